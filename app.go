@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 
+	"lay/internal/ai"
 	core "lay/internal/app"
 )
 
@@ -12,8 +13,30 @@ type appService interface {
 	SaveNotes(content string) error
 	GetConfig() core.Config
 	GetGatewayConfig() *core.GatewayConfig
-	SaveConfig(anthropicKey string, openAIKey string, model string, gatewayURL string, transcribeLang string) error
-	SendMessage(conversationJSON string) (string, error)
+	GetGateways() []core.GatewayConfig
+	GetGatewayStatus() []core.GatewayStatus
+	SaveGatewayModel(model string, gateway string) error
+	GetModels() []ai.Model
+	ListModels() core.ModelList
+	RefreshModels() core.ModelList
+	GetGatewayAuthStatus(name string) ai.GatewayAuthStatus
+	StartGatewayLogin(name string) (*ai.DeviceLogin, error)
+	LogoutGateway(name string) error
+	GetUsageSummary(period string) (core.UsageSummary, error)
+	SaveBudget(daily float64, monthly float64) error
+	SaveConfig(anthropicKey string, openAIKey string, geminiKey string, model string, gatewayURL string, localURL string, transcribeLang string) error
+	SendMessage(requestID string, conversationID string, messageJSON string) (string, error)
+	RegenerateMessage(requestID string, conversationID string, msgID string) (string, error)
+	EditAndResend(requestID string, conversationID string, msgID string, content string) (string, error)
+	CancelMessage(requestID string)
+	ListConversations(query string) ([]core.ConversationSummary, error)
+	LoadConversation(id string) (*core.Conversation, error)
+	LoadThread(id string) ([]core.Turn, error)
+	SwitchBranch(conversationID string, msgID string) ([]core.Turn, error)
+	DeleteConversation(id string) error
+	RenameConversation(id string, title string) error
+	ExportConversation(id string, format string) (string, error)
+	ExtractFromTranscript(kind string) (string, error)
 	StartRecording() (string, error)
 	StopRecording() error
 	Transcribe(recordingDir string) (string, error)
@@ -52,12 +75,100 @@ func (a *App) GetGatewayConfig() *core.GatewayConfig {
 	return a.service.GetGatewayConfig()
 }
 
-func (a *App) SaveConfig(anthropicKey string, openAIKey string, model string, gatewayURL string, transcribeLang string) error {
-	return a.service.SaveConfig(anthropicKey, openAIKey, model, gatewayURL, transcribeLang)
+func (a *App) GetGateways() []core.GatewayConfig {
+	return a.service.GetGateways()
 }
 
-func (a *App) SendMessage(conversationJSON string) (string, error) {
-	return a.service.SendMessage(conversationJSON)
+func (a *App) GetGatewayStatus() []core.GatewayStatus {
+	return a.service.GetGatewayStatus()
+}
+
+func (a *App) SaveGatewayModel(model string, gateway string) error {
+	return a.service.SaveGatewayModel(model, gateway)
+}
+
+func (a *App) GetModels() []ai.Model {
+	return a.service.GetModels()
+}
+
+func (a *App) ListModels() core.ModelList {
+	return a.service.ListModels()
+}
+
+func (a *App) RefreshModels() core.ModelList {
+	return a.service.RefreshModels()
+}
+
+func (a *App) GetGatewayAuthStatus(name string) ai.GatewayAuthStatus {
+	return a.service.GetGatewayAuthStatus(name)
+}
+
+func (a *App) StartGatewayLogin(name string) (*ai.DeviceLogin, error) {
+	return a.service.StartGatewayLogin(name)
+}
+
+func (a *App) LogoutGateway(name string) error {
+	return a.service.LogoutGateway(name)
+}
+
+func (a *App) GetUsageSummary(period string) (core.UsageSummary, error) {
+	return a.service.GetUsageSummary(period)
+}
+
+func (a *App) SaveBudget(daily float64, monthly float64) error {
+	return a.service.SaveBudget(daily, monthly)
+}
+
+func (a *App) SaveConfig(anthropicKey string, openAIKey string, geminiKey string, model string, gatewayURL string, localURL string, transcribeLang string) error {
+	return a.service.SaveConfig(anthropicKey, openAIKey, geminiKey, model, gatewayURL, localURL, transcribeLang)
+}
+
+func (a *App) SendMessage(requestID string, conversationID string, messageJSON string) (string, error) {
+	return a.service.SendMessage(requestID, conversationID, messageJSON)
+}
+
+func (a *App) RegenerateMessage(requestID string, conversationID string, msgID string) (string, error) {
+	return a.service.RegenerateMessage(requestID, conversationID, msgID)
+}
+
+func (a *App) EditAndResend(requestID string, conversationID string, msgID string, content string) (string, error) {
+	return a.service.EditAndResend(requestID, conversationID, msgID, content)
+}
+
+func (a *App) CancelMessage(requestID string) {
+	a.service.CancelMessage(requestID)
+}
+
+func (a *App) ListConversations(query string) ([]core.ConversationSummary, error) {
+	return a.service.ListConversations(query)
+}
+
+func (a *App) LoadConversation(id string) (*core.Conversation, error) {
+	return a.service.LoadConversation(id)
+}
+
+func (a *App) LoadThread(id string) ([]core.Turn, error) {
+	return a.service.LoadThread(id)
+}
+
+func (a *App) SwitchBranch(conversationID string, msgID string) ([]core.Turn, error) {
+	return a.service.SwitchBranch(conversationID, msgID)
+}
+
+func (a *App) DeleteConversation(id string) error {
+	return a.service.DeleteConversation(id)
+}
+
+func (a *App) RenameConversation(id string, title string) error {
+	return a.service.RenameConversation(id, title)
+}
+
+func (a *App) ExportConversation(id string, format string) (string, error) {
+	return a.service.ExportConversation(id, format)
+}
+
+func (a *App) ExtractFromTranscript(kind string) (string, error) {
+	return a.service.ExtractFromTranscript(kind)
 }
 
 func (a *App) StartRecording() (string, error) {
@@ -5,15 +5,17 @@ import (
 	"errors"
 	"testing"
 
+	"lay/internal/ai"
 	core "lay/internal/app"
 )
 
 type fakeService struct {
-	started bool
-	ctx     context.Context
-	notes   string
-	cfg     core.Config
-	err     error
+	started   bool
+	ctx       context.Context
+	notes     string
+	cfg       core.Config
+	err       error
+	cancelled string
 }
 
 func (f *fakeService) Startup(ctx context.Context) { f.started, f.ctx = true, ctx }
@@ -21,10 +23,46 @@ func (f *fakeService) GetNotes() string            { return f.notes }
 func (f *fakeService) SaveNotes(_ string) error    { return f.err }
 func (f *fakeService) GetConfig() core.Config              { return f.cfg }
 func (f *fakeService) GetGatewayConfig() *core.GatewayConfig { return nil }
-func (f *fakeService) SaveConfig(_, _, _, _, _ string) error {
+func (f *fakeService) GetGateways() []core.GatewayConfig      { return nil }
+func (f *fakeService) GetGatewayStatus() []core.GatewayStatus { return nil }
+func (f *fakeService) SaveGatewayModel(_, _ string) error     { return f.err }
+func (f *fakeService) GetModels() []ai.Model                  { return nil }
+func (f *fakeService) ListModels() core.ModelList             { return core.ModelList{} }
+func (f *fakeService) RefreshModels() core.ModelList          { return core.ModelList{} }
+func (f *fakeService) GetGatewayAuthStatus(_ string) ai.GatewayAuthStatus {
+	return ai.GatewayAuthStatus{LoggedIn: true}
+}
+func (f *fakeService) StartGatewayLogin(_ string) (*ai.DeviceLogin, error) {
+	return &ai.DeviceLogin{UserCode: "CODE"}, f.err
+}
+func (f *fakeService) LogoutGateway(_ string) error { return f.err }
+func (f *fakeService) GetUsageSummary(period string) (core.UsageSummary, error) {
+	return core.UsageSummary{Period: period}, f.err
+}
+func (f *fakeService) SaveBudget(_, _ float64) error { return f.err }
+func (f *fakeService) SaveConfig(_, _, _, _, _, _, _ string) error {
 	return f.err
 }
-func (f *fakeService) SendMessage(_ string) (string, error)   { return "ok", f.err }
+func (f *fakeService) SendMessage(_, _, _ string) (string, error) { return "ok", f.err }
+func (f *fakeService) RegenerateMessage(_, _, _ string) (string, error) { return "again", f.err }
+func (f *fakeService) EditAndResend(_, _, _, _ string) (string, error) { return "edited", f.err }
+func (f *fakeService) CancelMessage(id string)                 { f.cancelled = id }
+func (f *fakeService) ListConversations(_ string) ([]core.ConversationSummary, error) {
+	return []core.ConversationSummary{{ID: "c1"}}, f.err
+}
+func (f *fakeService) LoadConversation(id string) (*core.Conversation, error) {
+	return &core.Conversation{ID: id}, f.err
+}
+func (f *fakeService) LoadThread(_ string) ([]core.Turn, error) {
+	return []core.Turn{{Message: core.Message{ID: "m1"}}}, f.err
+}
+func (f *fakeService) SwitchBranch(_, msgID string) ([]core.Turn, error) {
+	return []core.Turn{{Message: core.Message{ID: msgID}}}, f.err
+}
+func (f *fakeService) DeleteConversation(_ string) error    { return f.err }
+func (f *fakeService) RenameConversation(_, _ string) error { return f.err }
+func (f *fakeService) ExportConversation(_, format string) (string, error) { return format, f.err }
+func (f *fakeService) ExtractFromTranscript(_ string) (string, error) { return `{"items":[]}`, f.err }
 func (f *fakeService) StartRecording() (string, error)         { return "/tmp/r", f.err }
 func (f *fakeService) StopRecording() error                    { return f.err }
 func (f *fakeService) Transcribe(_ string) (string, error)     { return "tx", f.err }
@@ -53,6 +91,26 @@ func TestAppWrapperDelegates(t *testing.T) {
 	if got := a.GetConfig(); got.Model != "claude-sonnet-4-6" {
 		t.Fatalf("GetConfig().Model = %q", got.Model)
 	}
+
+	a.CancelMessage("req-1")
+	if f.cancelled != "req-1" {
+		t.Fatalf("CancelMessage should delegate request ID, got %q", f.cancelled)
+	}
+	if !a.GetGatewayAuthStatus("").LoggedIn {
+		t.Fatalf("GetGatewayAuthStatus should delegate to service")
+	}
+	if login, err := a.StartGatewayLogin("Corp"); err != nil || login.UserCode != "CODE" {
+		t.Fatalf("StartGatewayLogin() = %+v, %v", login, err)
+	}
+	if list, err := a.ListConversations(""); err != nil || len(list) != 1 || list[0].ID != "c1" {
+		t.Fatalf("ListConversations() = %+v, %v", list, err)
+	}
+	if out, err := a.ExportConversation("c1", "json"); err != nil || out != "json" {
+		t.Fatalf("ExportConversation() = %q, %v", out, err)
+	}
+	if turns, err := a.SwitchBranch("c1", "m2"); err != nil || len(turns) != 1 || turns[0].ID != "m2" {
+		t.Fatalf("SwitchBranch() = %+v, %v", turns, err)
+	}
 }
 
 func TestAppWrapperPropagatesErrors(t *testing.T) {
@@ -62,7 +120,13 @@ func TestAppWrapperPropagatesErrors(t *testing.T) {
 	if err := a.SaveNotes("x"); !errors.Is(err, expected) {
 		t.Fatalf("SaveNotes() error = %v, want %v", err, expected)
 	}
-	if _, err := a.SendMessage("[]"); !errors.Is(err, expected) {
+	if _, err := a.SendMessage("req-1", "c1", "{}"); !errors.Is(err, expected) {
 		t.Fatalf("SendMessage() error = %v, want %v", err, expected)
 	}
+	if _, err := a.LoadConversation("c1"); !errors.Is(err, expected) {
+		t.Fatalf("LoadConversation() error = %v, want %v", err, expected)
+	}
+	if _, err := a.EditAndResend("req-2", "c1", "m1", "x"); !errors.Is(err, expected) {
+		t.Fatalf("EditAndResend() error = %v, want %v", err, expected)
+	}
 }
@@ -1,5 +1,21 @@
+export interface ChatDocument {
+  name: string;
+  mimeType: string; // set from the contents by the backend
+  data: string; // base64, no prefix
+}
+
 export interface ChatMessage {
+  id?: string; // set by the backend once the message is saved
   role: 'user' | 'assistant';
   content: string;
   images?: string[]; // base64-encoded image data (no prefix)
+  documents?: ChatDocument[]; // attached PDFs and text files
+  thinking?: string; // model reasoning shown apart from the answer
+  compacted?: string; // what was summarized to fit the model's context window
+  adapted?: string; // what was left out because the model cannot take it
+  model?: string; // the model that answered
+  fellBack?: boolean; // model stood in for the chosen one, which was unavailable
+  transcript?: string; // the meeting the question was about, as named in ~/.lay/transcripts
+  createdAt?: string;
+  siblings?: string[]; // IDs of the edited or regenerated versions of this message, oldest first
 }
@@ -1,24 +1,67 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {app} from '../models';
+import {ai} from '../models';
 
 export function AppendTranscriptToNotes(arg1:string):Promise<void>;
 
+export function CancelMessage(arg1:string):Promise<void>;
+
+export function DeleteConversation(arg1:string):Promise<void>;
+
+export function EditAndResend(arg1:string,arg2:string,arg3:string,arg4:string):Promise<string>;
+
+export function ExportConversation(arg1:string,arg2:string):Promise<string>;
+
 export function ExportToFile(arg1:string,arg2:string):Promise<void>;
 
+export function ExtractFromTranscript(arg1:string):Promise<string>;
+
 export function GetConfig():Promise<app.Config>;
 
+export function GetGatewayAuthStatus(arg1:string):Promise<ai.GatewayAuthStatus>;
+
 export function GetGatewayConfig():Promise<app.GatewayConfig>;
 
+export function GetGatewayStatus():Promise<Array<app.GatewayStatus>>;
+
+export function GetGateways():Promise<Array<app.GatewayConfig>>;
+
 export function GetHomePath():Promise<string>;
 
+export function GetModels():Promise<Array<ai.Model>>;
+
 export function GetNotes():Promise<string>;
 
-export function SaveConfig(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string):Promise<void>;
+export function GetUsageSummary(arg1:string):Promise<app.UsageSummary>;
+
+export function ListConversations(arg1:string):Promise<Array<app.ConversationSummary>>;
+
+export function ListModels():Promise<app.ModelList>;
+
+export function LoadConversation(arg1:string):Promise<app.Conversation>;
+
+export function LoadThread(arg1:string):Promise<Array<app.Turn>>;
+
+export function LogoutGateway(arg1:string):Promise<void>;
+
+export function RefreshModels():Promise<app.ModelList>;
+
+export function RegenerateMessage(arg1:string,arg2:string,arg3:string):Promise<string>;
+
+export function RenameConversation(arg1:string,arg2:string):Promise<void>;
+
+export function SaveBudget(arg1:number,arg2:number):Promise<void>;
+
+export function SaveConfig(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string,arg6:string,arg7:string):Promise<void>;
+
+export function SaveGatewayModel(arg1:string,arg2:string):Promise<void>;
 
 export function SaveNotes(arg1:string):Promise<void>;
 
-export function SendMessage(arg1:string):Promise<string>;
+export function SendMessage(arg1:string,arg2:string,arg3:string):Promise<string>;
+
+export function StartGatewayLogin(arg1:string):Promise<ai.DeviceLogin>;
 
 export function StartMicOnlyRecording():Promise<string>;
 
@@ -26,6 +69,8 @@ export function StartRecording():Promise<string>;
 
 export function StopRecording():Promise<void>;
 
+export function SwitchBranch(arg1:string,arg2:string):Promise<Array<app.Turn>>;
+
 export function Transcribe(arg1:string):Promise<string>;
 
 export function TranscribeMicOnly(arg1:string):Promise<string>;
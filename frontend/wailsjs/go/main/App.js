@@ -6,36 +6,120 @@ export function AppendTranscriptToNotes(arg1) {
   return window['go']['main']['App']['AppendTranscriptToNotes'](arg1);
 }
 
+export function CancelMessage(arg1) {
+  return window['go']['main']['App']['CancelMessage'](arg1);
+}
+
+export function DeleteConversation(arg1) {
+  return window['go']['main']['App']['DeleteConversation'](arg1);
+}
+
+export function EditAndResend(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['EditAndResend'](arg1, arg2, arg3, arg4);
+}
+
+export function ExportConversation(arg1, arg2) {
+  return window['go']['main']['App']['ExportConversation'](arg1, arg2);
+}
+
 export function ExportToFile(arg1, arg2) {
   return window['go']['main']['App']['ExportToFile'](arg1, arg2);
 }
 
+export function ExtractFromTranscript(arg1) {
+  return window['go']['main']['App']['ExtractFromTranscript'](arg1);
+}
+
 export function GetConfig() {
   return window['go']['main']['App']['GetConfig']();
 }
 
+export function GetGatewayAuthStatus(arg1) {
+  return window['go']['main']['App']['GetGatewayAuthStatus'](arg1);
+}
+
 export function GetGatewayConfig() {
   return window['go']['main']['App']['GetGatewayConfig']();
 }
 
+export function GetGatewayStatus() {
+  return window['go']['main']['App']['GetGatewayStatus']();
+}
+
+export function GetGateways() {
+  return window['go']['main']['App']['GetGateways']();
+}
+
 export function GetHomePath() {
   return window['go']['main']['App']['GetHomePath']();
 }
 
+export function GetModels() {
+  return window['go']['main']['App']['GetModels']();
+}
+
 export function GetNotes() {
   return window['go']['main']['App']['GetNotes']();
 }
 
-export function SaveConfig(arg1, arg2, arg3, arg4, arg5) {
-  return window['go']['main']['App']['SaveConfig'](arg1, arg2, arg3, arg4, arg5);
+export function GetUsageSummary(arg1) {
+  return window['go']['main']['App']['GetUsageSummary'](arg1);
+}
+
+export function ListConversations(arg1) {
+  return window['go']['main']['App']['ListConversations'](arg1);
+}
+
+export function ListModels() {
+  return window['go']['main']['App']['ListModels']();
+}
+
+export function LoadConversation(arg1) {
+  return window['go']['main']['App']['LoadConversation'](arg1);
+}
+
+export function LoadThread(arg1) {
+  return window['go']['main']['App']['LoadThread'](arg1);
+}
+
+export function LogoutGateway(arg1) {
+  return window['go']['main']['App']['LogoutGateway'](arg1);
+}
+
+export function RefreshModels() {
+  return window['go']['main']['App']['RefreshModels']();
+}
+
+export function RegenerateMessage(arg1, arg2, arg3) {
+  return window['go']['main']['App']['RegenerateMessage'](arg1, arg2, arg3);
+}
+
+export function RenameConversation(arg1, arg2) {
+  return window['go']['main']['App']['RenameConversation'](arg1, arg2);
+}
+
+export function SaveBudget(arg1, arg2) {
+  return window['go']['main']['App']['SaveBudget'](arg1, arg2);
+}
+
+export function SaveConfig(arg1, arg2, arg3, arg4, arg5, arg6, arg7) {
+  return window['go']['main']['App']['SaveConfig'](arg1, arg2, arg3, arg4, arg5, arg6, arg7);
+}
+
+export function SaveGatewayModel(arg1, arg2) {
+  return window['go']['main']['App']['SaveGatewayModel'](arg1, arg2);
 }
 
 export function SaveNotes(arg1) {
   return window['go']['main']['App']['SaveNotes'](arg1);
 }
 
-export function SendMessage(arg1) {
-  return window['go']['main']['App']['SendMessage'](arg1);
+export function SendMessage(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SendMessage'](arg1, arg2, arg3);
+}
+
+export function StartGatewayLogin(arg1) {
+  return window['go']['main']['App']['StartGatewayLogin'](arg1);
 }
 
 export function StartMicOnlyRecording() {
@@ -50,6 +134,10 @@ export function StopRecording() {
   return window['go']['main']['App']['StopRecording']();
 }
 
+export function SwitchBranch(arg1, arg2) {
+  return window['go']['main']['App']['SwitchBranch'](arg1, arg2);
+}
+
 export function Transcribe(arg1) {
   return window['go']['main']['App']['Transcribe'](arg1);
 }
@@ -1,11 +1,214 @@
+export namespace ai {
+	
+	export class Capabilities {
+	    vision: boolean;
+	    documents?: boolean;
+	    streaming: boolean;
+	    tools?: boolean;
+	    reasoning?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Capabilities(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.vision = source["vision"];
+	        this.documents = source["documents"];
+	        this.streaming = source["streaming"];
+	        this.tools = source["tools"];
+	        this.reasoning = source["reasoning"];
+	    }
+	}
+	export class DeviceLogin {
+	    userCode: string;
+	    verificationURI: string;
+	    verificationURIComplete?: string;
+	    expiresIn: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DeviceLogin(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.userCode = source["userCode"];
+	        this.verificationURI = source["verificationURI"];
+	        this.verificationURIComplete = source["verificationURIComplete"];
+	        this.expiresIn = source["expiresIn"];
+	    }
+	}
+	export class Document {
+	    name: string;
+	    mimeType: string;
+	    data: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Document(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.mimeType = source["mimeType"];
+	        this.data = source["data"];
+	    }
+	}
+	export class GatewayAuthStatus {
+	    loggedIn: boolean;
+	    subject?: string;
+	    // Go type: time
+	    expiresAt?: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new GatewayAuthStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.loggedIn = source["loggedIn"];
+	        this.subject = source["subject"];
+	        this.expiresAt = this.convertValues(source["expiresAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Params {
+	    maxTokens?: number;
+	    temperature?: number;
+	    topP?: number;
+	    stop?: string[];
+	    reasoningEffort?: string;
+	    thinkingBudget?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Params(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.maxTokens = source["maxTokens"];
+	        this.temperature = source["temperature"];
+	        this.topP = source["topP"];
+	        this.stop = source["stop"];
+	        this.reasoningEffort = source["reasoningEffort"];
+	        this.thinkingBudget = source["thinkingBudget"];
+	    }
+	}
+	export class Model {
+	    id: string;
+	    provider: string;
+	    name: string;
+	    capabilities: Capabilities;
+	    params?: Params;
+	    contextWindow?: number;
+	    maxOutput?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Model(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.provider = source["provider"];
+	        this.name = source["name"];
+	        this.capabilities = this.convertValues(source["capabilities"], Capabilities);
+	        this.params = this.convertValues(source["params"], Params);
+	        this.contextWindow = source["contextWindow"];
+	        this.maxOutput = source["maxOutput"];
+	    }
+	
+	convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
 export namespace app {
 	
+	export class BudgetConfig {
+	    daily?: number;
+	    monthly?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new BudgetConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.daily = source["daily"];
+	        this.monthly = source["monthly"];
+	    }
+	}
+	export class NetworkConfig {
+	    proxyURL?: string;
+	    caFile?: string;
+	    clientCert?: string;
+	    clientKey?: string;
+	    connectTimeout?: number;
+	    readTimeout?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new NetworkConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.proxyURL = source["proxyURL"];
+	        this.caFile = source["caFile"];
+	        this.clientCert = source["clientCert"];
+	        this.clientKey = source["clientKey"];
+	        this.connectTimeout = source["connectTimeout"];
+	        this.readTimeout = source["readTimeout"];
+	    }
+	}
 	export class Config {
 	    anthropicKey: string;
 	    openaiKey: string;
+	    geminiKey: string;
 	    model: string;
 	    gatewayURL: string;
+	    localURL: string;
 	    transcribeLang: string;
+	    models?: ai.Model[];
+	    network?: NetworkConfig;
+	    budget?: BudgetConfig;
+	    gatewayModels?: Record<string, string>;
+	    fallbacks?: string[];
+	    fallbackOn?: string[];
+	    params?: Record<string, ai.Params>;
 	
 	    static createFrom(source: any = {}) {
 	        return new Config(source);
@@ -15,14 +218,188 @@ export namespace app {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.anthropicKey = source["anthropicKey"];
 	        this.openaiKey = source["openaiKey"];
+	        this.geminiKey = source["geminiKey"];
 	        this.model = source["model"];
 	        this.gatewayURL = source["gatewayURL"];
+	        this.localURL = source["localURL"];
 	        this.transcribeLang = source["transcribeLang"];
+	        this.models = this.convertValues(source["models"], ai.Model);
+	        this.network = this.convertValues(source["network"], NetworkConfig);
+	        this.budget = this.convertValues(source["budget"], BudgetConfig);
+	        this.gatewayModels = source["gatewayModels"];
+	        this.fallbacks = source["fallbacks"];
+	        this.fallbackOn = source["fallbackOn"];
+	        this.params = this.convertValues(source["params"], ai.Params, true);
 	    }
+	
+	convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Message {
+	    id: string;
+	    parentId?: string;
+	    role: string;
+	    content: string;
+	    images?: string[];
+	    documents?: ai.Document[];
+	    thinking?: string;
+	    model?: string;
+	    fellBack?: boolean;
+	    compacted?: string;
+	    adapted?: string;
+	    transcript?: string;
+	    // Go type: time
+	    createdAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Message(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.parentId = source["parentId"];
+	        this.role = source["role"];
+	        this.content = source["content"];
+	        this.images = source["images"];
+	        this.documents = this.convertValues(source["documents"], ai.Document);
+	        this.thinking = source["thinking"];
+	        this.model = source["model"];
+	        this.fellBack = source["fellBack"];
+	        this.compacted = source["compacted"];
+	        this.adapted = source["adapted"];
+	        this.transcript = source["transcript"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Conversation {
+	    id: string;
+	    title: string;
+	    // Go type: time
+	    createdAt: any;
+	    // Go type: time
+	    updatedAt: any;
+	    messages: Message[];
+	    current?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Conversation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.title = source["title"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	        this.messages = this.convertValues(source["messages"], Message);
+	        this.current = source["current"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ConversationSummary {
+	    id: string;
+	    title: string;
+	    // Go type: time
+	    createdAt: any;
+	    // Go type: time
+	    updatedAt: any;
+	    messages: number;
+	    preview?: string;
+	    transcript?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ConversationSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.title = source["title"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	        this.messages = source["messages"];
+	        this.preview = source["preview"];
+	        this.transcript = source["transcript"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class GatewayModel {
 	    value: string;
 	    label: string;
+	    vision?: boolean;
+	    documents?: boolean;
+	    tools?: boolean;
+	    streaming?: boolean;
+	    contextWindow?: number;
+	    maxOutput?: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new GatewayModel(source);
@@ -32,12 +409,110 @@ export namespace app {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.value = source["value"];
 	        this.label = source["label"];
+	        this.vision = source["vision"];
+	        this.documents = source["documents"];
+	        this.tools = source["tools"];
+	        this.streaming = source["streaming"];
+	        this.contextWindow = source["contextWindow"];
+	        this.maxOutput = source["maxOutput"];
+	    }
+	}
+	export class GatewayAuthConfig {
+	    type: string;
+	    token?: string;
+	    header?: string;
+	    command?: string;
+	    flow?: string;
+	    tokenURL?: string;
+	    deviceAuthURL?: string;
+	    clientID?: string;
+	    clientSecret?: string;
+	    scopes?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new GatewayAuthConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.token = source["token"];
+	        this.header = source["header"];
+	        this.command = source["command"];
+	        this.flow = source["flow"];
+	        this.tokenURL = source["tokenURL"];
+	        this.deviceAuthURL = source["deviceAuthURL"];
+	        this.clientID = source["clientID"];
+	        this.clientSecret = source["clientSecret"];
+	        this.scopes = source["scopes"];
 	    }
 	}
+	export class GatewayResponseSelectors {
+	    text: string;
+	    thinking?: string;
+	    inputTokens?: string;
+	    outputTokens?: string;
+	    error?: string;
+	    streamText?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new GatewayResponseSelectors(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.text = source["text"];
+	        this.thinking = source["thinking"];
+	        this.inputTokens = source["inputTokens"];
+	        this.outputTokens = source["outputTokens"];
+	        this.error = source["error"];
+	        this.streamText = source["streamText"];
+	    }
+	}
+	export class GatewayCustomConfig {
+	    request: any;
+	    response: GatewayResponseSelectors;
+	
+	    static createFrom(source: any = {}) {
+	        return new GatewayCustomConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.request = source["request"];
+	        this.response = this.convertValues(source["response"], GatewayResponseSelectors);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class GatewayConfig {
 	    name: string;
 	    url: string;
 	    models: GatewayModel[];
+	    headers?: Record<string, string>;
+	    auth?: GatewayAuthConfig;
+	    priority?: number;
+	    healthURL?: string;
+	    modelsURL?: string;
+	    promptCache?: boolean;
+	    dialect?: string;
+	    custom?: GatewayCustomConfig;
 	
 	    static createFrom(source: any = {}) {
 	        return new GatewayConfig(source);
@@ -48,6 +523,166 @@ export namespace app {
 	        this.name = source["name"];
 	        this.url = source["url"];
 	        this.models = this.convertValues(source["models"], GatewayModel);
+	        this.headers = source["headers"];
+	        this.auth = this.convertValues(source["auth"], GatewayAuthConfig);
+	        this.priority = source["priority"];
+	        this.healthURL = source["healthURL"];
+	        this.modelsURL = source["modelsURL"];
+	        this.promptCache = source["promptCache"];
+	        this.dialect = source["dialect"];
+	        this.custom = this.convertValues(source["custom"], GatewayCustomConfig);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class GatewayStatus {
+	    name: string;
+	    url: string;
+	    priority: number;
+	    healthy: boolean;
+	    // Go type: time
+	    checkedAt: any;
+	    latencyMs?: number;
+	    lastError?: string;
+	    active: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new GatewayStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.url = source["url"];
+	        this.priority = source["priority"];
+	        this.healthy = source["healthy"];
+	        this.checkedAt = this.convertValues(source["checkedAt"], null);
+	        this.latencyMs = source["latencyMs"];
+	        this.lastError = source["lastError"];
+	        this.active = source["active"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ModelList {
+	    models: ai.Model[];
+	    errors?: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new ModelList(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.models = this.convertValues(source["models"], ai.Model);
+	        this.errors = source["errors"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ModelUsage {
+	    model: string;
+	    requests: number;
+	    inputTokens: number;
+	    outputTokens: number;
+	    cacheReadTokens: number;
+	    cacheWriteTokens: number;
+	    cost: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ModelUsage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.model = source["model"];
+	        this.requests = source["requests"];
+	        this.inputTokens = source["inputTokens"];
+	        this.outputTokens = source["outputTokens"];
+	        this.cacheReadTokens = source["cacheReadTokens"];
+	        this.cacheWriteTokens = source["cacheWriteTokens"];
+	        this.cost = source["cost"];
+	    }
+	}
+	export class UsageSummary {
+	    period: string;
+	    // Go type: time
+	    since: any;
+	    requests: number;
+	    inputTokens: number;
+	    outputTokens: number;
+	    cacheReadTokens: number;
+	    cacheWriteTokens: number;
+	    cost: number;
+	    unpriced: number;
+	    budget?: number;
+	    models: ModelUsage[];
+	
+	    static createFrom(source: any = {}) {
+	        return new UsageSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.period = source["period"];
+	        this.since = this.convertValues(source["since"], null);
+	        this.requests = source["requests"];
+	        this.inputTokens = source["inputTokens"];
+	        this.outputTokens = source["outputTokens"];
+	        this.cacheReadTokens = source["cacheReadTokens"];
+	        this.cacheWriteTokens = source["cacheWriteTokens"];
+	        this.cost = source["cost"];
+	        this.unpriced = source["unpriced"];
+	        this.budget = source["budget"];
+	        this.models = this.convertValues(source["models"], ModelUsage);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -69,5 +704,61 @@ export namespace app {
 		}
 	}
 
+	export class Turn {
+	    id: string;
+	    parentId?: string;
+	    role: string;
+	    content: string;
+	    images?: string[];
+	    documents?: ai.Document[];
+	    thinking?: string;
+	    model?: string;
+	    fellBack?: boolean;
+	    compacted?: string;
+	    adapted?: string;
+	    transcript?: string;
+	    // Go type: time
+	    createdAt: any;
+	    siblings?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Turn(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.parentId = source["parentId"];
+	        this.role = source["role"];
+	        this.content = source["content"];
+	        this.images = source["images"];
+	        this.documents = this.convertValues(source["documents"], ai.Document);
+	        this.thinking = source["thinking"];
+	        this.model = source["model"];
+	        this.fellBack = source["fellBack"];
+	        this.compacted = source["compacted"];
+	        this.adapted = source["adapted"];
+	        this.transcript = source["transcript"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	        this.siblings = source["siblings"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 }
-
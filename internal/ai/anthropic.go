@@ -0,0 +1,217 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// AnthropicProvider is the provider ID of Anthropic's API.
+const AnthropicProvider = "anthropic"
+
+type anthropicProvider struct{}
+
+func (anthropicProvider) ID() string { return AnthropicProvider }
+
+func (anthropicProvider) Send(ctx context.Context, cfg Config, req Request, onDelta DeltaFunc) (*Response, error) {
+	if cfg.AnthropicKey == "" {
+		return nil, fmt.Errorf("Anthropic API key not set — open Settings to add your key")
+	}
+
+	// Retries are handled by the client's shared policy, not the SDK.
+	client := anthropic.NewClient(option.WithAPIKey(cfg.AnthropicKey), option.WithMaxRetries(0), option.WithHTTPClient(cfg.httpClient()))
+
+	params := anthropic.MessageNewParams{
+		Model:         anthropic.Model(req.Model.ID),
+		MaxTokens:     int64(req.Params.maxTokens(defaultMaxTokens)),
+		Messages:      anthropicMessages(req.Messages),
+		System:        anthropicSystem(req.SystemBlocks),
+		StopSequences: req.Params.Stop,
+	}
+	budget := req.Params.thinkingBudget()
+	tools := req.Tools
+	if req.Schema != nil {
+		// The answer is the input of a tool the model must call. A forced
+		// tool call cannot be combined with thinking.
+		budget = 0
+		tools = []Tool{{Name: req.Schema.Name, Description: req.Schema.Description, Parameters: req.Schema.JSON}}
+		params.ToolChoice = anthropic.ToolChoiceParamOfTool(req.Schema.Name)
+	}
+	if budget > 0 {
+		// The budget counts towards max_tokens and must leave room for the
+		// answer. Sampling parameters cannot be combined with thinking.
+		budget = max(budget, minThinkingBudget)
+		if params.MaxTokens <= int64(budget) {
+			params.MaxTokens = int64(budget + defaultMaxTokens)
+		}
+		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(int64(budget))
+	} else {
+		if t := req.Params.Temperature; t != nil {
+			params.Temperature = anthropic.Float(*t)
+		}
+		if p := req.Params.TopP; p != nil {
+			params.TopP = anthropic.Float(*p)
+		}
+	}
+	for _, t := range tools {
+		properties, required := t.schemaProperties()
+		tool := anthropic.ToolUnionParamOfTool(anthropic.ToolInputSchemaParam{Properties: properties, Required: required}, t.Name)
+		tool.OfTool.Description = anthropic.String(t.Description)
+		params.Tools = append(params.Tools, tool)
+	}
+
+	// Always stream: the SDK refuses non-streaming requests whose max_tokens
+	// could take longer than ten minutes to generate.
+	if onDelta == nil {
+		onDelta = func(string) {}
+	}
+	return streamAnthropic(ctx, cfg, client, params, onDelta)
+}
+
+// anthropicMessages converts the conversation to Anthropic's format. Tool
+// results are sent back as user turns, with consecutive results merged into
+// one message as the API requires.
+func anthropicMessages(messages []Message) []anthropic.MessageParam {
+	var out []anthropic.MessageParam
+	for _, m := range messages {
+		switch m.Role {
+		case "user":
+			var blocks []anthropic.ContentBlockParamUnion
+			for _, doc := range m.Documents {
+				blocks = append(blocks, anthropicDocument(doc))
+			}
+			for _, img := range m.Images {
+				blocks = append(blocks, anthropic.NewImageBlockBase64(imageMIME(img), img))
+			}
+			if m.Content != "" {
+				blocks = append(blocks, anthropic.NewTextBlock(m.Content))
+			}
+			out = append(out, anthropic.NewUserMessage(blocks...))
+		case "assistant":
+			var blocks []anthropic.ContentBlockParamUnion
+			for _, t := range m.Thinking {
+				if t.Redacted != "" {
+					blocks = append(blocks, anthropic.NewRedactedThinkingBlock(t.Redacted))
+				} else if t.Signature != "" {
+					blocks = append(blocks, anthropic.NewThinkingBlock(t.Signature, t.Text))
+				}
+			}
+			if m.Content != "" {
+				blocks = append(blocks, anthropic.NewTextBlock(m.Content))
+			}
+			for _, call := range m.ToolCalls {
+				blocks = append(blocks, anthropic.NewToolUseBlock(call.ID, toolInput(call.Arguments), call.Name))
+			}
+			out = append(out, anthropic.NewAssistantMessage(blocks...))
+		case "tool":
+			result := anthropic.NewToolResultBlock(m.ToolCallID, m.Content, false)
+			if n := len(out); n > 0 && out[n-1].Role == anthropic.MessageParamRoleUser && out[n-1].Content[0].OfToolResult != nil {
+				out[n-1].Content = append(out[n-1].Content, result)
+			} else {
+				out = append(out, anthropic.NewUserMessage(result))
+			}
+		}
+	}
+	return out
+}
+
+func anthropicDocument(doc Document) anthropic.ContentBlockParamUnion {
+	var block anthropic.ContentBlockParamUnion
+	if doc.isPDF() {
+		block = anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: doc.Data})
+	} else {
+		data, _ := base64.StdEncoding.DecodeString(doc.Data)
+		block = anthropic.NewDocumentBlock(anthropic.PlainTextSourceParam{Data: string(data)})
+	}
+	if doc.Name != "" {
+		block.OfDocument.Title = anthropic.String(doc.Name)
+	}
+	return block
+}
+
+// anthropicResponse collects the text, thinking and tool calls of a finished
+// message. Text blocks are joined; thinking usually comes before them.
+func anthropicResponse(msg *anthropic.Message) (*Response, error) {
+	resp := &Response{Usage: anthropicUsage(msg.Usage)}
+	var sb strings.Builder
+	for _, block := range msg.Content {
+		switch block.Type {
+		case "text":
+			sb.WriteString(block.Text)
+		case "thinking":
+			resp.Thinking = append(resp.Thinking, Thinking{Text: block.Thinking, Signature: block.Signature})
+		case "redacted_thinking":
+			resp.Thinking = append(resp.Thinking, Thinking{Redacted: block.Data})
+		case "tool_use":
+			resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: block.ID, Name: block.Name, Arguments: string(block.Input)})
+		}
+	}
+	resp.Text = sb.String()
+	if resp.Text == "" && len(resp.ToolCalls) == 0 {
+		return nil, fmt.Errorf("no text content in response")
+	}
+	return resp, nil
+}
+
+// anthropicSystem converts the system prompt, with a cache breakpoint after
+// each block marked for caching. Anthropic allows four breakpoints; the
+// first ones are kept since they cover the most stable prefixes.
+func anthropicSystem(blocks []SystemBlock) []anthropic.TextBlockParam {
+	var out []anthropic.TextBlockParam
+	breakpoints := 0
+	for _, b := range blocks {
+		if b.Text == "" {
+			continue
+		}
+		block := anthropic.TextBlockParam{Text: b.Text}
+		if b.Cache && breakpoints < 4 {
+			block.CacheControl = anthropic.NewCacheControlEphemeralParam()
+			breakpoints++
+		}
+		out = append(out, block)
+	}
+	return out
+}
+
+func anthropicUsage(u anthropic.Usage) Usage {
+	return Usage{
+		InputTokens:      int(u.InputTokens),
+		OutputTokens:     int(u.OutputTokens),
+		CacheReadTokens:  int(u.CacheReadInputTokens),
+		CacheWriteTokens: int(u.CacheCreationInputTokens),
+	}
+}
+
+func streamAnthropic(ctx context.Context, cfg Config, client anthropic.Client, params anthropic.MessageNewParams, onDelta DeltaFunc) (*Response, error) {
+	stream := client.Messages.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	// The accumulator assembles tool_use input and thinking signatures from
+	// their fragments and keeps the final usage; text and thinking are
+	// forwarded as they arrive.
+	var msg anthropic.Message
+	for stream.Next() {
+		event := stream.Current()
+		if err := msg.Accumulate(event); err != nil {
+			return nil, fmt.Errorf("Anthropic API error: %w", err)
+		}
+		if delta, ok := event.AsAny().(anthropic.ContentBlockDeltaEvent); ok {
+			switch d := delta.Delta.AsAny().(type) {
+			case anthropic.TextDelta:
+				if d.Text != "" {
+					onDelta(d.Text)
+				}
+			case anthropic.ThinkingDelta:
+				cfg.thinking(d.Thinking)
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("Anthropic API error: %w", err)
+	}
+	return anthropicResponse(&msg)
+}
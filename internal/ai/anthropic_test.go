@@ -0,0 +1,111 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// anthropicSSE renders Messages API stream events, each given as its JSON
+// payload, in server-sent event format.
+func anthropicSSE(events ...string) string {
+	var sb strings.Builder
+	for _, ev := range events {
+		typ := ev[strings.Index(ev, `"type":"`)+8:]
+		typ = typ[:strings.IndexByte(typ, '"')]
+		sb.WriteString("event: " + typ + "\ndata: " + ev + "\n\n")
+	}
+	return sb.String()
+}
+
+func TestAnthropicSeparatesThinkingFromText(t *testing.T) {
+	tr := &captureTransport{reply: anthropicSSE(
+		`{"type":"message_start","message":{"id":"msg","type":"message","role":"assistant","content":[],"usage":{"input_tokens":9}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":"","signature":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"They agreed on "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Friday."}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Friday, "}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"at noon."}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":30}}`,
+		`{"type":"message_stop"}`,
+	)}
+
+	var thinking, text strings.Builder
+	cfg := Config{
+		AnthropicKey: "k",
+		Model:        "claude-opus-4-6",
+		HTTPClient:   &http.Client{Transport: tr},
+		Params:       map[string]Params{"claude-opus-4-6": {ThinkingBudget: 4000}},
+		OnThinking:   func(d string) { thinking.WriteString(d) },
+	}
+	resp, err := New().Complete(context.Background(), cfg, "", []Message{{Role: "user", Content: "when?"}}, func(d string) { text.WriteString(d) })
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Text != "Friday, at noon." || text.String() != resp.Text {
+		t.Fatalf("Text = %q, streamed %q; want every text block", resp.Text, text.String())
+	}
+	if len(resp.Thinking) != 1 || resp.Thinking[0] != (Thinking{Text: "They agreed on Friday.", Signature: "sig"}) {
+		t.Fatalf("Thinking = %+v", resp.Thinking)
+	}
+	if thinking.String() != "They agreed on Friday." {
+		t.Fatalf("OnThinking got %q", thinking.String())
+	}
+	if resp.Usage != (Usage{InputTokens: 9, OutputTokens: 30}) {
+		t.Fatalf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicSendsThinkingBackWithToolCalls(t *testing.T) {
+	msgs := anthropicMessages([]Message{
+		{Role: "user", Content: "hi"},
+		{
+			Role:      "assistant",
+			Thinking:  []Thinking{{Text: "look it up", Signature: "sig"}, {Redacted: "opaque"}},
+			ToolCalls: []ToolCall{{ID: "a", Name: "read_notes"}},
+		},
+		{Role: "tool", ToolCallID: "a", Content: "notes"},
+	})
+	blocks := msgs[1].Content
+	if len(blocks) != 3 || blocks[0].OfThinking == nil || blocks[0].OfThinking.Signature != "sig" || blocks[1].OfRedactedThinking == nil || blocks[2].OfToolUse == nil {
+		t.Fatalf("thinking should precede the tool call unchanged, got %+v", blocks)
+	}
+}
+
+func TestAnthropicCachesStableSystemBlocks(t *testing.T) {
+	tr := &captureTransport{reply: anthropicSSE(
+		`{"type":"message_start","message":{"id":"msg","type":"message","role":"assistant","content":[],"usage":{"input_tokens":20,"cache_read_input_tokens":3000,"cache_creation_input_tokens":150}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ok"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+		`{"type":"message_stop"}`,
+	)}
+	cfg := Config{AnthropicKey: "k", Model: "claude-sonnet-4-6", HTTPClient: &http.Client{Transport: tr}}
+	system := []SystemBlock{{Text: "instructions + transcript", Cache: true}, {Text: "latest lines"}}
+	resp, err := New().CompleteBlocks(context.Background(), cfg, system, []Message{{Role: "user", Content: "hi"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := tr.body["system"].([]any)
+	if len(blocks) != 2 {
+		t.Fatalf("system = %v, want two blocks", blocks)
+	}
+	if cc, _ := blocks[0].(map[string]any)["cache_control"].(map[string]any); cc["type"] != "ephemeral" {
+		t.Fatalf("first block = %v, want a cache breakpoint", blocks[0])
+	}
+	if _, ok := blocks[1].(map[string]any)["cache_control"]; ok {
+		t.Fatal("the changing block should not be cached")
+	}
+	if want := (Usage{InputTokens: 20, OutputTokens: 2, CacheReadTokens: 3000, CacheWriteTokens: 150}); resp.Usage != want {
+		t.Fatalf("Usage = %+v, want %+v", resp.Usage, want)
+	}
+}
@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+const mimePDF = "application/pdf"
+
+// Document is a file attached to a user message: a PDF or plain text.
+type Document struct {
+	Name     string `json:"name"`
+	MIMEType string `json:"mimeType"` // "application/pdf" or "text/plain"
+	Data     string `json:"data"`     // base64
+}
+
+func (d Document) isPDF() bool { return d.MIMEType == mimePDF }
+
+// text returns the decoded contents of a text document wrapped in a
+// <document> tag, for providers that take documents as message text.
+func (d Document) text() string {
+	data, err := base64.StdEncoding.DecodeString(d.Data)
+	if err != nil {
+		return fmt.Sprintf("<document name=%q>\n(could not be decoded)\n</document>", d.Name)
+	}
+	return fmt.Sprintf("<document name=%q>\n%s\n</document>", d.Name, data)
+}
+
+// unreadable is the stand-in text for a PDF sent to a model that cannot
+// read one.
+func (d Document) unreadable() string {
+	return fmt.Sprintf("[Attached PDF %q was not sent: this model cannot read PDFs.]", d.Name)
+}
+
+// DocumentMIME sniffs the type of an attachment from its contents. Only
+// PDFs and UTF-8 text are accepted.
+func DocumentMIME(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return mimePDF, nil
+	case utf8.Valid(data) && !bytes.ContainsRune(data, 0):
+		return "text/plain", nil
+	}
+	return "", fmt.Errorf("unsupported attachment — only PDFs and plain-text files can be attached")
+}
+
+// imageMIME sniffs the type of base64 image data. Pasted images can be PNG,
+// JPEG, GIF or WebP; anything else is labelled PNG, the most common case.
+func imageMIME(b64 string) string {
+	// 64 base64 characters decode to 48 bytes, enough for every signature.
+	prefix := b64
+	if len(prefix) > 64 {
+		prefix = prefix[:64]
+	}
+	head, err := base64.StdEncoding.DecodeString(prefix)
+	if err != nil && len(head) == 0 {
+		return "image/png"
+	}
+	switch mime := http.DetectContentType(head); mime {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return mime
+	}
+	return "image/png"
+}
+
+// imageDataURL returns img as a data: URL with its real type.
+func imageDataURL(img string) string {
+	return "data:" + imageMIME(img) + ";base64," + img
+}
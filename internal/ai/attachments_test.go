@@ -0,0 +1,83 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"testing"
+)
+
+func TestImageMIME(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png"},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), "image/jpeg"},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), "image/gif"},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), "image/webp"},
+		{"unknown", []byte("not an image"), "image/png"},
+	}
+	for _, tt := range tests {
+		if got := imageMIME(base64.StdEncoding.EncodeToString(tt.data)); got != tt.want {
+			t.Errorf("%s: imageMIME() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDocumentMIME(t *testing.T) {
+	if mime, err := DocumentMIME([]byte("%PDF-1.7\n...")); err != nil || mime != mimePDF {
+		t.Fatalf("PDF: got %q, %v", mime, err)
+	}
+	if mime, err := DocumentMIME([]byte("# Agenda\n- item")); err != nil || mime != "text/plain" {
+		t.Fatalf("text: got %q, %v", mime, err)
+	}
+	if _, err := DocumentMIME([]byte("PK\x03\x04\x00\x00")); err == nil {
+		t.Fatal("binary data should be rejected")
+	}
+}
+
+var testPDF = Document{Name: "spec.pdf", MIMEType: mimePDF, Data: base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 test"))}
+
+func TestOpenAISendsPDFAsFilePart(t *testing.T) {
+	tr := &captureTransport{reply: `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`}
+	cfg := Config{OpenAIKey: "k", Model: "gpt-4.1", HTTPClient: &http.Client{Transport: tr}}
+	msgs := []Message{{Role: "user", Content: "summarise", Documents: []Document{testPDF}}}
+	if _, err := New().Send(context.Background(), cfg, "", msgs); err != nil {
+		t.Fatal(err)
+	}
+	parts := tr.body["messages"].([]any)[1].(map[string]any)["content"].([]any)
+	file, _ := parts[0].(map[string]any)["file"].(map[string]any)
+	if parts[0].(map[string]any)["type"] != "file" || file["filename"] != "spec.pdf" || file["file_data"] != "data:application/pdf;base64,"+testPDF.Data {
+		t.Fatalf("first part = %v, want the PDF as a file part", parts[0])
+	}
+	if parts[1].(map[string]any)["text"] != "summarise" {
+		t.Fatalf("second part = %v, want the message text", parts[1])
+	}
+}
+
+func TestAnthropicSendsDocumentBlocks(t *testing.T) {
+	tr := &captureTransport{reply: anthropicSSE(
+		`{"type":"message_start","message":{"id":"msg","type":"message","role":"assistant","content":[],"usage":{"input_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ok"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_stop"}`,
+	)}
+	cfg := Config{AnthropicKey: "k", Model: "claude-sonnet-4-6", HTTPClient: &http.Client{Transport: tr}}
+	notes := Document{Name: "notes.txt", MIMEType: "text/plain", Data: base64.StdEncoding.EncodeToString([]byte("hello"))}
+	msgs := []Message{{Role: "user", Content: "compare", Documents: []Document{testPDF, notes}}}
+	if _, err := New().Send(context.Background(), cfg, "", msgs); err != nil {
+		t.Fatal(err)
+	}
+	content := tr.body["messages"].([]any)[0].(map[string]any)["content"].([]any)
+	pdf := content[0].(map[string]any)
+	if pdf["type"] != "document" || pdf["source"].(map[string]any)["media_type"] != mimePDF || pdf["title"] != "spec.pdf" {
+		t.Fatalf("first block = %v, want a PDF document", pdf)
+	}
+	text := content[1].(map[string]any)["source"].(map[string]any)
+	if text["type"] != "text" || text["data"] != "hello" {
+		t.Fatalf("second block source = %v, want plain text", text)
+	}
+}
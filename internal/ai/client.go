@@ -1,38 +1,91 @@
 package ai
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"slices"
 	"strings"
-
-	anthropic "github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/option"
-	openai "github.com/sashabaranov/go-openai"
 )
 
 type Config struct {
-	AnthropicKey   string
-	OpenAIKey      string
-	Model          string
-	GatewayURL string // if set, all requests are routed through a gateway
+	AnthropicKey string
+	OpenAIKey    string
+	GeminiKey    string
+	Model        string
+	Gateway      *Gateway // if set, all requests are routed through this gateway
+	LocalURL     string   // base URL of an OpenAI-compatible local model server
+	Models       []Model  // user-declared models, consulted before the registry
+
+	Retry   RetryPolicy       // zero value means DefaultRetryPolicy
+	OnRetry func(RetryNotice) // called before each retry, e.g. to show progress
+
+	// OnThinking receives reasoning text as it streams, separately from the
+	// answer. A retry starts the reasoning over.
+	OnThinking DeltaFunc
+
+	HTTPClient *http.Client // shared by all providers, see NewHTTPClient; nil means http.DefaultClient
+
+	Tools []Tool // offered to models whose capabilities include tools
+
+	Params map[string]Params // per-model overrides, keyed by model ID
+
+	// Fallbacks are tried in order when Model fails with one of the
+	// FallbackOn kinds (DefaultFallbackOn if empty).
+	Fallbacks  []Fallback
+	FallbackOn []ErrorKind
+	OnFallback func(FallbackNotice) // called before moving on to the next model
+
+	schema *Schema // set by Extract
 }
 
 type Message struct {
-	Role    string
-	Content string
-	Images  []string // base64-encoded image data (PNG)
+	Role      string // "user", "assistant" or "tool"
+	Content   string
+	Images    []string   // base64-encoded PNG, JPEG, GIF or WebP data
+	Documents []Document // user: attached PDFs and text files
+
+	ToolCalls  []ToolCall // assistant: tools the model asked to run
+	Thinking   []Thinking // assistant: reasoning that preceded ToolCalls, sent back as-is
+	ToolCallID string     // tool: the call this message answers
+}
+
+// thinking forwards a chunk of reasoning to OnThinking, if set.
+func (cfg Config) thinking(delta string) {
+	if cfg.OnThinking != nil && delta != "" {
+		cfg.OnThinking(delta)
+	}
 }
 
-type Client struct{}
+// DeltaFunc receives each chunk of answer text as it streams in.
+type DeltaFunc func(delta string)
 
+type Client struct {
+	registry *Registry
+	images   imageCache
+}
+
+// New returns a client with the built-in providers and models registered.
 func New() *Client {
-	return &Client{}
+	r := NewRegistry()
+	r.Register(anthropicProvider{})
+	r.Register(openAIProvider{})
+	r.Register(geminiProvider{})
+	r.Register(localProvider{})
+	r.Register(&gatewayProvider{})
+	for _, m := range builtinModels {
+		r.RegisterModel(m)
+	}
+	return &Client{registry: r}
 }
 
+// Registry exposes the client's providers and models so callers can add their own.
+func (c *Client) Registry() *Registry {
+	return c.registry
+}
+
+// IsOpenAIModel reports whether a model ID looks like an OpenAI model. It is
+// only used to pick the wire format for gateway models that are not declared.
 func IsOpenAIModel(model string) bool {
 	return strings.HasPrefix(model, "gpt-") ||
 		strings.HasPrefix(model, "o1") ||
@@ -40,278 +93,196 @@ func IsOpenAIModel(model string) bool {
 		strings.HasPrefix(model, "o4")
 }
 
+// IsAnthropicModel reports whether a model ID looks like an Anthropic model.
 func IsAnthropicModel(model string) bool {
 	return strings.HasPrefix(model, "claude-") ||
 		strings.HasPrefix(model, "us.anthropic.")
 }
 
+// Send returns the complete answer once the provider has finished responding.
 func (c *Client) Send(ctx context.Context, cfg Config, systemPrompt string, messages []Message) (string, error) {
-	if cfg.GatewayURL != "" {
-		return c.sendGateway(ctx, cfg, systemPrompt, messages)
-	}
-	if IsOpenAIModel(cfg.Model) {
-		return c.sendOpenAI(ctx, cfg, systemPrompt, messages)
-	}
-	if IsAnthropicModel(cfg.Model) {
-		return c.sendAnthropic(ctx, cfg, systemPrompt, messages)
+	resp, err := c.Complete(ctx, cfg, systemPrompt, messages, nil)
+	if err != nil {
+		return "", err
 	}
-	return "", fmt.Errorf("model %q is not a recognized provider — set up a gateway to use it", cfg.Model)
+	return resp.Text, nil
 }
 
-func (c *Client) sendAnthropic(ctx context.Context, cfg Config, systemPrompt string, messages []Message) (string, error) {
-	if cfg.AnthropicKey == "" {
-		return "", fmt.Errorf("Anthropic API key not set — open Settings to add your key")
+// SendStream is like Send but calls onDelta with each chunk of text as it
+// arrives. The returned string is the full answer.
+func (c *Client) SendStream(ctx context.Context, cfg Config, systemPrompt string, messages []Message, onDelta DeltaFunc) (string, error) {
+	if onDelta == nil {
+		onDelta = func(string) {}
 	}
-
-	client := anthropic.NewClient(option.WithAPIKey(cfg.AnthropicKey))
-
-	var apiMessages []anthropic.MessageParam
-	for _, m := range messages {
-		switch m.Role {
-		case "user":
-			var blocks []anthropic.ContentBlockParamUnion
-			for _, img := range m.Images {
-				blocks = append(blocks, anthropic.NewImageBlockBase64("image/png", img))
-			}
-			if m.Content != "" {
-				blocks = append(blocks, anthropic.NewTextBlock(m.Content))
-			}
-			apiMessages = append(apiMessages, anthropic.NewUserMessage(blocks...))
-		case "assistant":
-			apiMessages = append(apiMessages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(m.Content)))
-		}
-	}
-
-	resp, err := client.Messages.New(ctx, anthropic.MessageNewParams{
-		Model:     anthropic.Model(cfg.Model),
-		MaxTokens: 2048,
-		Messages:  apiMessages,
-		System: []anthropic.TextBlockParam{
-			{Text: systemPrompt},
-		},
-	})
+	resp, err := c.Complete(ctx, cfg, systemPrompt, messages, onDelta)
 	if err != nil {
-		return "", fmt.Errorf("Anthropic API error: %w", err)
-	}
-	for _, block := range resp.Content {
-		if block.Type == "text" {
-			return block.Text, nil
-		}
+		return "", err
 	}
-	return "", fmt.Errorf("no text content in response")
+	return resp.Text, nil
 }
 
-func (c *Client) sendOpenAI(ctx context.Context, cfg Config, systemPrompt string, messages []Message) (string, error) {
-	if cfg.OpenAIKey == "" {
-		return "", fmt.Errorf("OpenAI API key not set — open Settings to add your key")
+// Complete returns the answer together with its token usage and the model
+// that produced it. A nil onDelta means no streaming.
+func (c *Client) Complete(ctx context.Context, cfg Config, systemPrompt string, messages []Message, onDelta DeltaFunc) (*Response, error) {
+	var system []SystemBlock
+	if systemPrompt != "" {
+		system = []SystemBlock{{Text: systemPrompt}}
 	}
+	return c.CompleteBlocks(ctx, cfg, system, messages, onDelta)
+}
 
-	client := openai.NewClient(cfg.OpenAIKey)
+// CompleteBlocks is Complete with the system prompt split into blocks, so
+// that providers with prompt caching can reuse its stable prefix.
+//
+// When cfg.Model fails with one of the cfg.FallbackOn kinds, once retries
+// are exhausted, the models in cfg.Fallbacks are tried in order; any
+// failure of a fallback moves on to the next. Response.Fallback tells which
+// one answered. Nothing falls back once answer text has been streamed.
+func (c *Client) CompleteBlocks(ctx context.Context, cfg Config, system []SystemBlock, messages []Message, onDelta DeltaFunc) (*Response, error) {
+	streamed := false
+	delta := onDelta
+	if onDelta != nil {
+		delta = func(d string) {
+			streamed = true
+			onDelta(d)
+		}
+	}
 
-	var msgs []openai.ChatCompletionMessage
-	msgs = append(msgs, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleSystem,
-		Content: systemPrompt,
-	})
-	for _, m := range messages {
-		role := openai.ChatMessageRoleUser
-		if m.Role == "assistant" {
-			role = openai.ChatMessageRoleAssistant
+	var firstErr error
+	var failed []string
+	for i := 0; i <= len(cfg.Fallbacks); i++ {
+		attempt := cfg.attempt(i)
+		resp, err := c.complete(ctx, attempt, system, messages, delta)
+		if err == nil {
+			resp.Fallback = i
+			return resp, nil
 		}
-		if len(m.Images) > 0 && m.Role == "user" {
-			var parts []openai.ChatMessagePart
-			for _, img := range m.Images {
-				parts = append(parts, openai.ChatMessagePart{
-					Type: openai.ChatMessagePartTypeImageURL,
-					ImageURL: &openai.ChatMessageImageURL{
-						URL: "data:image/png;base64," + img,
-					},
-				})
+		if i == 0 {
+			firstErr = err
+			if !cfg.fallsBack(err) {
+				return nil, err
 			}
-			if m.Content != "" {
-				parts = append(parts, openai.ChatMessagePart{
-					Type: openai.ChatMessagePartTypeText,
-					Text: m.Content,
-				})
-			}
-			msgs = append(msgs, openai.ChatCompletionMessage{Role: role, MultiContent: parts})
 		} else {
-			msgs = append(msgs, openai.ChatCompletionMessage{Role: role, Content: m.Content})
-		}
-	}
-
-	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:    cfg.Model,
-		Messages: msgs,
-	})
-	if err != nil {
-		return "", fmt.Errorf("OpenAI API error: %w", err)
-	}
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("empty response from OpenAI")
-	}
-	return resp.Choices[0].Message.Content, nil
-}
-
-// gatewayMessage is a message in the Chat Completions API format.
-type gatewayMessage struct {
-	Role    string `json:"role"`
-	Content any    `json:"content"` // string or []gatewayContentPart
-}
-
-// gatewayContentPart represents a content block in the gateway format.
-type gatewayContentPart struct {
-	Type     string           `json:"type"`
-	Text     string           `json:"text,omitempty"`
-	Image    *gatewayImage    `json:"image,omitempty"`    // Anthropic models
-	ImageURL *gatewayImageURL `json:"image_url,omitempty"` // OpenAI models
-}
-
-type gatewayImage struct {
-	File     string `json:"file"`
-	MimeType string `json:"mimeType"`
-}
-
-type gatewayImageURL struct {
-	URL string `json:"url"`
-}
-
-// gatewayParams holds model parameters sent inside the request body.
-type gatewayParams struct {
-	MaxOutputTokens int `json:"max_output_tokens"`
-}
-
-// gatewayRequest is the body sent to the gateway endpoint.
-type gatewayRequest struct {
-	Model      string           `json:"model"`
-	Messages   []gatewayMessage `json:"messages"`
-	System     string           `json:"system,omitempty"`
-	Parameters gatewayParams    `json:"parameters"`
-}
-
-// gatewayResponse handles both Anthropic and OpenAI Chat Completions response shapes.
-type gatewayResponse struct {
-	// Anthropic shape
-	Content []gatewayContentBlock `json:"content"`
-	// OpenAI Chat Completions shape
-	Choices []gatewayChoice `json:"choices"`
-	// Common
-	Error *gatewayErrorPayload `json:"error,omitempty"`
-}
-
-type gatewayContentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
-}
-
-type gatewayChoice struct {
-	Message struct {
-		Content string `json:"content"`
-	} `json:"message"`
-}
-
-type gatewayErrorPayload struct {
-	Message string `json:"message"`
-	Code    string `json:"code"`
-}
-
-func (c *Client) sendGateway(ctx context.Context, cfg Config, systemPrompt string, messages []Message) (string, error) {
-	isOpenAI := IsOpenAIModel(cfg.Model)
-
-	gwMessages := make([]gatewayMessage, 0, len(messages)+1)
-
-	// OpenAI models expect system prompt as a message; Anthropic uses top-level field.
-	if isOpenAI && systemPrompt != "" {
-		gwMessages = append(gwMessages, gatewayMessage{
-			Role:    "system",
-			Content: []gatewayContentPart{{Type: "text", Text: systemPrompt}},
-		})
-	}
-
-	for _, m := range messages {
-		var parts []gatewayContentPart
-		if len(m.Images) > 0 && m.Role == "user" {
-			for _, img := range m.Images {
-				if isOpenAI {
-					parts = append(parts, gatewayContentPart{
-						Type:     "image_url",
-						ImageURL: &gatewayImageURL{URL: "data:image/png;base64," + img},
-					})
-				} else {
-					parts = append(parts, gatewayContentPart{
-						Type: "image",
-						Image: &gatewayImage{
-							File:     img,
-							MimeType: "image/png",
-						},
-					})
-				}
+			label := attempt.Model
+			if name := gatewayName(attempt.Gateway); name != "" {
+				label += " via " + name
 			}
+			failed = append(failed, fmt.Sprintf("%s: %v", label, err))
 		}
-		if m.Content != "" {
-			parts = append(parts, gatewayContentPart{Type: "text", Text: m.Content})
+		if ctx.Err() != nil || streamed {
+			break
+		}
+		if i < len(cfg.Fallbacks) && cfg.OnFallback != nil {
+			next := cfg.Fallbacks[i]
+			cfg.OnFallback(FallbackNotice{
+				From: attempt.Model, To: next.Model,
+				FromGateway: gatewayName(attempt.Gateway), ToGateway: gatewayName(next.Gateway),
+				Err: err,
+			})
 		}
-		gwMessages = append(gwMessages, gatewayMessage{Role: m.Role, Content: parts})
-	}
-
-	reqBody := gatewayRequest{
-		Model:    cfg.Model,
-		Messages: gwMessages,
-		Parameters: gatewayParams{
-			MaxOutputTokens: 20000,
-		},
 	}
-	if !isOpenAI {
-		reqBody.System = systemPrompt
+	if len(failed) == 0 {
+		return nil, firstErr
 	}
+	return nil, fmt.Errorf("%w (fallbacks failed too — %s)", firstErr, strings.Join(failed, "; "))
+}
 
-	bodyBytes, err := json.Marshal(reqBody)
+// complete sends one request to cfg.Model, with retries.
+func (c *Client) complete(ctx context.Context, cfg Config, system []SystemBlock, messages []Message, onDelta DeltaFunc) (*Response, error) {
+	provider, model, err := c.resolve(cfg)
 	if err != nil {
-		return "", fmt.Errorf("failed to encode gateway request: %w", err)
+		return nil, err
 	}
-
-	url := cfg.GatewayURL
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+	messages, _, err = fitMessages(model, messages)
 	if err != nil {
-		return "", fmt.Errorf("failed to create gateway request: %w", err)
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("gateway request failed: %w", err)
+	req := Request{
+		Model:        model,
+		System:       systemText(system),
+		SystemBlocks: system,
+		Messages:     c.prepareImages(provider.ID(), messages),
+		Params:       cfg.params(model),
+	}
+	if model.Capabilities.Tools {
+		req.Tools = cfg.Tools
+	}
+	if cfg.schema != nil {
+		req.Schema = cfg.schema
+		if _, ok := provider.(schemaSender); !ok {
+			prompt := schemaPrompt(*cfg.schema)
+			if req.System != "" {
+				prompt = "\n\n" + prompt
+			}
+			req.SystemBlocks = append(slices.Clone(system), SystemBlock{Text: prompt})
+			req.System = systemText(req.SystemBlocks)
+		}
+	}
+	var resp *Response
+	if onDelta != nil && !model.Capabilities.Streaming {
+		resp, err = withRetry(ctx, provider.ID(), cfg.Retry, cfg.OnRetry, nil, func(DeltaFunc) (*Response, error) {
+			return provider.Send(ctx, cfg, req, nil)
+		})
+		if err == nil {
+			onDelta(resp.Text)
+		}
+	} else {
+		resp, err = withRetry(ctx, provider.ID(), cfg.Retry, cfg.OnRetry, onDelta, func(delta DeltaFunc) (*Response, error) {
+			return provider.Send(ctx, cfg, req, delta)
+		})
 	}
-	defer resp.Body.Close()
-
-	respBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read gateway response: %w", err)
+		return nil, err
 	}
+	resp.Model, resp.Provider = model, provider.ID()
+	return resp, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("gateway returned status %d: %s", resp.StatusCode, string(respBytes))
+// resolve picks the provider for cfg.Model. A configured gateway takes every
+// request except local models, which never leave the user's network.
+// Otherwise the model must be declared (in cfg.Models or the registry) or
+// qualified with a registered provider ID, e.g. "openai/gpt-4o".
+func (c *Client) resolve(cfg Config) (Provider, Model, error) {
+	model, declared := c.lookupModel(cfg)
+	qualified := false
+	if !declared {
+		if id, name, ok := strings.Cut(cfg.Model, "/"); ok && name != "" {
+			if _, ok := c.registry.Provider(id); ok {
+				model = Model{ID: name, Provider: id, Name: name, Capabilities: chatCaps}
+				qualified = true
+			}
+		}
 	}
 
-	var gwResp gatewayResponse
-	if err := json.Unmarshal(respBytes, &gwResp); err != nil {
-		return "", fmt.Errorf("failed to parse gateway response: %w", err)
+	if cfg.Gateway != nil && model.Provider != LocalProvider {
+		if !declared {
+			// Gateways often use "vendor/model" IDs of their own; send them as-is.
+			model = Model{ID: cfg.Model, Capabilities: chatCaps}
+		}
+		model.Capabilities = cfg.Gateway.Capabilities(model.Capabilities)
+		p, _ := c.registry.Provider(GatewayProvider)
+		return p, model, nil
 	}
 
-	if gwResp.Error != nil {
-		return "", fmt.Errorf("gateway error: %s", gwResp.Error.Message)
+	if !declared && !qualified {
+		return nil, Model{}, fmt.Errorf("model %q is not a recognized provider — set up a gateway to use it", cfg.Model)
 	}
 
-	// OpenAI Chat Completions response
-	if len(gwResp.Choices) > 0 {
-		return gwResp.Choices[0].Message.Content, nil
+	p, ok := c.registry.Provider(model.Provider)
+	if !ok {
+		return nil, Model{}, fmt.Errorf("model %q uses unknown provider %q", cfg.Model, model.Provider)
 	}
+	return p, model, nil
+}
 
-	// Anthropic-style response
-	for _, block := range gwResp.Content {
-		if block.Type == "text" {
-			return block.Text, nil
+// lookupModel finds the declaration for cfg.Model, which may be a bare model
+// ID or one qualified with its provider.
+func (c *Client) lookupModel(cfg Config) (Model, bool) {
+	for _, m := range cfg.Models {
+		if m.matches(cfg.Model) {
+			return m, true
 		}
 	}
-
-	return "", fmt.Errorf("no text content in gateway response: %s", string(respBytes))
+	return c.registry.Model(cfg.Model)
 }
@@ -2,6 +2,10 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 )
@@ -61,13 +65,20 @@ func TestSendValidatesProviderKeys(t *testing.T) {
 	if err == nil || !strings.Contains(err.Error(), "Anthropic API key not set") {
 		t.Fatalf("expected missing Anthropic key error, got: %v", err)
 	}
+
+	_, err = c.Send(context.Background(), Config{
+		Model: "gemini-2.5-flash-lite",
+	}, "prompt", msgs)
+	if err == nil || !strings.Contains(err.Error(), "Gemini API key not set") {
+		t.Fatalf("expected missing Gemini key error, got: %v", err)
+	}
 }
 
 func TestSendGatewayOnlyModelWithoutGateway(t *testing.T) {
 	c := New()
 	msgs := []Message{{Role: "user", Content: "hello"}}
 
-	for _, model := range []string{"gemini-2.5-flash-lite", "grok-4-fast-reasoning"} {
+	for _, model := range []string{"grok-4-fast-reasoning", "mistral-large-latest"} {
 		_, err := c.Send(context.Background(), Config{
 			Model: model,
 		}, "prompt", msgs)
@@ -76,3 +87,148 @@ func TestSendGatewayOnlyModelWithoutGateway(t *testing.T) {
 		}
 	}
 }
+
+func TestSendStreamGatewaySSE(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["stream"] != true {
+			t.Errorf("expected stream=true in request body, got %v (err %v)", body, err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, part := range []string{"Hel", "lo"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", part)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	var deltas []string
+	got, err := New().SendStream(context.Background(), Config{
+		Model:   "gpt-4.1",
+		Gateway: &Gateway{URL: srv.URL},
+	}, "prompt", []Message{{Role: "user", Content: "hi"}}, func(d string) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("SendStream() error = %v", err)
+	}
+	if got != "Hello" || strings.Join(deltas, "|") != "Hel|lo" {
+		t.Fatalf("SendStream() = %q with deltas %q", got, deltas)
+	}
+}
+
+func TestSendStreamGatewayWithoutSSE(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"whole answer"}]}`)
+	}))
+	defer srv.Close()
+
+	var deltas []string
+	got, err := New().SendStream(context.Background(), Config{
+		Model:   "claude-sonnet-4-6",
+		Gateway: &Gateway{URL: srv.URL},
+	}, "prompt", []Message{{Role: "user", Content: "hi"}}, func(d string) {
+		deltas = append(deltas, d)
+	})
+	if err != nil || got != "whole answer" || len(deltas) != 1 {
+		t.Fatalf("SendStream() = %q, %v with deltas %q", got, err, deltas)
+	}
+}
+
+func TestGatewayJoinsTextAfterThinking(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"one "},{"type":"text","text":"two"}]}`)
+	}))
+	defer srv.Close()
+
+	resp, err := New().Complete(context.Background(), Config{
+		Model:   "claude-sonnet-4-6",
+		Gateway: &Gateway{URL: srv.URL},
+	}, "", []Message{{Role: "user", Content: "hi"}}, nil)
+	if err != nil || resp.Text != "one two" || resp.ThinkingText() != "hmm" {
+		t.Fatalf("Complete() = %+v, %v", resp, err)
+	}
+}
+
+func TestCompleteReportsGatewayUsage(t *testing.T) {
+	cases := []struct {
+		name   string
+		model  string
+		events []string
+	}{
+		{
+			name:  "openai",
+			model: "gpt-4.1",
+			events: []string{
+				`{"choices":[{"delta":{"content":"ok"}}]}`,
+				`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5}}`,
+			},
+		},
+		{
+			name:  "anthropic",
+			model: "claude-sonnet-4-6",
+			events: []string{
+				`{"type":"message_start","message":{"usage":{"input_tokens":12,"output_tokens":1}}}`,
+				`{"type":"content_block_delta","delta":{"type":"text_delta","text":"ok"}}`,
+				`{"type":"message_delta","usage":{"output_tokens":5}}`,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				for _, ev := range tc.events {
+					fmt.Fprintf(w, "data: %s\n\n", ev)
+				}
+			}))
+			defer srv.Close()
+
+			resp, err := New().Complete(context.Background(), Config{
+				Model:   tc.model,
+				Gateway: &Gateway{URL: srv.URL},
+			}, "", []Message{{Role: "user", Content: "hi"}}, func(string) {})
+			if err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if resp.Usage != (Usage{InputTokens: 12, OutputTokens: 5}) {
+				t.Fatalf("Usage = %+v, want 12 in / 5 out", resp.Usage)
+			}
+			if resp.Provider != GatewayProvider || resp.Model.ID != tc.model {
+				t.Fatalf("answered by %s/%s, want gateway/%s", resp.Provider, resp.Model.ID, tc.model)
+			}
+		})
+	}
+}
+
+func TestGatewayPromptCache(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":10,"output_tokens":2,"cache_read_input_tokens":900}}`)
+	}))
+	defer srv.Close()
+
+	system := []SystemBlock{{Text: "transcript", Cache: true}, {Text: " latest"}}
+	cfg := Config{Model: "claude-sonnet-4-6", Gateway: &Gateway{URL: srv.URL}}
+	if _, err := New().CompleteBlocks(context.Background(), cfg, system, []Message{{Role: "user", Content: "hi"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if body["system"] != "transcript latest" {
+		t.Fatalf("system = %v, want plain text without PromptCache", body["system"])
+	}
+
+	cfg.Gateway.PromptCache = true
+	resp, err := New().CompleteBlocks(context.Background(), cfg, system, []Message{{Role: "user", Content: "hi"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := body["system"].([]any)
+	if len(parts) != 2 || parts[0].(map[string]any)["cache_control"] == nil || parts[1].(map[string]any)["cache_control"] != nil {
+		t.Fatalf("system = %v, want a cache hint on the stable block only", parts)
+	}
+	if resp.Usage.CacheReadTokens != 900 || resp.Usage.InputTokens != 10 {
+		t.Fatalf("Usage = %+v", resp.Usage)
+	}
+}
@@ -0,0 +1,92 @@
+package ai
+
+import (
+	"encoding/base64"
+	"unicode/utf8"
+)
+
+// Context windows assumed for models that do not declare one. Local servers
+// are often run with a small context, so they get a cautious default; a
+// model declared in config.json with "contextWindow" overrides both.
+const (
+	defaultContextWindow      = 128_000
+	defaultLocalContextWindow = 8192
+)
+
+// Rough per-item costs for attachments, which the estimator cannot measure
+// without the provider's tokenizer.
+const (
+	imageTokens       = 1600 // an image fitted to Anthropic's limits, the largest of the providers
+	pdfBytesPerToken  = 12   // PDFs are compressed and mostly images for all we know
+	messageOverhead   = 4    // role markers and separators
+	contextSafetyPart = 20   // keep 1/20 of the window free for estimation error
+)
+
+// EstimateTokens approximates the number of tokens in s. English averages
+// about four characters per token; other scripts are closer to one token
+// per character, so those are counted in full.
+func EstimateTokens(s string) int {
+	ascii, other := 0, 0
+	for i := 0; i < len(s); {
+		if s[i] < utf8.RuneSelf {
+			ascii++
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		other++
+		i += size
+	}
+	return (ascii+3)/4 + other
+}
+
+// EstimateMessages approximates the tokens messages take up in a request.
+func EstimateMessages(messages []Message) int {
+	n := 0
+	for _, m := range messages {
+		n += messageOverhead + EstimateTokens(m.Content)
+		n += len(m.Images) * imageTokens
+		for _, doc := range m.Documents {
+			if doc.isPDF() {
+				n += base64.StdEncoding.DecodedLen(len(doc.Data)) / pdfBytesPerToken
+			} else {
+				n += EstimateTokens(doc.text())
+			}
+		}
+		for _, call := range m.ToolCalls {
+			n += EstimateTokens(call.Name) + EstimateTokens(string(call.Arguments))
+		}
+		for _, t := range m.Thinking {
+			n += EstimateTokens(t.Text)
+		}
+	}
+	return n
+}
+
+// contextWindow returns the model's context window in tokens.
+func (m Model) contextWindow() int {
+	switch {
+	case m.ContextWindow > 0:
+		return m.ContextWindow
+	case m.Provider == LocalProvider:
+		return defaultLocalContextWindow
+	}
+	return defaultContextWindow
+}
+
+// InputBudget returns how many tokens of prompt cfg.Model can take: its
+// context window less the room reserved for the answer (and reasoning) and
+// a safety margin for estimation error.
+func (c *Client) InputBudget(cfg Config) (int, error) {
+	_, model, err := c.resolve(cfg)
+	if err != nil {
+		return 0, err
+	}
+	window := model.contextWindow()
+	p := cfg.params(model)
+	reserve := p.maxTokens(defaultMaxTokens) + p.thinkingBudget()
+	// An output limit close to the window (say a 64k default on a 128k
+	// model) would leave almost nothing; cap the reservation at half.
+	reserve = min(reserve, window/2)
+	return window - reserve - window/contextSafetyPart, nil
+}
@@ -0,0 +1,39 @@
+package ai
+
+import "testing"
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens("The budget review is on Friday."); got != 8 {
+		t.Fatalf("English: got %d, want 8", got)
+	}
+	if got := EstimateTokens("予算の見直しは金曜日"); got != 10 {
+		t.Fatalf("Japanese: got %d, want one per character", got)
+	}
+	msgs := []Message{{Role: "user", Content: "look", Images: []string{"x"}}}
+	if got := EstimateMessages(msgs); got != messageOverhead+1+imageTokens {
+		t.Fatalf("EstimateMessages() = %d", got)
+	}
+}
+
+func TestInputBudget(t *testing.T) {
+	c := New()
+	got, err := c.InputBudget(Config{Model: "claude-sonnet-4-6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 200_000 - defaultMaxTokens - 200_000/contextSafetyPart; got != want {
+		t.Fatalf("Claude budget = %d, want %d", got, want)
+	}
+
+	cfg := Config{Model: "local/llama3.2", Models: []Model{{ID: "llama3.2", Provider: LocalProvider}}}
+	got, _ = c.InputBudget(cfg)
+	if want := defaultLocalContextWindow/2 - defaultLocalContextWindow/contextSafetyPart; got != want {
+		t.Fatalf("local budget = %d, want %d with the output reserve capped at half", got, want)
+	}
+
+	cfg.Models[0].ContextWindow = 32_000
+	cfg.Params = map[string]Params{"llama3.2": {MaxTokens: 1000}}
+	if got, _ = c.InputBudget(cfg); got != 32_000-1000-1600 {
+		t.Fatalf("declared window: budget = %d", got)
+	}
+}
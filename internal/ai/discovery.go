@@ -0,0 +1,288 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ModelLister is implemented by providers that can list the models the
+// configured account has access to.
+type ModelLister interface {
+	ListModels(ctx context.Context, cfg Config) ([]Model, error)
+}
+
+// ListModels asks the provider registered as providerID for its models.
+// For the gateway, cfg.Gateway.ModelsURL must be set.
+func (c *Client) ListModels(ctx context.Context, cfg Config, providerID string) ([]Model, error) {
+	p, ok := c.registry.Provider(providerID)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", providerID)
+	}
+	lister, ok := p.(ModelLister)
+	if !ok {
+		return nil, fmt.Errorf("provider %q cannot list its models", providerID)
+	}
+	return lister.ListModels(ctx, cfg)
+}
+
+// MergeModels adds the models in found to known. A found model that is
+// already known keeps the known declaration, which is curated, and only
+// contributes a context window or output limit the declaration lacks.
+func MergeModels(known, found []Model) []Model {
+	out := slices.Clone(known)
+	for _, m := range found {
+		i := slices.IndexFunc(out, func(k Model) bool { return k.ID == m.ID && k.Provider == m.Provider })
+		if i < 0 {
+			out = append(out, m)
+			continue
+		}
+		if out[i].ContextWindow == 0 {
+			out[i].ContextWindow = m.ContextWindow
+		}
+		if out[i].MaxOutput == 0 {
+			out[i].MaxOutput = m.MaxOutput
+		}
+	}
+	return out
+}
+
+// ListModels lists the Claude models available to the API key. The API
+// reports no capabilities, so they are inferred from the model family.
+func (anthropicProvider) ListModels(ctx context.Context, cfg Config) ([]Model, error) {
+	if cfg.AnthropicKey == "" {
+		return nil, fmt.Errorf("Anthropic API key not set")
+	}
+	client := anthropic.NewClient(option.WithAPIKey(cfg.AnthropicKey), option.WithMaxRetries(0), option.WithHTTPClient(cfg.httpClient()))
+	var models []Model
+	pager := client.Models.ListAutoPaging(ctx, anthropic.ModelListParams{Limit: anthropic.Int(1000)})
+	for pager.Next() {
+		info := pager.Current()
+		caps := reasoningCaps
+		// Extended thinking arrived with Claude 3.7.
+		if strings.HasPrefix(info.ID, "claude-3-") && !strings.HasPrefix(info.ID, "claude-3-7") {
+			caps = toolCaps
+		}
+		models = append(models, Model{ID: info.ID, Provider: AnthropicProvider, Name: info.DisplayName, Capabilities: caps})
+	}
+	if err := pager.Err(); err != nil {
+		return nil, fmt.Errorf("list Anthropic models: %w", err)
+	}
+	return models, nil
+}
+
+// openAINonChat marks model IDs in OpenAI's list that the chat cannot use.
+var openAINonChat = []string{"audio", "realtime", "transcribe", "tts", "image", "search", "instruct", "embedding", "codex"}
+
+// ListModels lists the OpenAI chat models available to the API key. The
+// list covers every kind of model and says nothing about capabilities, so
+// chat models are picked out by name and their capabilities inferred.
+func (openAIProvider) ListModels(ctx context.Context, cfg Config) ([]Model, error) {
+	if cfg.OpenAIKey == "" {
+		return nil, fmt.Errorf("OpenAI API key not set")
+	}
+	ocfg := openai.DefaultConfig(cfg.OpenAIKey)
+	ocfg.HTTPClient = cfg.httpClient()
+	list, err := openai.NewClientWithConfig(ocfg).ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list OpenAI models: %w", err)
+	}
+	var models []Model
+	for _, m := range list.Models {
+		id := m.ID
+		if !IsOpenAIModel(id) && !strings.HasPrefix(id, "chatgpt-") {
+			continue
+		}
+		if slices.ContainsFunc(openAINonChat, func(s string) bool { return strings.Contains(id, s) }) {
+			continue
+		}
+		caps := toolCaps
+		switch {
+		case strings.HasPrefix(id, "gpt-3.5"):
+			caps = Capabilities{Streaming: true, Tools: true}
+		case isOpenAIReasoningModel(id):
+			caps = reasoningCaps
+		}
+		models = append(models, Model{ID: id, Provider: OpenAIProvider, Name: id, Capabilities: caps})
+	}
+	return models, nil
+}
+
+// ListModels lists the Gemini models that can generate content, with
+// their context window and whether they think.
+func (p geminiProvider) ListModels(ctx context.Context, cfg Config) ([]Model, error) {
+	if cfg.GeminiKey == "" {
+		return nil, fmt.Errorf("Gemini API key not set")
+	}
+	base := p.baseURL
+	if base == "" {
+		base = geminiBaseURL
+	}
+	var models []Model
+	pageToken := ""
+	for {
+		var page struct {
+			Models []struct {
+				Name                       string   `json:"name"` // "models/gemini-2.5-flash"
+				DisplayName                string   `json:"displayName"`
+				InputTokenLimit            int      `json:"inputTokenLimit"`
+				SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+				Thinking                   bool     `json:"thinking"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		q := url.Values{"key": {cfg.GeminiKey}, "pageSize": {"1000"}}
+		if pageToken != "" {
+			q.Set("pageToken", pageToken)
+		}
+		if err := getJSON(ctx, cfg.httpClient(), base+"/models?"+q.Encode(), &page); err != nil {
+			return nil, fmt.Errorf("list Gemini models: %w", redactKey(err, cfg.GeminiKey))
+		}
+		for _, m := range page.Models {
+			id := strings.TrimPrefix(m.Name, "models/")
+			if !strings.HasPrefix(id, "gemini-") || !slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+				continue
+			}
+			caps := chatCaps
+			caps.Reasoning = m.Thinking
+			models = append(models, Model{
+				ID:            id,
+				Provider:      GeminiProvider,
+				Name:          m.DisplayName,
+				Capabilities:  caps,
+				ContextWindow: m.InputTokenLimit,
+			})
+		}
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// redactKey keeps an API key sent in the query string out of error messages.
+func redactKey(err error, key string) error {
+	if key == "" || !strings.Contains(err.Error(), key) {
+		return err
+	}
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), key, "…"))
+}
+
+// gatewayModel is an entry of a gateway's OpenAI-style model list. Gateways
+// add metadata of their own; the LiteLLM and OpenRouter fields are read.
+type gatewayModel struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+
+	ContextLength  int `json:"context_length"` // OpenRouter
+	ContextWindow  int `json:"context_window"`
+	MaxInputTokens int `json:"max_input_tokens"` // LiteLLM
+
+	MaxOutputTokens int `json:"max_output_tokens"` // LiteLLM
+
+	SupportsVision          bool `json:"supports_vision"` // LiteLLM
+	SupportsPDFInput        bool `json:"supports_pdf_input"`
+	SupportsFunctionCalling bool `json:"supports_function_calling"`
+	SupportsReasoning       bool `json:"supports_reasoning"`
+
+	Architecture struct {
+		InputModalities []string `json:"input_modalities"` // OpenRouter
+	} `json:"architecture"`
+	TopProvider struct {
+		MaxCompletionTokens int `json:"max_completion_tokens"` // OpenRouter
+	} `json:"top_provider"`
+	SupportedParameters []string `json:"supported_parameters"` // OpenRouter
+}
+
+func (m gatewayModel) model() Model {
+	described := m.ContextLength+m.ContextWindow+m.MaxInputTokens > 0 || m.SupportsVision || m.SupportsFunctionCalling ||
+		len(m.Architecture.InputModalities) > 0 || len(m.SupportedParameters) > 0
+	// Without metadata the model is assumed to chat with images, as it is
+	// when selected without being listed.
+	caps := chatCaps
+	if described {
+		caps = Capabilities{
+			Vision:    m.SupportsVision || slices.Contains(m.Architecture.InputModalities, "image"),
+			Documents: m.SupportsPDFInput || slices.Contains(m.Architecture.InputModalities, "file"),
+			Streaming: true,
+			Tools:     m.SupportsFunctionCalling || slices.Contains(m.SupportedParameters, "tools"),
+			Reasoning: m.SupportsReasoning || slices.Contains(m.SupportedParameters, "reasoning"),
+		}
+	}
+	name := m.DisplayName
+	if name == "" {
+		name = m.Name
+	}
+	if name == "" {
+		name = m.ID
+	}
+	return Model{
+		ID:            m.ID,
+		Provider:      GatewayProvider,
+		Name:          name,
+		Capabilities:  caps,
+		ContextWindow: max(m.ContextLength, m.ContextWindow, m.MaxInputTokens),
+		MaxOutput:     max(m.MaxOutputTokens, m.TopProvider.MaxCompletionTokens),
+	}
+}
+
+// ListModels fetches cfg.Gateway.ModelsURL with the gateway's credentials.
+func (p *gatewayProvider) ListModels(ctx context.Context, cfg Config) ([]Model, error) {
+	if cfg.Gateway == nil || cfg.Gateway.ModelsURL == "" {
+		return nil, fmt.Errorf("the gateway has no models URL")
+	}
+	body, err := p.get(ctx, cfg, cfg.Gateway.ModelsURL, false)
+	if err != nil {
+		return nil, fmt.Errorf("list gateway models: %w", err)
+	}
+	var list struct {
+		Data []gatewayModel `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("list gateway models: %w", err)
+	}
+	models := make([]Model, 0, len(list.Data))
+	for _, m := range list.Data {
+		if m.ID != "" {
+			models = append(models, m.model())
+		}
+	}
+	return models, nil
+}
+
+// get fetches endpoint from the gateway, logging in again once on 401.
+func (p *gatewayProvider) get(ctx context.Context, cfg Config, endpoint string, refresh bool) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.authorize(ctx, req, cfg, refresh); err != nil {
+		return nil, err
+	}
+	resp, err := cfg.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && !refresh && cfg.Gateway.Auth.refreshable() {
+		return p.get(ctx, cfg, endpoint, true)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d: %s", endpoint, resp.StatusCode, string(body))
+	}
+	return body, nil
+}
@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// routeTransport answers each request with the reply registered for its
+// path. The host is ignored, as ANTHROPIC_BASE_URL may redirect the SDK.
+type routeTransport map[string]string
+
+func (rt routeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	reply, ok := rt[r.URL.Path]
+	status := http.StatusOK
+	if !ok {
+		status, reply = http.StatusNotFound, `{"error":{"message":"not found"}}`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(reply)),
+		Request:    r,
+	}, nil
+}
+
+func TestListModelsInfersCapabilities(t *testing.T) {
+	anthropicModels := &http.Client{Transport: routeTransport{
+		"/v1/models": `{"data":[
+			{"id":"claude-opus-5","display_name":"Claude Opus 5","created_at":"2026-01-01T00:00:00Z","type":"model"},
+			{"id":"claude-3-5-haiku-20241022","display_name":"Claude Haiku 3.5","created_at":"2024-10-22T00:00:00Z","type":"model"}],
+			"has_more":false}`,
+	}}
+	openAIModels := &http.Client{Transport: routeTransport{
+		"/v1/models": `{"object":"list","data":[
+			{"id":"gpt-4.1","object":"model"},{"id":"o3-mini","object":"model"},
+			{"id":"text-embedding-3-small","object":"model"},{"id":"gpt-4o-realtime-preview","object":"model"}]}`,
+	}}
+	c := New()
+
+	claude, err := c.ListModels(context.Background(), Config{AnthropicKey: "a", HTTPClient: anthropicModels}, AnthropicProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claude) != 2 || claude[0].Name != "Claude Opus 5" || !claude[0].Capabilities.Reasoning || claude[1].Capabilities.Reasoning {
+		t.Fatalf("Anthropic models = %+v", claude)
+	}
+
+	gpt, err := c.ListModels(context.Background(), Config{OpenAIKey: "o", HTTPClient: openAIModels}, OpenAIProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gpt) != 2 || gpt[0].ID != "gpt-4.1" || gpt[0].Capabilities.Reasoning || !gpt[1].Capabilities.Reasoning {
+		t.Fatalf("OpenAI models = %+v", gpt)
+	}
+}
+
+func TestListGeminiModelsReadsLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "g" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"models":[
+			{"name":"models/gemini-3-pro","displayName":"Gemini 3 Pro","inputTokenLimit":2097152,"supportedGenerationMethods":["generateContent"],"thinking":true},
+			{"name":"models/text-embedding-004","supportedGenerationMethods":["embedContent"]}]}`)
+	}))
+	defer srv.Close()
+
+	models, err := geminiProvider{baseURL: srv.URL}.ListModels(context.Background(), Config{GeminiKey: "g"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].ContextWindow != 2097152 || !models[0].Capabilities.Reasoning {
+		t.Fatalf("models = %+v", models)
+	}
+}
+
+func TestListGatewayModelsReadsMetadata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"data":[
+			{"id":"acme/big","name":"Acme Big","context_length":300000,"architecture":{"input_modalities":["text"]},"supported_parameters":["tools"]},
+			{"id":"plain"}]}`)
+	}))
+	defer srv.Close()
+
+	models, err := New().ListModels(context.Background(), Config{
+		Gateway: &Gateway{URL: srv.URL, ModelsURL: srv.URL + "/models", Auth: &GatewayAuth{Type: AuthBearer, Token: "t"}},
+	}, GatewayProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := models[0]
+	if big.Name != "Acme Big" || big.ContextWindow != 300000 || big.Capabilities.Vision || !big.Capabilities.Tools {
+		t.Fatalf("acme/big = %+v", big)
+	}
+	if plain := models[1]; plain.Capabilities != chatCaps || plain.Provider != GatewayProvider {
+		t.Fatalf("plain = %+v", plain)
+	}
+}
+
+func TestMergeModelsKeepsKnownDeclarations(t *testing.T) {
+	known := []Model{{ID: "a", Provider: "p", Name: "A", Capabilities: toolCaps}}
+	found := []Model{
+		{ID: "a", Provider: "p", Name: "a", Capabilities: chatCaps, ContextWindow: 1000},
+		{ID: "b", Provider: "p", Name: "B"},
+	}
+	got := MergeModels(known, found)
+	if len(got) != 2 || got[0].Name != "A" || got[0].Capabilities != toolCaps || got[0].ContextWindow != 1000 || got[1].ID != "b" {
+		t.Fatalf("MergeModels() = %+v", got)
+	}
+	if known[0].ContextWindow != 0 {
+		t.Fatal("MergeModels modified known")
+	}
+}
@@ -0,0 +1,244 @@
+package ai
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ErrorKind tells rate limits, auth failures and outages apart so the UI can
+// react to each differently.
+type ErrorKind string
+
+const (
+	ErrorRateLimit  ErrorKind = "rate_limit"      // 429
+	ErrorOverloaded ErrorKind = "overloaded"      // 503, Anthropic 529
+	ErrorServer     ErrorKind = "server"          // other 5xx
+	ErrorTimeout    ErrorKind = "timeout"         // request or connection timed out
+	ErrorNetwork    ErrorKind = "network"         // connection refused, reset, DNS…
+	ErrorTLS        ErrorKind = "tls"             // certificate not trusted or invalid
+	ErrorAuth       ErrorKind = "auth"            // 401, 403
+	ErrorInvalid    ErrorKind = "invalid_request" // other 4xx
+	ErrorUnknown    ErrorKind = "unknown"
+)
+
+// APIError is a provider failure classified by kind. It wraps the provider's
+// own error, so Error() keeps the original message.
+type APIError struct {
+	Kind       ErrorKind
+	Provider   string
+	StatusCode int           // 0 when no HTTP response was received
+	RetryAfter time.Duration // from the Retry-After header, if any
+	Err        error
+}
+
+func (e *APIError) Error() string { return e.Err.Error() }
+func (e *APIError) Unwrap() error { return e.Err }
+
+// Retryable reports whether sending the same request again may succeed.
+func (e *APIError) Retryable() bool {
+	switch e.Kind {
+	case ErrorRateLimit, ErrorOverloaded, ErrorServer, ErrorTimeout, ErrorNetwork:
+		return true
+	}
+	return false
+}
+
+// ErrorKindOf returns the kind of a classified error, or ErrorUnknown.
+func ErrorKindOf(err error) ErrorKind {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind
+	}
+	return ErrorUnknown
+}
+
+// classifyError wraps err in an *APIError based on the SDK error types,
+// HTTP status and network failures it carries. Cancellation is returned
+// unchanged.
+func classifyError(provider string, err error) error {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return err
+	}
+
+	out := &APIError{Kind: ErrorUnknown, Provider: provider, Err: err}
+	var after retryAfterError
+	if errors.As(err, &after) {
+		out.RetryAfter = after.after
+	}
+
+	var anthErr *anthropic.Error
+	var oaiErr *openai.APIError
+	var oaiReqErr *openai.RequestError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &anthErr):
+		out.StatusCode = anthErr.StatusCode
+		if anthErr.Response != nil {
+			out.RetryAfter = parseRetryAfter(anthErr.Response.Header.Get("Retry-After"))
+		}
+	case errors.As(err, &oaiErr):
+		out.StatusCode = oaiErr.HTTPStatusCode
+	case errors.As(err, &oaiReqErr):
+		out.StatusCode = oaiReqErr.HTTPStatusCode
+	case errors.As(err, &certErr), errors.As(err, &authorityErr), errors.As(err, &hostErr), errors.As(err, &invalidErr):
+		// Checked before net.Error: retrying won't make a certificate valid.
+		out.Kind = ErrorTLS
+		return out
+	case errors.Is(err, context.DeadlineExceeded):
+		out.Kind = ErrorTimeout
+		return out
+	case errors.As(err, &netErr):
+		out.Kind = ErrorNetwork
+		if netErr.Timeout() {
+			out.Kind = ErrorTimeout
+		}
+		return out
+	}
+
+	out.Kind = kindForStatus(out.StatusCode)
+	if out.Kind == ErrorUnknown {
+		// Errors sent inside an already-open stream carry no status code.
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "overloaded_error"):
+			out.Kind = ErrorOverloaded
+		case strings.Contains(msg, "rate_limit_error"):
+			out.Kind = ErrorRateLimit
+		}
+	}
+	return out
+}
+
+func kindForStatus(status int) ErrorKind {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrorRateLimit
+	case status == http.StatusServiceUnavailable || status == 529:
+		return ErrorOverloaded
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrorTimeout
+	case status >= 500:
+		return ErrorServer
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorAuth
+	case status >= 400:
+		return ErrorInvalid
+	}
+	return ErrorUnknown
+}
+
+// httpError builds the error for a non-200 response from a provider we call
+// over plain HTTP (gateway, Gemini).
+func httpError(provider string, resp *http.Response, msg string) error {
+	return &APIError{
+		Kind:       kindForStatus(resp.StatusCode),
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		Err:        errors.New(msg),
+	}
+}
+
+// retryAfterError carries the Retry-After header of a failed response
+// through an SDK error that leaves it out, see retryAfterTransport.
+type retryAfterError struct {
+	error
+	after time.Duration
+}
+
+func (e retryAfterError) Unwrap() error { return e.error }
+
+// retryAfterTransport remembers the Retry-After header of the last failed
+// response. go-openai keeps only the status code of an error response, so
+// without it rate limits would be retried blind.
+type retryAfterTransport struct {
+	base  http.RoundTripper // nil means http.DefaultTransport
+	after atomic.Int64
+}
+
+// recordRetryAfter returns a copy of c whose failed responses report their
+// Retry-After header to the returned transport.
+func recordRetryAfter(c *http.Client) (*http.Client, *retryAfterTransport) {
+	t := &retryAfterTransport{base: c.Transport}
+	withHeader := *c
+	withHeader.Transport = t
+	return &withHeader, t
+}
+
+func (t *retryAfterTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(r)
+	if err == nil && resp.StatusCode >= 400 {
+		t.after.Store(int64(parseRetryAfter(resp.Header.Get("Retry-After"))))
+	}
+	return resp, err
+}
+
+// annotate adds the recorded Retry-After to err, if there is one.
+func (t *retryAfterTransport) annotate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if d := time.Duration(t.after.Load()); d > 0 {
+		return retryAfterError{err, d}
+	}
+	return err
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// friendly describes an error kind in a few words for progress notices.
+func (k ErrorKind) friendly() string {
+	switch k {
+	case ErrorRateLimit:
+		return "Rate limited"
+	case ErrorOverloaded:
+		return "Provider overloaded"
+	case ErrorServer:
+		return "Provider error"
+	case ErrorTimeout:
+		return "Request timed out"
+	case ErrorNetwork:
+		return "Network error"
+	}
+	return fmt.Sprintf("Request failed (%s)", k)
+}
@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// maxExtractAttempts is how many answers Extract accepts before giving up
+// on one that matches the schema.
+const maxExtractAttempts = 3
+
+// ErrInvalidOutput is returned by Extract when no answer matched the schema.
+var ErrInvalidOutput = errors.New("the model did not return valid output")
+
+// schemaSender is implemented by providers that constrain the answer to
+// Request.Schema themselves. Others are given the schema in the prompt.
+type schemaSender interface {
+	sendsSchema()
+}
+
+func (anthropicProvider) sendsSchema() {} // forced tool call
+func (openAIProvider) sendsSchema()    {} // response_format json_schema
+func (localProvider) sendsSchema()     {} // response_format json_schema
+
+// Extract asks cfg.Model for a JSON document matching schema instead of
+// prose. Anthropic models are made to call a tool whose input is the
+// schema, OpenAI-compatible servers get a json_schema response format and
+// other providers the schema in the prompt. Answers are validated, and one
+// that does not match is sent back with the problem for another try.
+//
+// The returned Response holds the document in Text and the usage of every
+// attempt. When no attempt matched, the error wraps ErrInvalidOutput. If
+// Extract fails after attempts that were billed, a Response carrying their
+// usage is returned along with the error so it can be recorded.
+func (c *Client) Extract(ctx context.Context, cfg Config, schema Schema, messages []Message) (*Response, error) {
+	cfg.schema = &schema
+	cfg.Tools, cfg.OnThinking = nil, nil
+	messages = slices.Clone(messages)
+
+	var usage Usage
+	var last *Response
+	var invalid error
+	for attempt := 1; attempt <= maxExtractAttempts; attempt++ {
+		resp, err := c.CompleteBlocks(ctx, cfg, nil, messages, nil)
+		if err != nil {
+			if last == nil {
+				return nil, err
+			}
+			return &Response{Usage: usage, Model: last.Model, Provider: last.Provider}, err
+		}
+		last = resp
+		usage = usage.add(resp.Usage)
+		cfg = cfg.FromFallback(resp)
+
+		doc := extractedJSON(resp, schema)
+		resp.Usage = usage
+		if invalid = schema.Validate([]byte(doc)); invalid == nil {
+			resp.Text, resp.ToolCalls = doc, nil
+			return resp, nil
+		}
+		messages = append(messages,
+			Message{Role: "assistant", Content: doc},
+			Message{Role: "user", Content: fmt.Sprintf("That does not match the schema: %v. Reply with the corrected JSON document only.", invalid)})
+	}
+	return last, fmt.Errorf("%w after %d attempts: %v", ErrInvalidOutput, maxExtractAttempts, invalid)
+}
+
+// extractedJSON picks the document out of an answer: the input of the
+// forced tool call, or the text without any code fence around it.
+func extractedJSON(resp *Response, schema Schema) string {
+	for _, call := range resp.ToolCalls {
+		if call.Name == schema.Name {
+			return call.Arguments
+		}
+	}
+	text := strings.TrimSpace(resp.Text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+	return strings.TrimSpace(text)
+}
+
+// schemaPrompt asks for the document in words, for providers that cannot
+// be given the schema otherwise.
+func schemaPrompt(schema Schema) string {
+	data, _ := json.MarshalIndent(schema.JSON, "", "  ")
+	var sb strings.Builder
+	sb.WriteString("Reply with a single JSON document and nothing else — no prose, no code fence.")
+	if schema.Description != "" {
+		sb.WriteString(" It is " + strings.TrimSuffix(schema.Description, ".") + ".")
+	}
+	sb.WriteString(" It must match this JSON Schema:\n\n" + string(data))
+	return sb.String()
+}
+
+// add returns the sum of two usages.
+func (u Usage) add(v Usage) Usage {
+	return Usage{
+		InputTokens:      u.InputTokens + v.InputTokens,
+		OutputTokens:     u.OutputTokens + v.OutputTokens,
+		CacheReadTokens:  u.CacheReadTokens + v.CacheReadTokens,
+		CacheWriteTokens: u.CacheWriteTokens + v.CacheWriteTokens,
+	}
+}
@@ -0,0 +1,156 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var decisionsSchema = Schema{
+	Name:        "decisions",
+	Description: "the decisions made in the meeting",
+	Strict:      true,
+	JSON: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"decisions": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"decision": map[string]any{"type": "string"},
+						"owner":    map[string]any{"type": []string{"string", "null"}},
+						"status":   map[string]any{"type": "string", "enum": []string{"final", "tentative"}},
+					},
+					"required":             []string{"decision", "owner", "status"},
+					"additionalProperties": false,
+				},
+			},
+		},
+		"required":             []string{"decisions"},
+		"additionalProperties": false,
+	},
+}
+
+func TestSchemaValidate(t *testing.T) {
+	for _, tc := range []struct {
+		doc  string
+		want string // substring of the error, "" for valid
+	}{
+		{`{"decisions":[{"decision":"Ship Friday","owner":null,"status":"final"}]}`, ""},
+		{`{"decisions":[]}`, ""},
+		{`{"decisions":[{"decision":"Ship","owner":"Ana"}]}`, `$.decisions[0] is missing "status"`},
+		{`{"decisions":[{"decision":"Ship","owner":7,"status":"final"}]}`, `$.decisions[0].owner is a number, want string or null`},
+		{`{"decisions":[{"decision":"Ship","owner":null,"status":"maybe"}]}`, "not one of the allowed values"},
+		{`{"decisions":[],"extra":1}`, `unexpected property "extra"`},
+		{`{"decisions":[`, "not valid JSON"},
+	} {
+		err := decisionsSchema.Validate([]byte(tc.doc))
+		if tc.want == "" && err != nil || tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("Validate(%s) = %v, want %q", tc.doc, err, tc.want)
+		}
+	}
+}
+
+func TestExtractUsesOpenAIResponseFormat(t *testing.T) {
+	tr := &captureTransport{reply: `{"choices":[{"message":{"content":"{\"decisions\":[]}"}}],"usage":{"prompt_tokens":10,"completion_tokens":3}}`}
+	resp, err := New().Extract(context.Background(), Config{OpenAIKey: "k", Model: "gpt-4.1", HTTPClient: &http.Client{Transport: tr}},
+		decisionsSchema, []Message{{Role: "user", Content: "transcript"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != `{"decisions":[]}` {
+		t.Fatalf("Text = %q", resp.Text)
+	}
+	format, _ := tr.body["response_format"].(map[string]any)
+	schema, _ := format["json_schema"].(map[string]any)
+	if format["type"] != "json_schema" || schema["name"] != "decisions" || schema["strict"] != true || schema["schema"] == nil {
+		t.Fatalf("response_format = %v", tr.body["response_format"])
+	}
+	if _, ok := tr.body["tools"]; ok {
+		t.Fatal("chat tools should not be offered while extracting")
+	}
+}
+
+func TestExtractForcesAnthropicTool(t *testing.T) {
+	tr := &captureTransport{reply: anthropicSSE(
+		`{"type":"message_start","message":{"id":"msg","type":"message","role":"assistant","content":[],"usage":{"input_tokens":9}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"tu","name":"decisions","input":{}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"decisions\":[{\"decision\":\"Ship\","}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"owner\":\"Ana\",\"status\":\"final\"}]}"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`,
+		`{"type":"message_stop"}`,
+	)}
+	cfg := Config{
+		AnthropicKey: "k",
+		Model:        "claude-sonnet-4-6",
+		HTTPClient:   &http.Client{Transport: tr},
+		Params:       map[string]Params{"claude-sonnet-4-6": {ThinkingBudget: 4000}},
+	}
+	resp, err := New().Extract(context.Background(), cfg, decisionsSchema, []Message{{Role: "user", Content: "transcript"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Decisions []struct{ Owner string }
+	}
+	if err := json.Unmarshal([]byte(resp.Text), &doc); err != nil || len(doc.Decisions) != 1 || doc.Decisions[0].Owner != "Ana" {
+		t.Fatalf("Text = %q (%v)", resp.Text, err)
+	}
+	choice, _ := tr.body["tool_choice"].(map[string]any)
+	if choice["type"] != "tool" || choice["name"] != "decisions" {
+		t.Fatalf("tool_choice = %v", tr.body["tool_choice"])
+	}
+	if _, ok := tr.body["thinking"]; ok {
+		t.Fatal("thinking cannot be combined with a forced tool call")
+	}
+}
+
+func TestExtractRetriesInvalidOutput(t *testing.T) {
+	var systems []string
+	replies := []string{"Sure! Here are the decisions.", "```json\n{\"decisions\":[]}\n```"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			System any `json:"system"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		systems = append(systems, fmt.Sprint(body.System))
+		reply, _ := json.Marshal(replies[len(systems)-1])
+		fmt.Fprintf(w, `{"choices":[{"message":{"content":%s}}],"usage":{"prompt_tokens":5,"completion_tokens":1}}`, reply)
+	}))
+	defer srv.Close()
+
+	resp, err := New().Extract(context.Background(), Config{Model: "claude-sonnet-4-6", Gateway: &Gateway{URL: srv.URL}, Retry: fastRetry},
+		decisionsSchema, []Message{{Role: "user", Content: "transcript"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != `{"decisions":[]}` || len(systems) != 2 || resp.Usage.InputTokens != 10 {
+		t.Fatalf("Text = %q after %d calls, usage %+v", resp.Text, len(systems), resp.Usage)
+	}
+	if !strings.Contains(systems[0], "JSON Schema") {
+		t.Fatalf("gateway requests should carry the schema in the prompt, got %q", systems[0])
+	}
+}
+
+func TestExtractGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"no"}}],"usage":{"prompt_tokens":5,"completion_tokens":1}}`)
+	}))
+	defer srv.Close()
+
+	resp, err := New().Extract(context.Background(), Config{Model: "gpt-4.1", Gateway: &Gateway{URL: srv.URL}, Retry: fastRetry},
+		decisionsSchema, []Message{{Role: "user", Content: "transcript"}})
+	if !errors.Is(err, ErrInvalidOutput) {
+		t.Fatalf("err = %v, want ErrInvalidOutput", err)
+	}
+	if resp == nil || resp.Usage.InputTokens != 5*maxExtractAttempts {
+		t.Fatalf("resp = %+v, want the usage of every attempt", resp)
+	}
+}
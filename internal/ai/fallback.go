@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Fallback is a model to try when the ones before it in the chain fail.
+type Fallback struct {
+	Model   string   // as in Config.Model
+	Gateway *Gateway // route this attempt through a gateway; nil calls the provider directly
+}
+
+// DefaultFallbackOn is the set of failures that move on to the next model
+// when Config.FallbackOn is empty: outages, not mistakes in the request.
+var DefaultFallbackOn = []ErrorKind{ErrorOverloaded, ErrorServer, ErrorTimeout, ErrorNetwork}
+
+// FallbackNotice describes a switch to the next model in the chain.
+type FallbackNotice struct {
+	From, To               string // model IDs as configured
+	FromGateway, ToGateway string // names of the gateways they go through, if any
+	Err                    error  // why From failed
+}
+
+// Message is a short human-readable progress line, e.g.
+// "Provider overloaded — switching to gpt-4.1…". When only the gateway
+// changes it names the gateway instead.
+func (n FallbackNotice) Message() string {
+	to := n.To
+	if n.From == n.To && n.ToGateway != "" && n.ToGateway != n.FromGateway {
+		to = n.ToGateway
+	}
+	return fmt.Sprintf("%s — switching to %s…", ErrorKindOf(n.Err).friendly(), to)
+}
+
+// gatewayName returns the name of gw, or "" without a gateway.
+func gatewayName(gw *Gateway) string {
+	if gw == nil {
+		return ""
+	}
+	return gw.Name
+}
+
+// attempt returns cfg set up for link i of the chain: 0 is cfg.Model itself,
+// i > 0 is cfg.Fallbacks[i-1].
+func (cfg Config) attempt(i int) Config {
+	if i == 0 {
+		return cfg
+	}
+	f := cfg.Fallbacks[i-1]
+	cfg.Model, cfg.Gateway = f.Model, f.Gateway
+	return cfg
+}
+
+// FromFallback returns cfg with the model that answered resp as its first
+// choice, keeping the rest of the chain after it, so that follow-up requests
+// such as tool rounds skip the models that just failed.
+func (cfg Config) FromFallback(resp *Response) Config {
+	if resp == nil || resp.Fallback <= 0 || resp.Fallback > len(cfg.Fallbacks) {
+		return cfg
+	}
+	out := cfg.attempt(resp.Fallback)
+	out.Fallbacks = cfg.Fallbacks[resp.Fallback:]
+	return out
+}
+
+// fallsBack reports whether err should move on to the next model.
+func (cfg Config) fallsBack(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	on := cfg.FallbackOn
+	if len(on) == 0 {
+		on = DefaultFallbackOn
+	}
+	return slices.Contains(on, apiErr.Kind)
+}
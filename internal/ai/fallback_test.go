@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// modelServer answers like the gateway, failing with the given status for
+// the models listed in down.
+func modelServer(t *testing.T, down map[string]int, calls *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		*calls = append(*calls, body.Model)
+		if status := down[body.Model]; status != 0 {
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"error":{"message":"unavailable"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"choices":[{"message":{"content":"from %s"}}]}`, body.Model)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFallbackOnOutage(t *testing.T) {
+	var calls []string
+	srv := modelServer(t, map[string]int{"gpt-4.1": http.StatusServiceUnavailable}, &calls)
+	gw := &Gateway{URL: srv.URL}
+
+	var notices []FallbackNotice
+	cfg := Config{
+		Model:      "gpt-4.1",
+		Gateway:    gw,
+		Retry:      fastRetry,
+		Fallbacks:  []Fallback{{Model: "gpt-4o", Gateway: gw}, {Model: "claude-sonnet-4-6", Gateway: gw}},
+		OnFallback: func(n FallbackNotice) { notices = append(notices, n) },
+	}
+	resp, err := New().Complete(context.Background(), cfg, "", []Message{{Role: "user", Content: "hi"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "from gpt-4o" || resp.Fallback != 1 || resp.Model.ID != "gpt-4o" {
+		t.Fatalf("got %q from fallback %d (%s)", resp.Text, resp.Fallback, resp.Model.ID)
+	}
+	if len(calls) != fastRetry.MaxAttempts+1 {
+		t.Fatalf("calls = %v", calls)
+	}
+	if len(notices) != 1 || notices[0].From != "gpt-4.1" || notices[0].To != "gpt-4o" {
+		t.Fatalf("notices = %+v", notices)
+	}
+	if msg := notices[0].Message(); !strings.HasSuffix(msg, "switching to gpt-4o…") {
+		t.Fatalf("Message() = %q", msg)
+	}
+
+	next := cfg.FromFallback(resp)
+	if next.Model != "gpt-4o" || len(next.Fallbacks) != 1 || next.Fallbacks[0].Model != "claude-sonnet-4-6" {
+		t.Fatalf("FromFallback() = %s then %+v", next.Model, next.Fallbacks)
+	}
+}
+
+func TestNoFallbackOnRequestErrors(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusBadRequest} {
+		var calls []string
+		srv := modelServer(t, map[string]int{"gpt-4.1": status}, &calls)
+		gw := &Gateway{URL: srv.URL}
+
+		_, err := New().Complete(context.Background(), Config{
+			Model:     "gpt-4.1",
+			Gateway:   gw,
+			Retry:     fastRetry,
+			Fallbacks: []Fallback{{Model: "gpt-4o", Gateway: gw}},
+		}, "", []Message{{Role: "user", Content: "hi"}}, nil)
+		if err == nil || len(calls) != 1 {
+			t.Fatalf("status %d: calls = %v, err = %v; want one call and an error", status, calls, err)
+		}
+	}
+}
+
+func TestFallbackChainReportsEveryFailure(t *testing.T) {
+	var calls []string
+	srv := modelServer(t, map[string]int{"gpt-4.1": 529, "gpt-4o": http.StatusUnauthorized}, &calls)
+	gw := &Gateway{URL: srv.URL}
+
+	_, err := New().Complete(context.Background(), Config{
+		Model:     "gpt-4.1",
+		Gateway:   gw,
+		Retry:     fastRetry,
+		Fallbacks: []Fallback{{Model: "gpt-4o", Gateway: gw}},
+	}, "", []Message{{Role: "user", Content: "hi"}}, nil)
+	if ErrorKindOf(err) != ErrorOverloaded || !strings.Contains(err.Error(), "gpt-4o:") {
+		t.Fatalf("err = %v", err)
+	}
+}
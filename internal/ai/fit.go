@@ -0,0 +1,85 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fit checks messages against the capabilities of cfg.Model and adapts
+// them to what it can take: images are left out for a model without
+// vision, and PDFs it cannot read are replaced by a note saying so. The
+// notices describe each change for the user, and say when the gateway
+// cannot carry the tools in cfg. A message left with nothing to send is an
+// ErrorInvalid. Models that cannot be resolved pass unchanged, for
+// Complete to report.
+//
+// Complete fits every request itself, so a fallback model gets the same
+// treatment; Fit lets the caller tell the user before sending.
+func (c *Client) Fit(cfg Config, messages []Message) ([]Message, []string, error) {
+	provider, model, err := c.resolve(cfg)
+	if err != nil {
+		return messages, nil, nil
+	}
+	out, notices, err := fitMessages(model, messages)
+	if err == nil && len(cfg.Tools) > 0 && !model.Capabilities.Tools && provider.ID() == GatewayProvider {
+		notices = append(notices, fmt.Sprintf("%s cannot call tools through the gateway, so it answers without them.", model.ID))
+	}
+	return out, notices, err
+}
+
+func fitMessages(model Model, messages []Message) ([]Message, []string, error) {
+	caps := model.Capabilities
+	var images int
+	var pdfs []string
+	out := make([]Message, len(messages))
+	for i, m := range messages {
+		if !caps.Vision && len(m.Images) > 0 {
+			if m.Role == "user" && strings.TrimSpace(m.Content) == "" && len(m.Documents) == 0 {
+				if i == len(messages)-1 {
+					return nil, nil, &APIError{Kind: ErrorInvalid, Err: fmt.Errorf("%s cannot see images — describe the image in words or choose a model with vision", model.ID)}
+				}
+				// An empty turn would be rejected; say what was there.
+				m.Content = "[Image not shown: this model cannot see images.]"
+			}
+			images += len(m.Images)
+			m.Images = nil
+		}
+		if !caps.Documents && hasPDF(m.Documents) {
+			var docs []Document
+			var notes []string
+			for _, d := range m.Documents {
+				if d.isPDF() {
+					pdfs = append(pdfs, fmt.Sprintf("%q", d.Name))
+					notes = append(notes, d.unreadable())
+				} else {
+					docs = append(docs, d)
+				}
+			}
+			m.Documents = docs
+			if m.Content != "" {
+				notes = append(notes, m.Content)
+			}
+			m.Content = strings.Join(notes, "\n\n")
+		}
+		out[i] = m
+	}
+
+	var notices []string
+	switch {
+	case images == 1:
+		notices = append(notices, fmt.Sprintf("%s cannot see images, so the image was left out.", model.ID))
+	case images > 1:
+		notices = append(notices, fmt.Sprintf("%s cannot see images, so %d images were left out.", model.ID, images))
+	}
+	if len(pdfs) > 0 {
+		notices = append(notices, fmt.Sprintf("%s cannot read PDFs, so %s went without %s contents.", model.ID, strings.Join(pdfs, ", "), plural(len(pdfs), "its", "their")))
+	}
+	return out, notices, nil
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFitStripsWhatTheModelCannotTake(t *testing.T) {
+	textOnly := Model{ID: "acme/text", Provider: OpenAIProvider, Capabilities: Capabilities{Streaming: true}}
+	cfg := Config{Model: "acme/text", Models: []Model{textOnly}}
+	pdf := Document{Name: "report.pdf", MIMEType: mimePDF, Data: "JVBERi0="}
+	notes := Document{Name: "notes.txt", MIMEType: "text/plain", Data: "aGk="}
+	msgs := []Message{
+		{Role: "user", Images: []string{"aW1n"}},
+		{Role: "assistant", Content: "A chart."},
+		{Role: "user", Content: "Compare", Images: []string{"aW1n"}, Documents: []Document{pdf, notes}},
+	}
+
+	got, notices, err := New().Fit(cfg, msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got[0].Images)+len(got[2].Images) != 0 || got[0].Content == "" {
+		t.Fatalf("images not left out: %+v", got)
+	}
+	if len(got[2].Documents) != 1 || got[2].Documents[0] != notes || !strings.HasPrefix(got[2].Content, pdf.unreadable()) || !strings.HasSuffix(got[2].Content, "Compare") {
+		t.Fatalf("last message = %+v, want the PDF replaced by a note", got[2])
+	}
+	if len(msgs[2].Images) != 1 || len(msgs[2].Documents) != 2 {
+		t.Fatal("Fit changed the caller's messages")
+	}
+	want := []string{
+		"acme/text cannot see images, so 2 images were left out.",
+		`acme/text cannot read PDFs, so "report.pdf" went without its contents.`,
+	}
+	if strings.Join(notices, "|") != strings.Join(want, "|") {
+		t.Fatalf("notices = %q, want %q", notices, want)
+	}
+
+	// A question that is only an image has nothing left to send.
+	_, _, err = New().Fit(cfg, msgs[:1])
+	if ErrorKindOf(err) != ErrorInvalid || !strings.Contains(err.Error(), "cannot see images") {
+		t.Fatalf("err = %v, want a local invalid-request error", err)
+	}
+
+	// A model that takes everything is left alone.
+	cfg.Model = "gpt-4.1"
+	if _, notices, err := New().Fit(cfg, msgs); err != nil || len(notices) != 0 {
+		t.Fatalf("Fit(gpt-4.1) = %q, %v", notices, err)
+	}
+}
+
+func TestMaxOutputCapsMaxTokens(t *testing.T) {
+	tr := &captureTransport{reply: `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`}
+	cfg := Config{OpenAIKey: "k", Model: "gpt-4o", HTTPClient: &http.Client{Transport: tr}, Params: map[string]Params{
+		"gpt-4o": {MaxTokens: 50_000},
+	}}
+	if _, err := New().Send(context.Background(), cfg, "", []Message{{Role: "user", Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if tr.body["max_tokens"] != float64(16_384) && tr.body["max_completion_tokens"] != float64(16_384) {
+		t.Fatalf("body = %v, want the output limit capped at gpt-4o's 16384", tr.body)
+	}
+
+	delete(cfg.Params, "gpt-4o")
+	if _, err := New().Send(context.Background(), cfg, "", []Message{{Role: "user", Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tr.body["max_completion_tokens"]; ok {
+		t.Fatalf("body = %v, want the provider default when no limit is set", tr.body)
+	}
+}
@@ -0,0 +1,482 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// gatewayMessage is a message in the Chat Completions API format.
+type gatewayMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"` // string or []gatewayContentPart
+}
+
+// gatewayContentPart represents a content block in the gateway format.
+type gatewayContentPart struct {
+	Type     string           `json:"type"`
+	Text     string           `json:"text,omitempty"`
+	Image    *gatewayImage    `json:"image,omitempty"`     // Anthropic models
+	ImageURL *gatewayImageURL `json:"image_url,omitempty"` // OpenAI models
+	Document *gatewayDocument `json:"document,omitempty"`  // Anthropic models
+	File     *gatewayFile     `json:"file,omitempty"`      // OpenAI models
+	Source   *gatewaySource   `json:"source,omitempty"`    // anthropic-messages images and documents
+
+	CacheControl *gatewayCacheControl `json:"cache_control,omitempty"` // system blocks, with Gateway.PromptCache
+}
+
+type gatewayCacheControl struct {
+	Type string `json:"type"` // "ephemeral"
+}
+
+type gatewayImage struct {
+	File     string `json:"file"`
+	MimeType string `json:"mimeType"`
+}
+
+type gatewayImageURL struct {
+	URL string `json:"url"`
+}
+
+type gatewayDocument struct {
+	File     string `json:"file"`
+	MimeType string `json:"mimeType"`
+	Name     string `json:"name,omitempty"`
+}
+
+// gatewaySource is the base64 source of an image or document block in the
+// Anthropic Messages API.
+type gatewaySource struct {
+	Type      string `json:"type"` // "base64"
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type gatewayFile struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data"` // data: URL
+}
+
+// gatewayParams holds model parameters sent inside the request body.
+type gatewayParams struct {
+	MaxOutputTokens int      `json:"max_output_tokens"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"top_p,omitempty"`
+	StopSequences   []string `json:"stop_sequences,omitempty"`
+	ReasoningEffort string   `json:"reasoning_effort,omitempty"`
+	ThinkingBudget  int      `json:"thinking_budget,omitempty"`
+}
+
+// gatewayMaxOutputTokens is sent when no profile sets a limit.
+const gatewayMaxOutputTokens = 20000
+
+// gatewayRequest is the body sent to the gateway endpoint.
+type gatewayRequest struct {
+	Model      string           `json:"model"`
+	Messages   []gatewayMessage `json:"messages"`
+	System     any              `json:"system,omitempty"` // string or, with Gateway.PromptCache, []gatewayContentPart
+	Parameters gatewayParams    `json:"parameters"`
+	Stream     bool             `json:"stream,omitempty"`
+}
+
+// gatewayResponse handles both Anthropic and OpenAI Chat Completions response shapes.
+type gatewayResponse struct {
+	// Anthropic shape
+	Content []gatewayContentBlock `json:"content"`
+	// OpenAI Chat Completions shape
+	Choices []gatewayChoice `json:"choices"`
+	// Common
+	Usage *gatewayUsage        `json:"usage,omitempty"`
+	Error *gatewayErrorPayload `json:"error,omitempty"`
+}
+
+// gatewayUsage accepts both Anthropic (input/output_tokens) and OpenAI
+// (prompt/completion_tokens) field names.
+type gatewayUsage struct {
+	InputTokens      int `json:"input_tokens"`
+	OutputTokens     int `json:"output_tokens"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+
+	// Anthropic counts cache hits and writes apart from input_tokens; OpenAI
+	// counts hits within prompt_tokens.
+	CacheReadInputTokens     int                  `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int                  `json:"cache_creation_input_tokens"`
+	PromptTokensDetails      *gatewayTokenDetails `json:"prompt_tokens_details"`
+
+	// The Responses API counts hits within input_tokens.
+	InputTokensDetails *gatewayTokenDetails `json:"input_tokens_details"`
+}
+
+type gatewayTokenDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+func (u *gatewayUsage) usage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	cached := 0
+	if u.PromptTokensDetails != nil {
+		cached += u.PromptTokensDetails.CachedTokens
+	}
+	if u.InputTokensDetails != nil {
+		cached += u.InputTokensDetails.CachedTokens
+	}
+	return Usage{
+		InputTokens:      u.InputTokens + u.PromptTokens - cached,
+		OutputTokens:     u.OutputTokens + u.CompletionTokens,
+		CacheReadTokens:  u.CacheReadInputTokens + cached,
+		CacheWriteTokens: u.CacheCreationInputTokens,
+	}
+}
+
+type gatewayContentBlock struct {
+	Type      string `json:"type"`
+	Text      string `json:"text"`
+	Thinking  string `json:"thinking"`
+	Signature string `json:"signature"`
+}
+
+type gatewayChoice struct {
+	Message struct {
+		Content          string `json:"content"`
+		ReasoningContent string `json:"reasoning_content"`
+	} `json:"message"`
+}
+
+type gatewayErrorPayload struct {
+	Message string `json:"message"`
+	Code    string `json:"code"`
+}
+
+// gatewayStreamChunk handles both Anthropic and OpenAI server-sent event payloads.
+type gatewayStreamChunk struct {
+	// Anthropic shape: {"type":"content_block_delta","delta":{"type":"text_delta","text":"..."}}
+	Type  string `json:"type"`
+	Delta struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		Thinking string `json:"thinking"` // thinking_delta
+	} `json:"delta"`
+	// Anthropic message_start carries input tokens, message_delta the output.
+	Message struct {
+		Usage *gatewayUsage `json:"usage"`
+	} `json:"message"`
+	// OpenAI Chat Completions shape: {"choices":[{"delta":{"content":"..."}}]}
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	// Common
+	Usage *gatewayUsage        `json:"usage,omitempty"`
+	Error *gatewayErrorPayload `json:"error,omitempty"`
+}
+
+// GatewayProvider is the provider ID of requests sent through a Gateway.
+const GatewayProvider = "gateway"
+
+// gatewayProvider sends every model through the configured gateway.
+type gatewayProvider struct {
+	tokens commandTokens
+	oauth  oauthTokens
+}
+
+func (*gatewayProvider) ID() string { return GatewayProvider }
+
+func (p *gatewayProvider) Send(ctx context.Context, cfg Config, req Request, onDelta DeltaFunc) (*Response, error) {
+	if cfg.Gateway == nil || cfg.Gateway.URL == "" {
+		return nil, fmt.Errorf("no gateway configured")
+	}
+	dialect, err := dialectFor(cfg.Gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := onDelta != nil && dialect.streams()
+	bodyBytes, err := dialect.body(cfg.Gateway, req, stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode gateway request: %w", err)
+	}
+
+	resp, err := p.post(ctx, cfg, bodyBytes, stream)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && stream &&
+		strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return dialect.stream(resp.Body, cfg, onDelta)
+	}
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gateway response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpError(GatewayProvider, resp, fmt.Sprintf("gateway returned status %d: %s", resp.StatusCode, string(respBytes)))
+	}
+
+	// The gateway ignored the stream flag, or the dialect cannot stream;
+	// deliver the answer as a single delta.
+	out, err := dialect.parse(respBytes)
+	if err == nil && onDelta != nil {
+		onDelta(out.Text)
+	}
+	return out, err
+}
+
+// layDialect is lay's original gateway format: Chat Completions messages
+// with a top-level system prompt and parameters object, and Anthropic-style
+// parts for Anthropic models. Answers may come in either vendor's shape.
+type layDialect struct{}
+
+func (layDialect) streams() bool { return true }
+
+func (layDialect) body(gw *Gateway, req Request, stream bool) ([]byte, error) {
+	isOpenAI := req.Model.Provider == OpenAIProvider || IsOpenAIModel(req.Model.ID)
+
+	// OpenAI models expect system prompt as a message; Anthropic uses top-level field.
+	system := []gatewayContentPart{{Type: "text", Text: req.System}}
+	if gw.PromptCache {
+		system = gatewaySystem(req.SystemBlocks)
+	}
+	var gwMessages []gatewayMessage
+	if isOpenAI && req.System != "" {
+		gwMessages = append(gwMessages, gatewayMessage{
+			Role:    "system",
+			Content: system,
+		})
+	}
+	gwMessages = append(gwMessages, gatewayMessages(req.Messages, isOpenAI)...)
+
+	reqBody := gatewayRequest{
+		Model:    req.Model.ID,
+		Messages: gwMessages,
+		Parameters: gatewayParams{
+			MaxOutputTokens: req.Params.maxTokens(gatewayMaxOutputTokens),
+			Temperature:     req.Params.Temperature,
+			TopP:            req.Params.TopP,
+			StopSequences:   req.Params.Stop,
+			ReasoningEffort: req.Params.ReasoningEffort,
+			ThinkingBudget:  req.Params.ThinkingBudget,
+		},
+		Stream: stream,
+	}
+	if !isOpenAI && req.System != "" {
+		reqBody.System = req.System
+		if gw.PromptCache {
+			reqBody.System = system
+		}
+	}
+	return json.Marshal(reqBody)
+}
+
+func (layDialect) parse(data []byte) (*Response, error) { return parseGatewayResponse(data) }
+
+func (layDialect) stream(body io.Reader, cfg Config, onDelta DeltaFunc) (*Response, error) {
+	return streamGateway(body, cfg, onDelta)
+}
+
+// gatewayMessages converts messages to Chat Completions messages, with
+// OpenAI-style image and file parts, or with openAI false the
+// Anthropic-style parts of lay's own format.
+func gatewayMessages(msgs []Message, openAI bool) []gatewayMessage {
+	out := make([]gatewayMessage, 0, len(msgs))
+	for _, m := range msgs {
+		var parts []gatewayContentPart
+		if m.Role == "user" {
+			for _, doc := range m.Documents {
+				switch {
+				case !doc.isPDF():
+					parts = append(parts, gatewayContentPart{Type: "text", Text: doc.text()})
+				case openAI:
+					parts = append(parts, gatewayContentPart{
+						Type: "file",
+						File: &gatewayFile{Filename: doc.Name, FileData: "data:" + mimePDF + ";base64," + doc.Data},
+					})
+				default:
+					parts = append(parts, gatewayContentPart{
+						Type:     "document",
+						Document: &gatewayDocument{File: doc.Data, MimeType: mimePDF, Name: doc.Name},
+					})
+				}
+			}
+			for _, img := range m.Images {
+				if openAI {
+					parts = append(parts, gatewayContentPart{
+						Type:     "image_url",
+						ImageURL: &gatewayImageURL{URL: imageDataURL(img)},
+					})
+				} else {
+					parts = append(parts, gatewayContentPart{
+						Type: "image",
+						Image: &gatewayImage{
+							File:     img,
+							MimeType: imageMIME(img),
+						},
+					})
+				}
+			}
+		}
+		if m.Content != "" {
+			parts = append(parts, gatewayContentPart{Type: "text", Text: m.Content})
+		}
+		out = append(out, gatewayMessage{Role: m.Role, Content: parts})
+	}
+	return out
+}
+
+// post sends body to the gateway. If a command or OAuth token is rejected, a
+// fresh one is fetched and the request is sent once more.
+func (p *gatewayProvider) post(ctx context.Context, cfg Config, body []byte, stream bool) (*http.Response, error) {
+	resp, err := p.do(ctx, cfg, body, stream, false)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && cfg.Gateway.Auth.refreshable() {
+		resp.Body.Close()
+		return p.do(ctx, cfg, body, stream, true)
+	}
+	return resp, nil
+}
+
+func (p *gatewayProvider) do(ctx context.Context, cfg Config, body []byte, stream bool, refresh bool) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.Gateway.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gateway request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if cfg.Gateway.Dialect == DialectAnthropicMessages {
+		httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
+	}
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	if err := p.authorize(ctx, httpReq, cfg, refresh); err != nil {
+		return nil, err
+	}
+
+	resp, err := cfg.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("gateway request failed: %w", err)
+	}
+	return resp, nil
+}
+
+func parseGatewayResponse(respBytes []byte) (*Response, error) {
+	var gwResp gatewayResponse
+	if err := json.Unmarshal(respBytes, &gwResp); err != nil {
+		return nil, fmt.Errorf("failed to parse gateway response: %w", err)
+	}
+
+	if gwResp.Error != nil {
+		return nil, fmt.Errorf("gateway error: %s", gwResp.Error.Message)
+	}
+
+	// OpenAI Chat Completions response
+	if len(gwResp.Choices) > 0 {
+		msg := gwResp.Choices[0].Message
+		return &Response{Text: msg.Content, Thinking: plainThinking(msg.ReasoningContent), Usage: gwResp.Usage.usage()}, nil
+	}
+
+	// Anthropic-style response; thinking blocks come before the text.
+	out := &Response{Usage: gwResp.Usage.usage()}
+	var sb strings.Builder
+	for _, block := range gwResp.Content {
+		switch block.Type {
+		case "text":
+			sb.WriteString(block.Text)
+		case "thinking":
+			out.Thinking = append(out.Thinking, Thinking{Text: block.Thinking, Signature: block.Signature})
+		}
+	}
+	if sb.Len() == 0 {
+		return nil, fmt.Errorf("no text content in gateway response: %s", string(respBytes))
+	}
+	out.Text = sb.String()
+	return out, nil
+}
+
+// gatewaySystem returns the system prompt as text parts with Anthropic-style
+// cache_control hints, which gateways that support prompt caching pass on
+// to the vendor.
+func gatewaySystem(blocks []SystemBlock) []gatewayContentPart {
+	var parts []gatewayContentPart
+	for _, b := range blocks {
+		if b.Text == "" {
+			continue
+		}
+		part := gatewayContentPart{Type: "text", Text: b.Text}
+		if b.Cache {
+			part.CacheControl = &gatewayCacheControl{Type: "ephemeral"}
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+func streamGateway(body io.Reader, cfg Config, onDelta DeltaFunc) (*Response, error) {
+	var sb, thinking strings.Builder
+	var usage Usage
+	err := readSSE(body, func(data []byte) error {
+		var chunk gatewayStreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to parse gateway stream event: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("gateway error: %s", chunk.Error.Message)
+		}
+		if chunk.Message.Usage != nil { // Anthropic message_start
+			usage = chunk.Message.Usage.usage()
+		}
+		if chunk.Usage != nil {
+			// OpenAI sends the totals in its last chunk; Anthropic's
+			// message_delta has only the cumulative output tokens.
+			got := chunk.Usage.usage()
+			if got.InputTokens > 0 {
+				usage.InputTokens = got.InputTokens
+			}
+			if got.CacheReadTokens > 0 || got.CacheWriteTokens > 0 {
+				usage.CacheReadTokens, usage.CacheWriteTokens = got.CacheReadTokens, got.CacheWriteTokens
+			}
+			usage.OutputTokens = got.OutputTokens
+		}
+		var text, thought string
+		if len(chunk.Choices) > 0 {
+			text = chunk.Choices[0].Delta.Content
+			thought = chunk.Choices[0].Delta.ReasoningContent
+		} else if chunk.Type == "content_block_delta" {
+			switch chunk.Delta.Type {
+			case "text_delta":
+				text = chunk.Delta.Text
+			case "thinking_delta":
+				thought = chunk.Delta.Thinking
+			}
+		}
+		if thought != "" {
+			thinking.WriteString(thought)
+			cfg.thinking(thought)
+		}
+		if text != "" {
+			sb.WriteString(text)
+			onDelta(text)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sb.Len() == 0 {
+		return nil, fmt.Errorf("no text content in gateway stream")
+	}
+	return &Response{Text: sb.String(), Thinking: plainThinking(thinking.String()), Usage: usage}, nil
+}
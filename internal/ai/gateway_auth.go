@@ -0,0 +1,137 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Gateway describes a gateway endpoint and how to authenticate with it.
+type Gateway struct {
+	Name    string // shown when requests fail over to it
+	URL     string
+	Headers map[string]string // sent with every request, e.g. a tenant ID
+	Auth    *GatewayAuth
+
+	// ModelsURL lists the gateway's models in the OpenAI format; empty if
+	// the gateway has no such endpoint.
+	ModelsURL string
+
+	// PromptCache sends the system prompt as blocks with cache_control
+	// hints on its stable prefix. Only for gateways that accept them.
+	PromptCache bool
+
+	// Dialect is the wire format of URL, one of the Dialect constants.
+	// Empty keeps lay's original format, which mixes Chat Completions
+	// messages with Anthropic-style fields.
+	Dialect string
+	Custom  *CustomDialect // for DialectCustom
+}
+
+// Gateway auth types.
+const (
+	AuthBearer  = "bearer"  // Authorization: Bearer <Token>
+	AuthAPIKey  = "apiKey"  // <Header>: <Token>
+	AuthCommand = "command" // Authorization: Bearer <stdout of Command>, refreshed on 401
+	AuthOAuth2  = "oauth2"  // Authorization: Bearer <access token>, see oauth.go
+)
+
+type GatewayAuth struct {
+	Type    string
+	Token   string // for bearer and apiKey
+	Header  string // header for apiKey (default X-API-Key); overrides Authorization for command
+	Command string // shell command that prints a token, for command
+
+	// oauth2
+	Flow          string // OAuthClientCredentials or OAuthDeviceCode
+	TokenURL      string
+	DeviceAuthURL string // device-code only
+	ClientID      string
+	ClientSecret  string
+	Scopes        []string
+	TokenFile     string // where tokens are cached; empty keeps them in memory
+}
+
+// refreshable reports whether a 401 may be fixed by fetching a new token.
+func (a *GatewayAuth) refreshable() bool {
+	return a != nil && (a.Type == AuthCommand || a.Type == AuthOAuth2)
+}
+
+// commandTokens caches tokens printed by auth commands until the gateway
+// rejects them.
+type commandTokens struct {
+	mu     sync.Mutex
+	tokens map[string]string // by command
+}
+
+func (c *commandTokens) get(ctx context.Context, command string, refresh bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tok, ok := c.tokens[command]; ok && !refresh {
+		return tok, nil
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("gateway token command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	tok := strings.TrimSpace(string(out))
+	if tok == "" {
+		return "", fmt.Errorf("gateway token command printed no token")
+	}
+	if c.tokens == nil {
+		c.tokens = make(map[string]string)
+	}
+	c.tokens[command] = tok
+	return tok, nil
+}
+
+// authorize sets the configured headers and credentials on req. With
+// refresh set, command tokens are fetched anew instead of from the cache.
+func (p *gatewayProvider) authorize(ctx context.Context, req *http.Request, cfg Config, refresh bool) error {
+	gw := cfg.Gateway
+	for k, v := range gw.Headers {
+		req.Header.Set(k, v)
+	}
+
+	auth := gw.Auth
+	if auth == nil {
+		return nil
+	}
+	switch auth.Type {
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+	case AuthAPIKey:
+		header := auth.Header
+		if header == "" {
+			header = "X-API-Key"
+		}
+		req.Header.Set(header, auth.Token)
+	case AuthCommand:
+		tok, err := p.tokens.get(ctx, auth.Command, refresh)
+		if err != nil {
+			return err
+		}
+		if auth.Header != "" {
+			req.Header.Set(auth.Header, tok)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+tok)
+		}
+	case AuthOAuth2:
+		tok, err := p.oauth.get(ctx, cfg.httpClient(), auth, refresh)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+tok)
+	default:
+		return fmt.Errorf("unknown gateway auth type %q", auth.Type)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGatewaySendsHeadersAndCredentials(t *testing.T) {
+	cases := []struct {
+		name   string
+		auth   *GatewayAuth
+		header string
+		want   string
+	}{
+		{name: "bearer", auth: &GatewayAuth{Type: AuthBearer, Token: "t0k"}, header: "Authorization", want: "Bearer t0k"},
+		{name: "api key default header", auth: &GatewayAuth{Type: AuthAPIKey, Token: "k3y"}, header: "X-API-Key", want: "k3y"},
+		{name: "api key custom header", auth: &GatewayAuth{Type: AuthAPIKey, Token: "k3y", Header: "Api-Key"}, header: "Api-Key", want: "k3y"},
+		{name: "command", auth: &GatewayAuth{Type: AuthCommand, Command: "echo cmd-token"}, header: "Authorization", want: "Bearer cmd-token"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get(tc.header); got != tc.want {
+					t.Errorf("%s = %q, want %q", tc.header, got, tc.want)
+				}
+				if got := r.Header.Get("X-Tenant"); got != "acme" {
+					t.Errorf("X-Tenant = %q, want acme", got)
+				}
+				fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+			}))
+			defer srv.Close()
+
+			_, err := New().Send(context.Background(), Config{
+				Model:   "gpt-4.1",
+				Gateway: &Gateway{URL: srv.URL, Headers: map[string]string{"X-Tenant": "acme"}, Auth: tc.auth},
+			}, "", []Message{{Role: "user", Content: "hi"}})
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestGatewayRefreshesCommandTokenOn401(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") != "Bearer new" {
+			// The token expired; the next run of the command prints a new one.
+			_ = os.WriteFile(tokenFile, []byte("new\n"), 0o600)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	c := New()
+	cfg := Config{
+		Model:   "gpt-4.1",
+		Gateway: &Gateway{URL: srv.URL, Auth: &GatewayAuth{Type: AuthCommand, Command: "cat " + tokenFile}},
+	}
+	msgs := []Message{{Role: "user", Content: "hi"}}
+	if _, err := c.Send(context.Background(), cfg, "", msgs); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("calls = %d, want 2 (rejected, then refreshed)", calls)
+	}
+
+	// The refreshed token is cached for later requests.
+	if _, err := c.Send(context.Background(), cfg, "", msgs); err != nil || calls != 3 {
+		t.Fatalf("second Send() = %v after %d calls, want cached token", err, calls)
+	}
+}
@@ -0,0 +1,405 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CustomDialect describes a gateway whose wire format is none of the
+// standard ones.
+//
+// Request is a JSON body in which strings may hold {{placeholders}}: model,
+// system, messages, prompt, maxTokens, temperature, topP, stop,
+// reasoningEffort and stream. A string that is just a placeholder becomes
+// the value itself, so "{{messages}}" turns into an array and
+// "{{maxTokens}}" into a number; an object key whose value is unset, like
+// an unconfigured temperature, is left out. Placeholders inside longer
+// strings are replaced by their text.
+//
+// The answer is read with JSONPath-style selectors such as
+// $.choices[0].message.content: dotted names, ["quoted names"], [n]
+// indexes, negative ones counting from the end, and [*] for every element,
+// whose strings are joined.
+type CustomDialect struct {
+	Request json.RawMessage
+
+	Text         string // the answer; required
+	Thinking     string // optional reasoning text
+	InputTokens  string // optional usage counts
+	OutputTokens string
+	Error        string // optional; a non-empty match fails the request
+
+	// StreamText selects the text of each server-sent event. Without it
+	// the gateway is asked not to stream and the answer arrives whole.
+	StreamText string
+}
+
+type customDialect struct{ *CustomDialect }
+
+func (d customDialect) streams() bool { return d.StreamText != "" }
+
+func (d customDialect) body(_ *Gateway, req Request, stream bool) ([]byte, error) {
+	tmpl, err := decodeJSON(d.Request)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request template: %w", err)
+	}
+
+	var prompt string
+	if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == "user" {
+		prompt = req.Messages[n-1].Content
+	}
+	vars := map[string]any{
+		"model":           req.Model.ID,
+		"system":          req.System,
+		"messages":        customMessages(req.Messages),
+		"prompt":          prompt,
+		"maxTokens":       req.Params.maxTokens(gatewayMaxOutputTokens),
+		"temperature":     nil,
+		"topP":            nil,
+		"stop":            nil,
+		"reasoningEffort": nil,
+		"stream":          stream,
+	}
+	if req.Params.Temperature != nil {
+		vars["temperature"] = *req.Params.Temperature
+	}
+	if req.Params.TopP != nil {
+		vars["topP"] = *req.Params.TopP
+	}
+	if len(req.Params.Stop) > 0 {
+		vars["stop"] = req.Params.Stop
+	}
+	if req.Params.ReasoningEffort != "" {
+		vars["reasoningEffort"] = req.Params.ReasoningEffort
+	}
+
+	body, _, err := fillTemplate(tmpl, vars)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(body)
+}
+
+// customMessages renders messages in the Chat Completions format, with
+// plain string content unless the message carries images or PDFs.
+func customMessages(msgs []Message) []gatewayMessage {
+	out := gatewayMessages(msgs, true)
+	for i, m := range msgs {
+		if len(m.Images) == 0 && !hasPDF(m.Documents) {
+			var sb strings.Builder
+			for _, part := range out[i].Content.([]gatewayContentPart) {
+				sb.WriteString(part.Text)
+			}
+			out[i].Content = sb.String()
+		}
+	}
+	return out
+}
+
+func hasPDF(docs []Document) bool {
+	for _, d := range docs {
+		if d.isPDF() {
+			return true
+		}
+	}
+	return false
+}
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// placeholder returns the name in s if s is a single placeholder.
+func placeholder(s string) (string, bool) {
+	m := placeholderPattern.FindStringSubmatch(s)
+	if m == nil || m[0] != s {
+		return "", false
+	}
+	return m[1], true
+}
+
+// fillTemplate replaces the placeholders in v. The second result is false
+// when v was a single placeholder for an unset value.
+func fillTemplate(v any, vars map[string]any) (any, bool, error) {
+	switch v := v.(type) {
+	case string:
+		if name, ok := placeholder(v); ok {
+			val, known := vars[name]
+			if !known {
+				return nil, false, fmt.Errorf("unknown placeholder {{%s}} in request template", name)
+			}
+			return val, val != nil, nil
+		}
+		var err error
+		out := placeholderPattern.ReplaceAllStringFunc(v, func(m string) string {
+			name, _ := placeholder(m)
+			val, known := vars[name]
+			if !known {
+				err = fmt.Errorf("unknown placeholder {{%s}} in request template", name)
+			}
+			if val == nil {
+				return ""
+			}
+			return fmt.Sprint(val)
+		})
+		return out, true, err
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			filled, set, err := fillTemplate(e, vars)
+			if err != nil {
+				return nil, false, err
+			}
+			if set {
+				out[k] = filled
+			}
+		}
+		return out, true, nil
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, e := range v {
+			filled, set, err := fillTemplate(e, vars)
+			if err != nil {
+				return nil, false, err
+			}
+			if set {
+				out = append(out, filled)
+			}
+		}
+		return out, true, nil
+	}
+	return v, true, nil
+}
+
+func (d customDialect) parse(data []byte) (*Response, error) {
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse gateway response: %w", err)
+	}
+	if err := d.checkError(doc); err != nil {
+		return nil, err
+	}
+	text, err := selectText(doc, d.Text)
+	if err != nil {
+		return nil, err
+	}
+	if text == "" {
+		return nil, fmt.Errorf("no text at %s in gateway response: %s", d.Text, string(data))
+	}
+	out := &Response{Text: text}
+	if d.Thinking != "" {
+		thinking, err := selectText(doc, d.Thinking)
+		if err != nil {
+			return nil, err
+		}
+		out.Thinking = plainThinking(thinking)
+	}
+	out.Usage, err = d.usage(doc, Usage{})
+	return out, err
+}
+
+func (d customDialect) stream(body io.Reader, _ Config, onDelta DeltaFunc) (*Response, error) {
+	var sb strings.Builder
+	var usage Usage
+	err := readSSE(body, func(data []byte) error {
+		doc, err := decodeJSON(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse gateway stream event: %w", err)
+		}
+		if err := d.checkError(doc); err != nil {
+			return err
+		}
+		if usage, err = d.usage(doc, usage); err != nil {
+			return err
+		}
+		text, err := selectText(doc, d.StreamText)
+		if err != nil {
+			return err
+		}
+		if text != "" {
+			sb.WriteString(text)
+			onDelta(text)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sb.Len() == 0 {
+		return nil, fmt.Errorf("no text content in gateway stream")
+	}
+	return &Response{Text: sb.String(), Usage: usage}, nil
+}
+
+func (d customDialect) checkError(doc any) error {
+	if d.Error == "" {
+		return nil
+	}
+	msg, err := selectText(doc, d.Error)
+	if err != nil {
+		return err
+	}
+	if msg != "" {
+		return fmt.Errorf("gateway error: %s", msg)
+	}
+	return nil
+}
+
+// usage reads the token counts from doc, keeping those of prev that doc
+// does not mention.
+func (d customDialect) usage(doc any, prev Usage) (Usage, error) {
+	for _, f := range []struct {
+		selector string
+		n        *int
+	}{{d.InputTokens, &prev.InputTokens}, {d.OutputTokens, &prev.OutputTokens}} {
+		if f.selector == "" {
+			continue
+		}
+		vals, err := selectJSON(doc, f.selector)
+		if err != nil {
+			return prev, err
+		}
+		for _, v := range vals {
+			if n, ok := v.(json.Number); ok {
+				if i, err := n.Int64(); err == nil {
+					*f.n = int(i)
+				}
+			}
+		}
+	}
+	return prev, nil
+}
+
+// decodeJSON decodes data keeping numbers as json.Number, so templates
+// pass them through unchanged.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// selectText joins the strings selector matches in doc.
+func selectText(doc any, selector string) (string, error) {
+	vals, err := selectJSON(doc, selector)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, v := range vals {
+		switch v := v.(type) {
+		case string:
+			sb.WriteString(v)
+		case json.Number:
+			sb.WriteString(v.String())
+		}
+	}
+	return sb.String(), nil
+}
+
+// selectJSON returns the values selector matches in doc. Missing names and
+// indexes match nothing.
+func selectJSON(doc any, selector string) ([]any, error) {
+	steps, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	vals := []any{doc}
+	for _, step := range steps {
+		var next []any
+		for _, v := range vals {
+			switch {
+			case step.all:
+				switch v := v.(type) {
+				case []any:
+					next = append(next, v...)
+				case map[string]any:
+					for _, k := range slices.Sorted(maps.Keys(v)) {
+						next = append(next, v[k])
+					}
+				}
+			case step.index != nil:
+				if arr, ok := v.([]any); ok {
+					i := *step.index
+					if i < 0 {
+						i += len(arr)
+					}
+					if i >= 0 && i < len(arr) {
+						next = append(next, arr[i])
+					}
+				}
+			default:
+				if obj, ok := v.(map[string]any); ok {
+					if e, ok := obj[step.name]; ok {
+						next = append(next, e)
+					}
+				}
+			}
+		}
+		vals = next
+	}
+	return vals, nil
+}
+
+type selectorStep struct {
+	name  string
+	index *int
+	all   bool
+}
+
+func parseSelector(selector string) ([]selectorStep, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(selector), "$")
+	if !ok {
+		return nil, fmt.Errorf("selector %q must start with $", selector)
+	}
+	var steps []selectorStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("selector %q has an empty name", selector)
+			}
+			if rest[:end] == "*" {
+				steps = append(steps, selectorStep{all: true})
+			} else {
+				steps = append(steps, selectorStep{name: rest[:end]})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("selector %q has an unclosed [", selector)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, selectorStep{all: true})
+			case len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, selectorStep{name: inner[1 : len(inner)-1]})
+			default:
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("selector %q has an invalid index [%s]", selector, inner)
+				}
+				steps = append(steps, selectorStep{index: &i})
+			}
+		default:
+			return nil, fmt.Errorf("selector %q is not valid at %q", selector, rest)
+		}
+	}
+	return steps, nil
+}
@@ -0,0 +1,360 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Gateway dialects: the wire format spoken at Gateway.URL.
+const (
+	DialectOpenAIChat        = "openai-chat"        // Chat Completions, e.g. /v1/chat/completions
+	DialectOpenAIResponses   = "openai-responses"   // OpenAI Responses API, e.g. /v1/responses
+	DialectAnthropicMessages = "anthropic-messages" // Anthropic Messages API, e.g. /v1/messages
+	DialectCustom            = "custom"             // request template and response selectors, see CustomDialect
+)
+
+// anthropicAPIVersion is sent with anthropic-messages requests.
+const anthropicAPIVersion = "2023-06-01"
+
+// gatewayDialect builds request bodies for a gateway and reads its answers.
+type gatewayDialect interface {
+	// streams reports whether the gateway can be asked for server-sent events.
+	streams() bool
+	body(gw *Gateway, req Request, stream bool) ([]byte, error)
+	parse(data []byte) (*Response, error)
+	stream(body io.Reader, cfg Config, onDelta DeltaFunc) (*Response, error)
+}
+
+func dialectFor(gw *Gateway) (gatewayDialect, error) {
+	switch gw.Dialect {
+	case "":
+		return layDialect{}, nil
+	case DialectOpenAIChat:
+		return openAIChatDialect{}, nil
+	case DialectOpenAIResponses:
+		return openAIResponsesDialect{}, nil
+	case DialectAnthropicMessages:
+		return anthropicMessagesDialect{}, nil
+	case DialectCustom:
+		if gw.Custom == nil || len(gw.Custom.Request) == 0 || gw.Custom.Text == "" {
+			return nil, fmt.Errorf("the custom gateway dialect needs a request template and a text selector")
+		}
+		return customDialect{gw.Custom}, nil
+	}
+	return nil, fmt.Errorf("unknown gateway dialect %q", gw.Dialect)
+}
+
+// Capabilities returns what a model that can do caps can still do through
+// gw. No dialect carries tool definitions or calls yet, so tools are off;
+// Fit tells the user, and the chat answers without them.
+func (gw *Gateway) Capabilities(caps Capabilities) Capabilities {
+	caps.Tools = false
+	return caps
+}
+
+// openAIChatDialect speaks the Chat Completions API, as LiteLLM, Portkey,
+// OpenRouter and most proxies do.
+type openAIChatDialect struct{}
+
+type openAIChatRequest struct {
+	Model               string           `json:"model"`
+	Messages            []gatewayMessage `json:"messages"`
+	MaxCompletionTokens int              `json:"max_completion_tokens"`
+	Temperature         *float64         `json:"temperature,omitempty"`
+	TopP                *float64         `json:"top_p,omitempty"`
+	Stop                []string         `json:"stop,omitempty"`
+	ReasoningEffort     string           `json:"reasoning_effort,omitempty"`
+	Stream              bool             `json:"stream,omitempty"`
+	StreamOptions       *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options,omitempty"`
+}
+
+func (openAIChatDialect) streams() bool { return true }
+
+func (openAIChatDialect) body(gw *Gateway, req Request, stream bool) ([]byte, error) {
+	var messages []gatewayMessage
+	if req.System != "" {
+		var system any = req.System
+		if gw.PromptCache {
+			system = gatewaySystem(req.SystemBlocks)
+		}
+		messages = append(messages, gatewayMessage{Role: "system", Content: system})
+	}
+	body := openAIChatRequest{
+		Model:               req.Model.ID,
+		Messages:            append(messages, gatewayMessages(req.Messages, true)...),
+		MaxCompletionTokens: req.Params.maxTokens(gatewayMaxOutputTokens),
+		Stop:                req.Params.Stop,
+		ReasoningEffort:     req.Params.reasoningEffort(),
+		Stream:              stream,
+	}
+	body.Temperature, body.TopP = req.Params.sampling(req.Model.ID)
+	if stream {
+		body.StreamOptions = &struct {
+			IncludeUsage bool `json:"include_usage"`
+		}{IncludeUsage: true}
+	}
+	return json.Marshal(body)
+}
+
+func (openAIChatDialect) parse(data []byte) (*Response, error) { return parseGatewayResponse(data) }
+
+func (openAIChatDialect) stream(body io.Reader, cfg Config, onDelta DeltaFunc) (*Response, error) {
+	return streamGateway(body, cfg, onDelta)
+}
+
+// anthropicMessagesDialect speaks the Anthropic Messages API.
+type anthropicMessagesDialect struct{}
+
+type anthropicMessagesRequest struct {
+	Model         string           `json:"model"`
+	Messages      []gatewayMessage `json:"messages"`
+	System        any              `json:"system,omitempty"` // string or, with Gateway.PromptCache, []gatewayContentPart
+	MaxTokens     int              `json:"max_tokens"`
+	Temperature   *float64         `json:"temperature,omitempty"`
+	TopP          *float64         `json:"top_p,omitempty"`
+	StopSequences []string         `json:"stop_sequences,omitempty"`
+	Thinking      *struct {
+		Type         string `json:"type"`
+		BudgetTokens int    `json:"budget_tokens"`
+	} `json:"thinking,omitempty"`
+	Stream bool `json:"stream,omitempty"`
+}
+
+func (anthropicMessagesDialect) streams() bool { return true }
+
+func (anthropicMessagesDialect) body(gw *Gateway, req Request, stream bool) ([]byte, error) {
+	body := anthropicMessagesRequest{
+		Model:         req.Model.ID,
+		MaxTokens:     req.Params.maxTokens(defaultMaxTokens),
+		Temperature:   req.Params.Temperature,
+		TopP:          req.Params.TopP,
+		StopSequences: req.Params.Stop,
+		Stream:        stream,
+	}
+	if req.System != "" {
+		body.System = req.System
+		if gw.PromptCache {
+			body.System = gatewaySystem(req.SystemBlocks)
+		}
+	}
+	if budget := req.Params.thinkingBudget(); budget > 0 {
+		// As in anthropic.go: the budget counts towards max_tokens, and
+		// sampling parameters cannot be combined with thinking.
+		budget = max(budget, minThinkingBudget)
+		if body.MaxTokens <= budget {
+			body.MaxTokens = budget + defaultMaxTokens
+		}
+		body.Temperature, body.TopP = nil, nil
+		body.Thinking = &struct {
+			Type         string `json:"type"`
+			BudgetTokens int    `json:"budget_tokens"`
+		}{Type: "enabled", BudgetTokens: budget}
+	}
+
+	for _, m := range req.Messages {
+		var parts []gatewayContentPart
+		if m.Role == "user" {
+			for _, doc := range m.Documents {
+				if !doc.isPDF() {
+					parts = append(parts, gatewayContentPart{Type: "text", Text: doc.text()})
+					continue
+				}
+				parts = append(parts, gatewayContentPart{
+					Type:   "document",
+					Source: &gatewaySource{Type: "base64", MediaType: mimePDF, Data: doc.Data},
+				})
+			}
+			for _, img := range m.Images {
+				parts = append(parts, gatewayContentPart{
+					Type:   "image",
+					Source: &gatewaySource{Type: "base64", MediaType: imageMIME(img), Data: img},
+				})
+			}
+		}
+		if m.Content != "" {
+			parts = append(parts, gatewayContentPart{Type: "text", Text: m.Content})
+		}
+		body.Messages = append(body.Messages, gatewayMessage{Role: m.Role, Content: parts})
+	}
+	return json.Marshal(body)
+}
+
+func (anthropicMessagesDialect) parse(data []byte) (*Response, error) {
+	return parseGatewayResponse(data)
+}
+
+func (anthropicMessagesDialect) stream(body io.Reader, cfg Config, onDelta DeltaFunc) (*Response, error) {
+	return streamGateway(body, cfg, onDelta)
+}
+
+// openAIResponsesDialect speaks the OpenAI Responses API. Nothing is
+// stored on the server; the whole conversation is sent each time.
+type openAIResponsesDialect struct{}
+
+type responsesRequest struct {
+	Model           string          `json:"model"`
+	Instructions    string          `json:"instructions,omitempty"`
+	Input           []responsesItem `json:"input"`
+	MaxOutputTokens int             `json:"max_output_tokens"`
+	Temperature     *float64        `json:"temperature,omitempty"`
+	TopP            *float64        `json:"top_p,omitempty"`
+	Reasoning       *struct {
+		Effort  string `json:"effort"`
+		Summary string `json:"summary"`
+	} `json:"reasoning,omitempty"`
+	Store  bool `json:"store"`
+	Stream bool `json:"stream,omitempty"`
+}
+
+type responsesItem struct {
+	Role    string          `json:"role"`
+	Content []responsesPart `json:"content"`
+}
+
+type responsesPart struct {
+	Type     string `json:"type"` // input_text, input_image, input_file or output_text
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+}
+
+// responsesResult is a finished response, also carried by the
+// response.completed stream event.
+type responsesResult struct {
+	Output []struct {
+		Type    string `json:"type"` // "message" or "reasoning"
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Summary []struct {
+			Text string `json:"text"`
+		} `json:"summary"`
+	} `json:"output"`
+	Usage *gatewayUsage        `json:"usage,omitempty"`
+	Error *gatewayErrorPayload `json:"error,omitempty"`
+}
+
+// responsesEvent is a server-sent event of the Responses API.
+type responsesEvent struct {
+	Type     string           `json:"type"`
+	Delta    string           `json:"delta"`
+	Message  string           `json:"message"` // type "error"
+	Response *responsesResult `json:"response"`
+}
+
+func (openAIResponsesDialect) streams() bool { return true }
+
+func (openAIResponsesDialect) body(gw *Gateway, req Request, stream bool) ([]byte, error) {
+	body := responsesRequest{
+		Model:           req.Model.ID,
+		Instructions:    req.System,
+		MaxOutputTokens: req.Params.maxTokens(gatewayMaxOutputTokens),
+		Stream:          stream,
+	}
+	body.Temperature, body.TopP = req.Params.sampling(req.Model.ID)
+	if effort := req.Params.reasoningEffort(); effort != "" {
+		body.Reasoning = &struct {
+			Effort  string `json:"effort"`
+			Summary string `json:"summary"`
+		}{Effort: effort, Summary: "auto"}
+	}
+
+	for _, m := range req.Messages {
+		var parts []responsesPart
+		if m.Role == "user" {
+			for _, doc := range m.Documents {
+				if !doc.isPDF() {
+					parts = append(parts, responsesPart{Type: "input_text", Text: doc.text()})
+					continue
+				}
+				parts = append(parts, responsesPart{Type: "input_file", Filename: doc.Name, FileData: "data:" + mimePDF + ";base64," + doc.Data})
+			}
+			for _, img := range m.Images {
+				parts = append(parts, responsesPart{Type: "input_image", ImageURL: imageDataURL(img)})
+			}
+		}
+		if m.Content != "" {
+			kind := "input_text"
+			if m.Role == "assistant" {
+				kind = "output_text"
+			}
+			parts = append(parts, responsesPart{Type: kind, Text: m.Content})
+		}
+		body.Input = append(body.Input, responsesItem{Role: m.Role, Content: parts})
+	}
+	return json.Marshal(body)
+}
+
+func (openAIResponsesDialect) parse(data []byte) (*Response, error) {
+	var res responsesResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("failed to parse gateway response: %w", err)
+	}
+	if res.Error != nil {
+		return nil, fmt.Errorf("gateway error: %s", res.Error.Message)
+	}
+
+	var text, thinking strings.Builder
+	for _, item := range res.Output {
+		switch item.Type {
+		case "message":
+			for _, c := range item.Content {
+				if c.Type == "output_text" {
+					text.WriteString(c.Text)
+				}
+			}
+		case "reasoning":
+			for _, s := range item.Summary {
+				thinking.WriteString(s.Text)
+			}
+		}
+	}
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("no text content in gateway response: %s", string(data))
+	}
+	return &Response{Text: text.String(), Thinking: plainThinking(thinking.String()), Usage: res.Usage.usage()}, nil
+}
+
+func (openAIResponsesDialect) stream(body io.Reader, cfg Config, onDelta DeltaFunc) (*Response, error) {
+	var sb, thinking strings.Builder
+	var usage Usage
+	err := readSSE(body, func(data []byte) error {
+		var ev responsesEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return fmt.Errorf("failed to parse gateway stream event: %w", err)
+		}
+		switch ev.Type {
+		case "response.output_text.delta":
+			sb.WriteString(ev.Delta)
+			onDelta(ev.Delta)
+		case "response.reasoning_summary_text.delta":
+			thinking.WriteString(ev.Delta)
+			cfg.thinking(ev.Delta)
+		case "response.completed", "response.incomplete":
+			if ev.Response != nil {
+				usage = ev.Response.Usage.usage()
+			}
+		case "response.failed":
+			if ev.Response != nil && ev.Response.Error != nil {
+				return fmt.Errorf("gateway error: %s", ev.Response.Error.Message)
+			}
+			return fmt.Errorf("gateway error: the response failed")
+		case "error":
+			return fmt.Errorf("gateway error: %s", ev.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sb.Len() == 0 {
+		return nil, fmt.Errorf("no text content in gateway stream")
+	}
+	return &Response{Text: sb.String(), Thinking: plainThinking(thinking.String()), Usage: usage}, nil
+}
@@ -0,0 +1,336 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dialectServer answers every request with reply, as server-sent events
+// when sse is set, and records the last request.
+type dialectServer struct {
+	*httptest.Server
+	body   map[string]any
+	header http.Header
+}
+
+func newDialectServer(t *testing.T, reply string, sse bool) *dialectServer {
+	t.Helper()
+	s := &dialectServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.body, s.header = nil, r.Header
+		_ = json.NewDecoder(r.Body).Decode(&s.body)
+		if sse {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		fmt.Fprint(w, reply)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func sse(events ...string) string {
+	var sb strings.Builder
+	for _, ev := range events {
+		fmt.Fprintf(&sb, "data: %s\n\n", ev)
+	}
+	return sb.String()
+}
+
+var dialectMessages = []Message{
+	{Role: "user", Content: "what is this?", Images: []string{"aGVsbG8="}},
+	{Role: "assistant", Content: "A greeting."},
+	{Role: "user", Content: "Sure?"},
+}
+
+func TestGatewayOpenAIChatDialect(t *testing.T) {
+	srv := newDialectServer(t, `{"choices":[{"message":{"content":"yes"}}],"usage":{"prompt_tokens":20,"completion_tokens":2,"prompt_tokens_details":{"cached_tokens":5}}}`, false)
+	temp := 0.2
+	resp, err := New().Complete(context.Background(), Config{
+		Model:   "claude-sonnet-4-6",
+		Gateway: &Gateway{URL: srv.URL, Dialect: DialectOpenAIChat},
+		Params:  map[string]Params{"claude-sonnet-4-6": {Temperature: &temp, MaxTokens: 500}},
+	}, "be brief", dialectMessages, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "yes" || resp.Usage != (Usage{InputTokens: 15, OutputTokens: 2, CacheReadTokens: 5}) {
+		t.Fatalf("Complete() = %q, usage %+v", resp.Text, resp.Usage)
+	}
+
+	msgs := srv.body["messages"].([]any)
+	system := msgs[0].(map[string]any)
+	if len(msgs) != 4 || system["role"] != "system" || system["content"] != "be brief" {
+		t.Fatalf("messages = %v, want the system prompt first even for Claude", msgs)
+	}
+	image := msgs[1].(map[string]any)["content"].([]any)[0].(map[string]any)
+	if image["type"] != "image_url" {
+		t.Fatalf("image part = %v, want image_url", image)
+	}
+	if srv.body["max_completion_tokens"] != 500.0 || srv.body["temperature"] != 0.2 || srv.body["parameters"] != nil || srv.body["system"] != nil {
+		t.Fatalf("body = %v, want Chat Completions fields only", srv.body)
+	}
+}
+
+func TestGatewayAnthropicMessagesDialect(t *testing.T) {
+	srv := newDialectServer(t, sse(
+		`{"type":"message_start","message":{"usage":{"input_tokens":12,"output_tokens":1}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"ok"}}`,
+		`{"type":"message_delta","usage":{"output_tokens":5}}`,
+	), true)
+	resp, err := New().Complete(context.Background(), Config{
+		Model:   "gpt-4.1",
+		Gateway: &Gateway{URL: srv.URL, Dialect: DialectAnthropicMessages},
+		Params:  map[string]Params{"gpt-4.1": {ThinkingBudget: 2000}},
+	}, "be brief", dialectMessages, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "ok" || resp.ThinkingText() != "hmm" || resp.Usage != (Usage{InputTokens: 12, OutputTokens: 5}) {
+		t.Fatalf("Complete() = %+v", resp)
+	}
+
+	if srv.header.Get("anthropic-version") != anthropicAPIVersion {
+		t.Fatalf("anthropic-version = %q", srv.header.Get("anthropic-version"))
+	}
+	if srv.body["system"] != "be brief" || len(srv.body["messages"].([]any)) != 3 {
+		t.Fatalf("body = %v, want a top-level system prompt", srv.body)
+	}
+	image := srv.body["messages"].([]any)[0].(map[string]any)["content"].([]any)[0].(map[string]any)
+	source, _ := image["source"].(map[string]any)
+	if image["type"] != "image" || source["type"] != "base64" || source["data"] != "aGVsbG8=" {
+		t.Fatalf("image part = %v, want a base64 source", image)
+	}
+	if srv.body["max_tokens"] == nil || srv.body["parameters"] != nil {
+		t.Fatalf("body = %v, want max_tokens", srv.body)
+	}
+}
+
+func TestGatewayOpenAIResponsesDialect(t *testing.T) {
+	srv := newDialectServer(t, sse(
+		`{"type":"response.created","response":{}}`,
+		`{"type":"response.reasoning_summary_text.delta","delta":"thinking"}`,
+		`{"type":"response.output_text.delta","delta":"Hel"}`,
+		`{"type":"response.output_text.delta","delta":"lo"}`,
+		`{"type":"response.completed","response":{"usage":{"input_tokens":30,"output_tokens":4,"input_tokens_details":{"cached_tokens":10}}}}`,
+	), true)
+	var deltas []string
+	resp, err := New().Complete(context.Background(), Config{
+		Model:   "gpt-4.1",
+		Gateway: &Gateway{URL: srv.URL, Dialect: DialectOpenAIResponses},
+	}, "be brief", dialectMessages, func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "Hello" || strings.Join(deltas, "|") != "Hel|lo" || resp.ThinkingText() != "thinking" {
+		t.Fatalf("Complete() = %+v with deltas %q", resp, deltas)
+	}
+	if resp.Usage != (Usage{InputTokens: 20, OutputTokens: 4, CacheReadTokens: 10}) {
+		t.Fatalf("Usage = %+v", resp.Usage)
+	}
+
+	if srv.body["instructions"] != "be brief" || srv.body["store"] != false {
+		t.Fatalf("body = %v, want instructions and store=false", srv.body)
+	}
+	input := srv.body["input"].([]any)
+	var types []string
+	for _, item := range input {
+		for _, part := range item.(map[string]any)["content"].([]any) {
+			types = append(types, part.(map[string]any)["type"].(string))
+		}
+	}
+	if want := []string{"input_image", "input_text", "output_text", "input_text"}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("content types = %v, want %v", types, want)
+	}
+
+	// A finished response, for gateways that ignore the stream flag.
+	srv = newDialectServer(t, `{"output":[{"type":"reasoning","summary":[{"type":"summary_text","text":"hm"}]},{"type":"message","content":[{"type":"output_text","text":"done"}]}],"usage":{"input_tokens":3,"output_tokens":1}}`, false)
+	resp, err = New().Complete(context.Background(), Config{
+		Model:   "gpt-4.1",
+		Gateway: &Gateway{URL: srv.URL, Dialect: DialectOpenAIResponses},
+	}, "", []Message{{Role: "user", Content: "hi"}}, nil)
+	if err != nil || resp.Text != "done" || resp.ThinkingText() != "hm" {
+		t.Fatalf("Complete() = %+v, %v", resp, err)
+	}
+}
+
+func TestGatewayOpenAIDialectsReasoningParams(t *testing.T) {
+	temp := 0.2
+	for dialect, reply := range map[string]string{
+		DialectOpenAIChat:      `{"choices":[{"message":{"content":"ok"}}]}`,
+		DialectOpenAIResponses: `{"output":[{"type":"message","content":[{"type":"output_text","text":"ok"}]}]}`,
+	} {
+		for _, model := range []string{"gpt-5-mini", "gpt-4.1"} {
+			srv := newDialectServer(t, reply, false)
+			_, err := New().Complete(context.Background(), Config{
+				Model:   model,
+				Gateway: &Gateway{URL: srv.URL, Dialect: dialect},
+				Params:  map[string]Params{model: {ThinkingBudget: 2000, Temperature: &temp}},
+			}, "", []Message{{Role: "user", Content: "hi"}}, nil)
+			if err != nil {
+				t.Fatalf("%s %s: %v", dialect, model, err)
+			}
+			effort := srv.body["reasoning_effort"]
+			if r, ok := srv.body["reasoning"].(map[string]any); ok {
+				effort = r["effort"]
+			}
+			// The thinking budget stands in for an effort; reasoning models
+			// reject sampling parameters.
+			if reasoning := model != "gpt-4.1"; reasoning && effort != "low" || !reasoning && effort != nil {
+				t.Errorf("%s %s: effort = %v", dialect, model, effort)
+			}
+			if _, sent := srv.body["temperature"]; sent != (model == "gpt-4.1") {
+				t.Errorf("%s %s: temperature sent = %v", dialect, model, sent)
+			}
+		}
+	}
+}
+
+func TestGatewayCustomDialect(t *testing.T) {
+	custom := &CustomDialect{
+		Request: json.RawMessage(`{
+			"engine": "{{model}}",
+			"input": {"history": "{{messages}}", "question": "{{prompt}}", "preamble": "Rules: {{system}}"},
+			"options": {"max_new_tokens": "{{maxTokens}}", "temperature": "{{temperature}}", "priority": 3}
+		}`),
+		Text:         "$.result.outputs[*].text",
+		Thinking:     "$.result.notes",
+		InputTokens:  "$.meta['tokens in']",
+		OutputTokens: "$.meta['tokens out']",
+		Error:        "$.fault.reason",
+	}
+	srv := newDialectServer(t, `{"result":{"outputs":[{"text":"one "},{"text":"two"}],"notes":"hm"},"meta":{"tokens in":7,"tokens out":2}}`, false)
+	var deltas []string
+	resp, err := New().Complete(context.Background(), Config{
+		Model:   "gpt-4.1",
+		Gateway: &Gateway{URL: srv.URL, Dialect: DialectCustom, Custom: custom},
+		Params:  map[string]Params{"gpt-4.1": {MaxTokens: 300}},
+	}, "be brief", dialectMessages, func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "one two" || resp.ThinkingText() != "hm" || resp.Usage != (Usage{InputTokens: 7, OutputTokens: 2}) || len(deltas) != 1 {
+		t.Fatalf("Complete() = %+v with deltas %q", resp, deltas)
+	}
+
+	if srv.body["engine"] != "gpt-4.1" || srv.body["stream"] != nil {
+		t.Fatalf("body = %v", srv.body)
+	}
+	input := srv.body["input"].(map[string]any)
+	history := input["history"].([]any)
+	if len(history) != 3 || history[2].(map[string]any)["content"] != "Sure?" || input["question"] != "Sure?" || input["preamble"] != "Rules: be brief" {
+		t.Fatalf("input = %v", input)
+	}
+	options := srv.body["options"].(map[string]any)
+	if _, ok := options["temperature"]; ok || options["max_new_tokens"] != 300.0 || options["priority"] != 3.0 {
+		t.Fatalf("options = %v, want unset values left out and numbers kept", options)
+	}
+
+	srv = newDialectServer(t, `{"fault":{"reason":"quota exceeded"}}`, false)
+	_, err = New().Complete(context.Background(), Config{
+		Model:   "gpt-4.1",
+		Gateway: &Gateway{URL: srv.URL, Dialect: DialectCustom, Custom: custom},
+	}, "", []Message{{Role: "user", Content: "hi"}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Fatalf("err = %v, want the gateway's error", err)
+	}
+}
+
+func TestGatewayCustomDialectStreams(t *testing.T) {
+	srv := newDialectServer(t, sse(`{"token":"Hel"}`, `{"token":"lo","usage":{"in":4,"out":2}}`, "[DONE]"), true)
+	var deltas []string
+	resp, err := New().Complete(context.Background(), Config{
+		Model: "gpt-4.1",
+		Gateway: &Gateway{URL: srv.URL, Dialect: DialectCustom, Custom: &CustomDialect{
+			Request:      json.RawMessage(`{"prompt":"{{prompt}}","stream":"{{stream}}"}`),
+			Text:         "$.text",
+			StreamText:   "$.token",
+			InputTokens:  "$.usage.in",
+			OutputTokens: "$.usage.out",
+		}},
+	}, "", []Message{{Role: "user", Content: "hi"}}, func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.body["stream"] != true {
+		t.Fatalf("body = %v, want stream=true", srv.body)
+	}
+	if resp.Text != "Hello" || strings.Join(deltas, "|") != "Hel|lo" || resp.Usage != (Usage{InputTokens: 4, OutputTokens: 2}) {
+		t.Fatalf("Complete() = %+v with deltas %q", resp, deltas)
+	}
+}
+
+func TestGatewayDialectErrors(t *testing.T) {
+	for _, gw := range []*Gateway{
+		{URL: "https://gw.invalid", Dialect: "soap"},
+		{URL: "https://gw.invalid", Dialect: DialectCustom},
+		{URL: "https://gw.invalid", Dialect: DialectCustom, Custom: &CustomDialect{Request: json.RawMessage(`{"x":"{{nope}}"}`), Text: "$.t"}},
+		{URL: "https://gw.invalid", Dialect: DialectCustom, Custom: &CustomDialect{Request: json.RawMessage(`{"x":`), Text: "$.t"}},
+	} {
+		_, err := New().Complete(context.Background(), Config{Model: "gpt-4.1", Gateway: gw}, "", []Message{{Role: "user", Content: "hi"}}, nil)
+		if err == nil || strings.Contains(err.Error(), "gateway request failed") {
+			t.Errorf("dialect %q: err = %v, want a configuration error before sending", gw.Dialect, err)
+		}
+	}
+}
+
+func TestSelectJSON(t *testing.T) {
+	doc, err := decodeJSON([]byte(`{"a":{"b":[{"c":"x"},{"c":"y"}],"d e":1},"z":"last"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		selector string
+		want     string
+	}{
+		{"$.a.b[0].c", "x"},
+		{"$.a.b[-1].c", "y"},
+		{"$.a.b[*].c", "xy"},
+		{`$.a["d e"]`, "1"},
+		{"$.*", "last"},
+		{"$.a.missing[0]", ""},
+		{"$.a.b[5].c", ""},
+	} {
+		if got, err := selectText(doc, tc.selector); err != nil || got != tc.want {
+			t.Errorf("selectText(%s) = %q, %v, want %q", tc.selector, got, err, tc.want)
+		}
+	}
+	for _, bad := range []string{"a.b", "$.a[", "$.a[x]", "$..a"} {
+		if _, err := selectJSON(doc, bad); err == nil {
+			t.Errorf("selectJSON(%s) should fail", bad)
+		}
+	}
+}
+
+func TestGatewayDropsTools(t *testing.T) {
+	srv := newDialectServer(t, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`, false)
+	cfg := Config{
+		Model:   "claude-sonnet-4-6",
+		Gateway: &Gateway{URL: srv.URL, Dialect: DialectOpenAIChat},
+		Tools:   []Tool{{Name: "read_notes", Parameters: map[string]any{"type": "object"}}},
+	}
+	msgs := []Message{{Role: "user", Content: "hi"}}
+
+	_, notices, err := New().Fit(cfg, msgs)
+	if err != nil || len(notices) != 1 || !strings.Contains(notices[0], "cannot call tools through the gateway") {
+		t.Fatalf("Fit() = %q, %v, want a notice that tools were dropped", notices, err)
+	}
+	if _, err := New().Send(context.Background(), cfg, "", msgs); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.body["tools"]; ok {
+		t.Fatalf("body = %v, want no tools", srv.body)
+	}
+
+	// Without tools there is nothing to report.
+	cfg.Tools = nil
+	if _, notices, _ := New().Fit(cfg, msgs); len(notices) != 0 {
+		t.Fatalf("Fit() without tools = %q", notices)
+	}
+}
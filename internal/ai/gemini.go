@@ -0,0 +1,264 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GeminiProvider is the provider ID of the Gemini API.
+const GeminiProvider = "gemini"
+
+const geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// geminiProvider calls the Gemini generateContent REST API directly.
+type geminiProvider struct {
+	baseURL string // overridden in tests
+}
+
+func (geminiProvider) ID() string { return GeminiProvider }
+
+type geminiPart struct {
+	Text       string            `json:"text,omitempty"`
+	Thought    bool              `json:"thought,omitempty"` // Text is a thought summary
+	InlineData *geminiInlineData `json:"inlineData,omitempty"`
+}
+
+type geminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"` // "user" or "model"
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent         `json:"contents"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens int                   `json:"maxOutputTokens,omitempty"`
+	Temperature     *float64              `json:"temperature,omitempty"`
+	TopP            *float64              `json:"topP,omitempty"`
+	StopSequences   []string              `json:"stopSequences,omitempty"`
+	ThinkingConfig  *geminiThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type geminiThinkingConfig struct {
+	ThinkingBudget  int  `json:"thinkingBudget"`
+	IncludeThoughts bool `json:"includeThoughts"`
+}
+
+// geminiGeneration maps p to a generationConfig, or nil when nothing is set.
+func geminiGeneration(p Params) *geminiGenerationConfig {
+	gc := geminiGenerationConfig{
+		MaxOutputTokens: p.maxTokens(0),
+		Temperature:     p.Temperature,
+		TopP:            p.TopP,
+		StopSequences:   p.Stop,
+	}
+	if budget := p.thinkingBudget(); budget > 0 {
+		gc.ThinkingConfig = &geminiThinkingConfig{ThinkingBudget: budget, IncludeThoughts: true}
+	}
+	if gc.MaxOutputTokens == 0 && gc.Temperature == nil && gc.TopP == nil && gc.StopSequences == nil && gc.ThinkingConfig == nil {
+		return nil
+	}
+	return &gc
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback,omitempty"`
+	UsageMetadata *struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"` // part of the prompt count
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount      int `json:"thoughtsTokenCount"` // billed as output
+	} `json:"usageMetadata,omitempty"`
+	Error *geminiErrorPayload `json:"error,omitempty"`
+}
+
+// usage returns the token counts; in a stream each chunk carries the
+// running totals.
+func (r geminiResponse) usage() (Usage, bool) {
+	if r.UsageMetadata == nil {
+		return Usage{}, false
+	}
+	return Usage{
+		InputTokens:     r.UsageMetadata.PromptTokenCount - r.UsageMetadata.CachedContentTokenCount,
+		OutputTokens:    r.UsageMetadata.CandidatesTokenCount + r.UsageMetadata.ThoughtsTokenCount,
+		CacheReadTokens: r.UsageMetadata.CachedContentTokenCount,
+	}, true
+}
+
+type geminiErrorPayload struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+func (p geminiProvider) Send(ctx context.Context, cfg Config, req Request, onDelta DeltaFunc) (*Response, error) {
+	if cfg.GeminiKey == "" {
+		return nil, fmt.Errorf("Gemini API key not set — open Settings to add your key")
+	}
+
+	body := geminiRequest{Contents: make([]geminiContent, 0, len(req.Messages)), GenerationConfig: geminiGeneration(req.Params)}
+	if req.System != "" {
+		body.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: req.System}}}
+	}
+	for _, m := range req.Messages {
+		role := "user"
+		if m.Role == "assistant" {
+			role = "model"
+		}
+		var parts []geminiPart
+		if m.Role == "user" {
+			for _, doc := range m.Documents {
+				if doc.isPDF() {
+					parts = append(parts, geminiPart{InlineData: &geminiInlineData{MimeType: mimePDF, Data: doc.Data}})
+				} else {
+					parts = append(parts, geminiPart{Text: doc.text()})
+				}
+			}
+			for _, img := range m.Images {
+				parts = append(parts, geminiPart{InlineData: &geminiInlineData{MimeType: imageMIME(img), Data: img}})
+			}
+		}
+		if m.Content != "" {
+			parts = append(parts, geminiPart{Text: m.Content})
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		body.Contents = append(body.Contents, geminiContent{Role: role, Parts: parts})
+	}
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Gemini request: %w", err)
+	}
+
+	base := p.baseURL
+	if base == "" {
+		base = geminiBaseURL
+	}
+	endpoint := base + "/models/" + url.PathEscape(req.Model.ID) + ":generateContent"
+	if onDelta != nil {
+		endpoint = base + "/models/" + url.PathEscape(req.Model.ID) + ":streamGenerateContent?alt=sse"
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", cfg.GeminiKey)
+
+	resp, err := cfg.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("Gemini request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBytes, _ := io.ReadAll(resp.Body)
+		var gResp geminiResponse
+		if json.Unmarshal(respBytes, &gResp) == nil && gResp.Error != nil {
+			return nil, httpError(GeminiProvider, resp, "Gemini API error: "+gResp.Error.Message)
+		}
+		return nil, httpError(GeminiProvider, resp, fmt.Sprintf("Gemini returned status %d: %s", resp.StatusCode, string(respBytes)))
+	}
+
+	if onDelta != nil {
+		return streamGemini(resp.Body, cfg, onDelta)
+	}
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Gemini response: %w", err)
+	}
+	var gResp geminiResponse
+	if err := json.Unmarshal(respBytes, &gResp); err != nil {
+		return nil, fmt.Errorf("failed to parse Gemini response: %w", err)
+	}
+	text, thought, err := geminiText(gResp)
+	if err != nil {
+		return nil, err
+	}
+	if text == "" {
+		return nil, fmt.Errorf("no text content in Gemini response")
+	}
+	usage, _ := gResp.usage()
+	return &Response{Text: text, Thinking: plainThinking(thought), Usage: usage}, nil
+}
+
+func streamGemini(body io.Reader, cfg Config, onDelta DeltaFunc) (*Response, error) {
+	var sb, thoughts strings.Builder
+	var usage Usage
+	err := readSSE(body, func(data []byte) error {
+		var chunk geminiResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to parse Gemini stream event: %w", err)
+		}
+		text, thought, err := geminiText(chunk)
+		if err != nil {
+			return err
+		}
+		if thought != "" {
+			thoughts.WriteString(thought)
+			cfg.thinking(thought)
+		}
+		if u, ok := chunk.usage(); ok {
+			usage = u
+		}
+		if text != "" {
+			sb.WriteString(text)
+			onDelta(text)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sb.Len() == 0 {
+		return nil, fmt.Errorf("no text content in Gemini response")
+	}
+	return &Response{Text: sb.String(), Thinking: plainThinking(thoughts.String()), Usage: usage}, nil
+}
+
+// geminiText joins the answer and the thought-summary parts of the first
+// candidate.
+func geminiText(resp geminiResponse) (text, thought string, err error) {
+	if resp.Error != nil {
+		return "", "", fmt.Errorf("Gemini API error: %s", resp.Error.Message)
+	}
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		return "", "", fmt.Errorf("Gemini blocked the prompt: %s", resp.PromptFeedback.BlockReason)
+	}
+	if len(resp.Candidates) == 0 {
+		return "", "", nil
+	}
+	var sb, thoughts strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part.Thought {
+			thoughts.WriteString(part.Text)
+		} else {
+			sb.WriteString(part.Text)
+		}
+	}
+	return sb.String(), thoughts.String(), nil
+}
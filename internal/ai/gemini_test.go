@@ -0,0 +1,100 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newGeminiTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c := New()
+	c.Registry().Register(geminiProvider{baseURL: srv.URL})
+	return c
+}
+
+func TestGeminiGenerateContent(t *testing.T) {
+	var got geminiRequest
+	c := newGeminiTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-2.5-flash:generateContent" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if r.Header.Get("x-goog-api-key") != "gk" {
+			t.Errorf("missing API key header")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi "},{"text":"there"}]}}]}`)
+	})
+
+	reply, err := c.Send(context.Background(), Config{GeminiKey: "gk", Model: "gemini-2.5-flash"}, "be brief", []Message{
+		{Role: "user", Content: "what is this?", Images: []string{"aGVsbG8="}},
+		{Role: "assistant", Content: "a picture"},
+	})
+	if err != nil || reply != "Hi there" {
+		t.Fatalf("Send() = %q, %v", reply, err)
+	}
+
+	if got.SystemInstruction == nil || got.SystemInstruction.Parts[0].Text != "be brief" {
+		t.Fatalf("system prompt should go to systemInstruction, got %+v", got.SystemInstruction)
+	}
+	if len(got.Contents) != 2 || got.Contents[1].Role != "model" {
+		t.Fatalf("unexpected contents: %+v", got.Contents)
+	}
+	img := got.Contents[0].Parts[0].InlineData
+	if img == nil || img.Data != "aGVsbG8=" || img.MimeType == "" {
+		t.Fatalf("image should be sent as inline data, got %+v", got.Contents[0].Parts)
+	}
+}
+
+func TestGeminiStreamGenerateContent(t *testing.T) {
+	c := newGeminiTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, ":streamGenerateContent") || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("unexpected stream URL %q", r.URL)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i, part := range []string{"one ", "two"} {
+			fmt.Fprintf(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":%q}]}}],\"usageMetadata\":{\"promptTokenCount\":7,\"candidatesTokenCount\":%d}}\n\n", part, i+1)
+		}
+	})
+
+	var deltas []string
+	resp, err := c.Complete(context.Background(), Config{GeminiKey: "gk", Model: "gemini-2.5-pro"}, "", []Message{{Role: "user", Content: "count"}}, func(d string) {
+		deltas = append(deltas, d)
+	})
+	if err != nil || resp.Text != "one two" || len(deltas) != 2 {
+		t.Fatalf("Complete() = %+v, %v with deltas %q", resp, err, deltas)
+	}
+	if resp.Usage != (Usage{InputTokens: 7, OutputTokens: 2}) {
+		t.Fatalf("Usage = %+v, want the totals from the last chunk", resp.Usage)
+	}
+}
+
+func TestGeminiReturnsThoughtsSeparately(t *testing.T) {
+	c := newGeminiTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"Checking the notes.","thought":true},{"text":"Friday."}]}}]}`)
+	})
+	resp, err := c.Complete(context.Background(), Config{GeminiKey: "gk", Model: "gemini-2.5-pro"}, "", []Message{{Role: "user", Content: "when?"}}, nil)
+	if err != nil || resp.Text != "Friday." || resp.ThinkingText() != "Checking the notes." {
+		t.Fatalf("Complete() = %+v, %v", resp, err)
+	}
+}
+
+func TestGeminiErrorMessage(t *testing.T) {
+	c := newGeminiTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"code":400,"message":"API key not valid","status":"INVALID_ARGUMENT"}}`)
+	})
+
+	_, err := c.Send(context.Background(), Config{GeminiKey: "bad", Model: "gemini-2.5-flash"}, "", []Message{{Role: "user", Content: "hi"}})
+	if err == nil || !strings.Contains(err.Error(), "API key not valid") {
+		t.Fatalf("expected Gemini error message, got %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package ai
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"sync"
+
+	_ "image/gif" // register the GIF decoder
+)
+
+// imageLimits is the largest image a provider uses as-is. Bigger images are
+// downscaled on the provider's side anyway, so sending them only costs
+// upload time and, for some providers, tokens.
+type imageLimits struct {
+	maxEdge      int // longest side in pixels
+	maxShortEdge int // shortest side in pixels, 0 for no limit
+	maxPixels    int // width × height, 0 for no limit
+}
+
+// providerImageLimits follows each provider's published guidance. The
+// gateway can end up at any vendor, so it gets the strictest limits.
+var providerImageLimits = map[string]imageLimits{
+	AnthropicProvider: {maxEdge: 1568, maxPixels: 1_150_000},
+	OpenAIProvider:    {maxEdge: 2048, maxShortEdge: 768},
+	GeminiProvider:    {maxEdge: 3072},
+	LocalProvider:     {maxEdge: 1024},
+	GatewayProvider:   {maxEdge: 1568, maxShortEdge: 768, maxPixels: 1_150_000},
+}
+
+// maxDecodePixels is the largest image decoded for downscaling. A few bytes
+// of compressed PNG can claim far more pixels than fit in memory, so bigger
+// images are sent as they are, for the provider to refuse.
+const maxDecodePixels = 40_000_000
+
+// jpegQuality keeps screenshot text legible at a fraction of PNG's size.
+const jpegQuality = 85
+
+// maxCachedImages bounds the image cache. A chat re-sends every image on
+// every turn, so it only needs to hold the current conversation's.
+const maxCachedImages = 64
+
+// imageCache remembers processed images by a hash of the original and the
+// limits they were fitted to, so later turns of a chat skip the work. An
+// empty entry means the original is sent unchanged.
+type imageCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]string
+	order   [][sha256.Size]byte // oldest first
+}
+
+func (c *imageCache) get(key [sha256.Size]byte) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	img, ok := c.entries[key]
+	return img, ok
+}
+
+func (c *imageCache) put(key [sha256.Size]byte, img string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[[sha256.Size]byte]string)
+	}
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	if len(c.order) >= maxCachedImages {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[key] = img
+	c.order = append(c.order, key)
+}
+
+// prepareImages returns messages with every image fitted to the provider's
+// limits. The caller's messages are not modified.
+func (c *Client) prepareImages(providerID string, messages []Message) []Message {
+	lim, ok := providerImageLimits[providerID]
+	if !ok {
+		return messages
+	}
+	var out []Message
+	for i, m := range messages {
+		if len(m.Images) == 0 {
+			continue
+		}
+		if out == nil {
+			out = append([]Message(nil), messages...)
+		}
+		images := make([]string, len(m.Images))
+		for j, img := range m.Images {
+			images[j] = c.fitImage(img, lim)
+		}
+		out[i].Images = images
+	}
+	if out == nil {
+		return messages
+	}
+	return out
+}
+
+// fitImage returns img fitted to lim, from the cache when it has been seen
+// before. Images that cannot be decoded (e.g. WebP) or are too large to
+// decode are sent unchanged.
+func (c *Client) fitImage(img string, lim imageLimits) string {
+	h := sha256.New()
+	io.WriteString(h, img)
+	fmt.Fprintf(h, "|%d|%d|%d", lim.maxEdge, lim.maxShortEdge, lim.maxPixels)
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+
+	out, ok := c.images.get(key)
+	if !ok {
+		var err error
+		if out, err = processImage(img, lim); err != nil || out == img {
+			out = ""
+		}
+		c.images.put(key, out)
+	}
+	if out == "" {
+		return img
+	}
+	return out
+}
+
+// processImage decodes img, downscales it to fit lim and re-encodes it as
+// JPEG, or PNG when it has transparency or PNG turns out smaller. The
+// original is kept when it already fits and re-encoding would not shrink it.
+func processImage(img string, lim imageLimits) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(img)
+	if err != nil {
+		return "", err
+	}
+	conf, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if conf.Width <= 0 || conf.Height <= 0 || conf.Width*conf.Height > maxDecodePixels {
+		return "", fmt.Errorf("image of %d×%d pixels is too large to process", conf.Width, conf.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	b := src.Bounds()
+	w, h := fitSize(b.Dx(), b.Dy(), lim)
+	resized := w != b.Dx() || h != b.Dy()
+
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	if resized {
+		rgba = downscale(rgba, w, h)
+	}
+
+	var best []byte
+	if rgba.Opaque() {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, rgba, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return "", err
+		}
+		best = buf.Bytes()
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, rgba); err != nil {
+		return "", err
+	}
+	if best == nil || buf.Len() < len(best) {
+		best = buf.Bytes()
+	}
+	if !resized && len(best) >= len(data) {
+		return img, nil
+	}
+	return base64.StdEncoding.EncodeToString(best), nil
+}
+
+// fitSize scales w×h down, keeping the aspect ratio, until it satisfies lim.
+func fitSize(w, h int, lim imageLimits) (int, int) {
+	long, short := max(w, h), min(w, h)
+	scale := 1.0
+	if lim.maxEdge > 0 && long > lim.maxEdge {
+		scale = math.Min(scale, float64(lim.maxEdge)/float64(long))
+	}
+	if lim.maxShortEdge > 0 && short > lim.maxShortEdge {
+		scale = math.Min(scale, float64(lim.maxShortEdge)/float64(short))
+	}
+	if lim.maxPixels > 0 && w*h > lim.maxPixels {
+		scale = math.Min(scale, math.Sqrt(float64(lim.maxPixels)/float64(w*h)))
+	}
+	if scale == 1 {
+		return w, h
+	}
+	return max(1, int(float64(w)*scale)), max(1, int(float64(h)*scale))
+}
+
+// downscale shrinks src to w×h by averaging the block of source pixels under
+// each destination pixel, which keeps thin text strokes from dropping out the
+// way nearest-neighbour sampling would. Colours are premultiplied, so
+// transparent pixels do not bleed into their neighbours.
+func downscale(src *image.RGBA, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += uint64(row[i])
+					g += uint64(row[i+1])
+					bl += uint64(row[i+2])
+					a += uint64(row[i+3])
+					n++
+				}
+			}
+			o := y*dst.Stride + x*4
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(bl / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,121 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"testing"
+)
+
+// testImage returns a w×h PNG of noise, which JPEG compresses better than
+// PNG, as base64. With alpha set one pixel is transparent.
+func testImage(t *testing.T, w, h int, alpha bool) string {
+	t.Helper()
+	rng := rand.New(rand.NewPCG(1, 2))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := rng.Uint32()
+			img.Set(x, y, color.NRGBA{uint8(v), uint8(v >> 8), uint8(v >> 16), 255})
+		}
+	}
+	if alpha {
+		img.Set(0, 0, color.NRGBA{})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func decodeTestImage(t *testing.T, b64 string) (image.Config, string) {
+	t.Helper()
+	data, _ := base64.StdEncoding.DecodeString(b64)
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg, format
+}
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		w, h  int
+		lim   imageLimits
+		wantW int
+		wantH int
+	}{
+		{800, 600, providerImageLimits[AnthropicProvider], 800, 600},
+		{3024, 1964, imageLimits{maxEdge: 1568}, 1568, 1018},
+		{4000, 3000, imageLimits{maxEdge: 2048, maxShortEdge: 768}, 1024, 768},
+		{2000, 2000, imageLimits{maxEdge: 1568, maxPixels: 1_150_000}, 1072, 1072},
+	}
+	for _, tt := range tests {
+		if w, h := fitSize(tt.w, tt.h, tt.lim); w != tt.wantW || h != tt.wantH {
+			t.Errorf("fitSize(%d, %d) = %d×%d, want %d×%d", tt.w, tt.h, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestPrepareImagesDownscalesAndCaches(t *testing.T) {
+	c := New()
+	big := testImage(t, 3000, 1500, false)
+	msgs := []Message{{Role: "user", Content: "look", Images: []string{big}}}
+
+	out := c.prepareImages(LocalProvider, msgs)
+	if msgs[0].Images[0] != big {
+		t.Fatal("the caller's message was modified")
+	}
+	cfg, format := decodeTestImage(t, out[0].Images[0])
+	if cfg.Width != 1024 || cfg.Height != 512 || format != "jpeg" {
+		t.Fatalf("got %s %d×%d, want jpeg 1024×512", format, cfg.Width, cfg.Height)
+	}
+
+	again := c.prepareImages(LocalProvider, msgs)
+	if again[0].Images[0] != out[0].Images[0] || len(c.images.entries) != 1 {
+		t.Fatalf("second turn should be served from the cache, %d entries", len(c.images.entries))
+	}
+
+	c.prepareImages(AnthropicProvider, msgs)
+	if len(c.images.entries) != 2 {
+		t.Fatal("each provider's limits need their own cache entry")
+	}
+}
+
+func TestPrepareImagesKeepsTransparencyAndUnknownFormats(t *testing.T) {
+	c := New()
+	clear := testImage(t, 2000, 100, true)
+	webp := base64.StdEncoding.EncodeToString([]byte("RIFF\x24\x00\x00\x00WEBPVP8 "))
+	out := c.prepareImages(LocalProvider, []Message{{Role: "user", Images: []string{clear, webp}}})
+
+	if _, format := decodeTestImage(t, out[0].Images[0]); format != "png" {
+		t.Fatalf("transparent image re-encoded as %s, want png", format)
+	}
+	if out[0].Images[1] != webp {
+		t.Fatal("undecodable image should be sent unchanged")
+	}
+}
+
+func TestPrepareImagesSkipsHugeImages(t *testing.T) {
+	// A tiny PNG whose header claims 50000×50000 pixels must not be decoded.
+	data, _ := base64.StdEncoding.DecodeString(testImage(t, 1, 1, false))
+	ihdr := data[12:29] // chunk type and data
+	binary.BigEndian.PutUint32(ihdr[4:], 50_000)
+	binary.BigEndian.PutUint32(ihdr[8:], 50_000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(ihdr))
+	huge := base64.StdEncoding.EncodeToString(data)
+	if cfg, _ := decodeTestImage(t, huge); cfg.Width != 50_000 {
+		t.Fatalf("test image claims %d×%d", cfg.Width, cfg.Height)
+	}
+
+	out := New().prepareImages(AnthropicProvider, []Message{{Role: "user", Images: []string{huge}}})
+	if out[0].Images[0] != huge {
+		t.Fatal("oversized image should be sent unchanged")
+	}
+}
@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// LocalProvider is the provider ID of the local model server. Its models
+// are written "local/<name>" and never go through a gateway.
+const LocalProvider = "local"
+
+// localProvider talks to a model server on the user's machine or network
+// (Ollama, llama-server, LM Studio…) through its OpenAI-compatible API.
+// No API key is sent.
+type localProvider struct{}
+
+func (localProvider) ID() string { return LocalProvider }
+
+func (localProvider) Send(ctx context.Context, cfg Config, req Request, onDelta DeltaFunc) (*Response, error) {
+	if cfg.LocalURL == "" {
+		return nil, fmt.Errorf("local model server URL not set — open Settings to add it")
+	}
+
+	ocfg := openai.DefaultConfig("")
+	ocfg.BaseURL = localBaseURL(cfg.LocalURL) + "/v1"
+	httpClient, retryAfter := recordRetryAfter(cfg.httpClient())
+	ocfg.HTTPClient = httpClient
+	client := openai.NewClientWithConfig(ocfg)
+
+	// Local servers have no file input, so PDFs are replaced by a note.
+	messages, _ := openAIMessages(req, req.Model.Capabilities.Vision, false)
+	chatReq := openai.ChatCompletionRequest{
+		Model:    req.Model.ID,
+		Messages: messages,
+		Tools:    openAITools(req.Tools),
+	}
+	applyOpenAIParams(&chatReq, req, false)
+	resp, err := completeOpenAI(ctx, cfg, client, chatReq, onDelta, "local model")
+	return resp, retryAfter.annotate(err)
+}
+
+// localBaseURL accepts the server root with or without the /v1 suffix.
+func localBaseURL(raw string) string {
+	u := strings.TrimRight(strings.TrimSpace(raw), "/")
+	return strings.TrimSuffix(u, "/v1")
+}
+
+// ListLocalModels asks the server at baseURL for its models. Ollama's
+// /api/tags is tried first because it reports which models accept images;
+// other servers are asked through the OpenAI-compatible /v1/models.
+// Returned IDs are the server's own names, with Provider set to "local".
+func ListLocalModels(ctx context.Context, client *http.Client, baseURL string) ([]Model, error) {
+	base := localBaseURL(baseURL)
+	if base == "" {
+		return nil, fmt.Errorf("local model server URL not set")
+	}
+
+	if models, err := listOllamaTags(ctx, client, base); err == nil {
+		return models, nil
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, client, base+"/v1/models", &list); err != nil {
+		return nil, fmt.Errorf("list local models: %w", err)
+	}
+	models := make([]Model, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, Model{
+			ID:           m.ID,
+			Provider:     LocalProvider,
+			Name:         m.ID,
+			Capabilities: Capabilities{Streaming: true},
+		})
+	}
+	return models, nil
+}
+
+func listOllamaTags(ctx context.Context, client *http.Client, base string) ([]Model, error) {
+	var tags struct {
+		Models []struct {
+			Name    string `json:"name"`
+			Details struct {
+				Families []string `json:"families"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	if err := getJSON(ctx, client, base+"/api/tags", &tags); err != nil {
+		return nil, err
+	}
+	models := make([]Model, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		vision := false
+		for _, f := range m.Details.Families {
+			// Multimodal models ship a vision projector family alongside the LLM.
+			if f == "clip" || f == "mllama" {
+				vision = true
+			}
+		}
+		models = append(models, Model{
+			ID:           m.Name,
+			Provider:     LocalProvider,
+			Name:         m.Name,
+			Capabilities: Capabilities{Vision: vision, Streaming: true},
+		})
+	}
+	return models, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := orDefaultClient(client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d: %s", url, resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListLocalModelsFromOllamaTags(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"models":[
+			{"name":"llama3.2:latest","details":{"families":["llama"]}},
+			{"name":"llava:7b","details":{"families":["llama","clip"]}}
+		]}`)
+	}))
+	defer srv.Close()
+
+	models, err := ListLocalModels(context.Background(), nil, srv.URL+"/v1/")
+	if err != nil {
+		t.Fatalf("ListLocalModels() error = %v", err)
+	}
+	if len(models) != 2 || models[0].Provider != "local" || models[0].Capabilities.Vision || !models[1].Capabilities.Vision {
+		t.Fatalf("unexpected models: %+v", models)
+	}
+}
+
+func TestListLocalModelsFallsBackToOpenAIModels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"qwen2.5-7b-instruct"}]}`)
+	}))
+	defer srv.Close()
+
+	models, err := ListLocalModels(context.Background(), nil, srv.URL)
+	if err != nil || len(models) != 1 || models[0].ID != "qwen2.5-7b-instruct" {
+		t.Fatalf("ListLocalModels() = %+v, %v", models, err)
+	}
+}
+
+func TestLocalProviderSendsWithoutKeyAndDropsImagesForTextModels(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("local requests should not carry credentials, got %q", auth)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"local answer"}}]}`)
+	}))
+	defer srv.Close()
+
+	reply, err := New().Send(context.Background(), Config{
+		Model:    "local/llama3.2",
+		LocalURL: srv.URL,
+		Gateway:  &Gateway{URL: "https://gateway.invalid"}, // local models must bypass the gateway
+		Models:   []Model{{ID: "llama3.2", Provider: "local", Capabilities: Capabilities{Streaming: true}}},
+	}, "sys", []Message{{Role: "user", Content: "look", Images: []string{"aGVsbG8="}}})
+	if err != nil || reply != "local answer" {
+		t.Fatalf("Send() = %q, %v", reply, err)
+	}
+
+	if got["model"] != "llama3.2" {
+		t.Fatalf("model = %v, want llama3.2", got["model"])
+	}
+	msgs := got["messages"].([]any)
+	if user := msgs[1].(map[string]any); user["content"] != "look" {
+		t.Fatalf("images should be dropped for text-only models, got %v", user)
+	}
+}
@@ -0,0 +1,32 @@
+package ai
+
+var chatCaps = Capabilities{Vision: true, Documents: true, Streaming: true}
+
+// toolCaps is chatCaps plus tool calling, for providers whose tool format
+// the client speaks.
+var toolCaps = Capabilities{Vision: true, Documents: true, Streaming: true, Tools: true}
+
+// reasoningCaps is toolCaps for models that can think before answering.
+var reasoningCaps = Capabilities{Vision: true, Documents: true, Streaming: true, Tools: true, Reasoning: true}
+
+// geminiCaps is chatCaps plus a thinking budget.
+var geminiCaps = Capabilities{Vision: true, Documents: true, Streaming: true, Reasoning: true}
+
+// builtinModels are the models offered in Settings out of the box.
+var builtinModels = []Model{
+	{ID: "claude-haiku-4-5-20251001", Provider: AnthropicProvider, Name: "Haiku 4.5 — fast", Capabilities: reasoningCaps, ContextWindow: 200_000, MaxOutput: 64_000},
+	{ID: "claude-sonnet-4-6", Provider: AnthropicProvider, Name: "Sonnet 4.6 — recommended", Capabilities: reasoningCaps, ContextWindow: 200_000, MaxOutput: 64_000},
+	{ID: "claude-opus-4-6", Provider: AnthropicProvider, Name: "Opus 4.6 — most capable", Capabilities: reasoningCaps, ContextWindow: 200_000, MaxOutput: 128_000},
+
+	{ID: "gpt-5-nano", Provider: OpenAIProvider, Name: "GPT-5 nano — fastest", Capabilities: reasoningCaps, ContextWindow: 400_000, MaxOutput: 128_000},
+	{ID: "gpt-5-mini", Provider: OpenAIProvider, Name: "GPT-5 mini — fast", Capabilities: reasoningCaps, ContextWindow: 400_000, MaxOutput: 128_000},
+	{ID: "gpt-5.1", Provider: OpenAIProvider, Name: "GPT-5.1", Capabilities: reasoningCaps, ContextWindow: 400_000, MaxOutput: 128_000},
+	{ID: "gpt-5.2", Provider: OpenAIProvider, Name: "GPT-5.2", Capabilities: reasoningCaps, ContextWindow: 400_000, MaxOutput: 128_000},
+	{ID: "gpt-5.2-chat-latest", Provider: OpenAIProvider, Name: "GPT-5.2 chat latest", Capabilities: toolCaps, ContextWindow: 128_000, MaxOutput: 16_384},
+	{ID: "gpt-4.1", Provider: OpenAIProvider, Name: "GPT-4.1", Capabilities: toolCaps, ContextWindow: 1_047_576, MaxOutput: 32_768},
+	{ID: "gpt-4o", Provider: OpenAIProvider, Name: "GPT-4o", Capabilities: toolCaps, ContextWindow: 128_000, MaxOutput: 16_384},
+
+	{ID: "gemini-2.5-flash-lite", Provider: GeminiProvider, Name: "Gemini 2.5 Flash-Lite — fastest", Capabilities: geminiCaps, ContextWindow: 1_048_576, MaxOutput: 65_536},
+	{ID: "gemini-2.5-flash", Provider: GeminiProvider, Name: "Gemini 2.5 Flash — fast", Capabilities: geminiCaps, ContextWindow: 1_048_576, MaxOutput: 65_536},
+	{ID: "gemini-2.5-pro", Provider: GeminiProvider, Name: "Gemini 2.5 Pro", Capabilities: geminiCaps, ContextWindow: 1_048_576, MaxOutput: 65_536},
+}
@@ -0,0 +1,416 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2 flows for AuthOAuth2.
+const (
+	OAuthClientCredentials = "clientCredentials"
+	OAuthDeviceCode        = "deviceCode"
+)
+
+// ErrLoginRequired is returned when a device-code gateway has no usable
+// token and the user has to sign in from Settings.
+var ErrLoginRequired = errors.New("gateway login required — open Settings to sign in")
+
+// tokenExpiryMargin is how long before expiry a token is refreshed, so it
+// doesn't run out while a request is in flight.
+const tokenExpiryMargin = time.Minute
+
+// OAuthToken is a token as cached on disk.
+type OAuthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	IDToken      string    `json:"id_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+func (t *OAuthToken) valid() bool {
+	return t != nil && t.AccessToken != "" &&
+		(t.Expiry.IsZero() || time.Until(t.Expiry) > tokenExpiryMargin)
+}
+
+// tokenResponse is the token endpoint's answer, successful or not (RFC 6749
+// section 5, RFC 8628 section 3.5).
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	IDToken          string `json:"id_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// oauthTokens serialises token fetches so concurrent requests share one
+// refresh. The cache file is the source of truth, so a login finished in
+// Settings is picked up by the next request.
+type oauthTokens struct {
+	mu  sync.Mutex
+	mem map[string]*OAuthToken // by token URL + client ID, when there is no cache file
+}
+
+func (c *oauthTokens) get(ctx context.Context, client *http.Client, auth *GatewayAuth, force bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := auth.TokenURL + "|" + auth.ClientID
+	tok := c.mem[key]
+	if auth.TokenFile != "" {
+		tok, _ = LoadOAuthToken(auth.TokenFile)
+	}
+	if !force && tok.valid() {
+		return tok.AccessToken, nil
+	}
+
+	var err error
+	switch {
+	case tok != nil && tok.RefreshToken != "":
+		tok, err = refreshOAuthToken(ctx, client, auth, tok.RefreshToken)
+		if err != nil && auth.Flow == OAuthClientCredentials {
+			tok, err = clientCredentialsToken(ctx, client, auth)
+		}
+	case auth.Flow == OAuthClientCredentials:
+		tok, err = clientCredentialsToken(ctx, client, auth)
+	default:
+		err = ErrLoginRequired
+	}
+	if errors.Is(err, ErrLoginRequired) {
+		return "", &APIError{Kind: ErrorAuth, Provider: GatewayProvider, Err: err}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if auth.TokenFile != "" {
+		if err := SaveOAuthToken(auth.TokenFile, tok); err != nil {
+			return "", err
+		}
+	} else {
+		if c.mem == nil {
+			c.mem = make(map[string]*OAuthToken)
+		}
+		c.mem[key] = tok
+	}
+	return tok.AccessToken, nil
+}
+
+func clientCredentialsToken(ctx context.Context, client *http.Client, auth *GatewayAuth) (*OAuthToken, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+	resp, err := postTokenForm(ctx, client, auth, auth.TokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+	return resp.token(nil)
+}
+
+func refreshOAuthToken(ctx context.Context, client *http.Client, auth *GatewayAuth, refreshToken string) (*OAuthToken, error) {
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}
+	resp, err := postTokenForm(ctx, client, auth, auth.TokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+	// A revoked or expired refresh token means signing in again.
+	if resp.Error == "invalid_grant" && auth.Flow == OAuthDeviceCode {
+		return nil, ErrLoginRequired
+	}
+	return resp.token(&OAuthToken{RefreshToken: refreshToken})
+}
+
+// token converts a successful response into a token. prev supplies values
+// the server may leave out on refresh.
+func (r *tokenResponse) token(prev *OAuthToken) (*OAuthToken, error) {
+	if r.Error != "" {
+		if r.ErrorDescription != "" {
+			return nil, fmt.Errorf("gateway token request failed: %s: %s", r.Error, r.ErrorDescription)
+		}
+		return nil, fmt.Errorf("gateway token request failed: %s", r.Error)
+	}
+	if r.AccessToken == "" {
+		return nil, fmt.Errorf("gateway token response has no access_token")
+	}
+	tok := &OAuthToken{AccessToken: r.AccessToken, RefreshToken: r.RefreshToken, IDToken: r.IDToken}
+	if prev != nil {
+		if tok.RefreshToken == "" {
+			tok.RefreshToken = prev.RefreshToken
+		}
+		if tok.IDToken == "" {
+			tok.IDToken = prev.IDToken
+		}
+	}
+	if r.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
+
+// postForm posts form to an OAuth endpoint with the client credentials and
+// returns the response body. Non-200 responses are returned as well, since
+// OAuth errors arrive as 400s with a JSON body.
+func postForm(ctx context.Context, client *http.Client, auth *GatewayAuth, endpoint string, form url.Values) (*http.Response, []byte, error) {
+	if endpoint == "" {
+		return nil, nil, fmt.Errorf("gateway OAuth endpoint not configured")
+	}
+	form.Set("client_id", auth.ClientID)
+	if auth.ClientSecret != "" {
+		form.Set("client_secret", auth.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create OAuth request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := orDefaultClient(client).Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("OAuth request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read OAuth response: %w", err)
+	}
+	return resp, body, nil
+}
+
+// postTokenForm calls the token endpoint. OAuth error codes are left in the
+// response for the caller to inspect.
+func postTokenForm(ctx context.Context, client *http.Client, auth *GatewayAuth, endpoint string, form url.Values) (*tokenResponse, error) {
+	resp, body, err := postForm(ctx, client, auth, endpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	var out tokenResponse
+	if json.Unmarshal(body, &out) != nil || (resp.StatusCode != http.StatusOK && out.Error == "") {
+		return nil, httpError(GatewayProvider, resp, fmt.Sprintf("token endpoint returned status %d: %s", resp.StatusCode, string(body)))
+	}
+	return &out, nil
+}
+
+// DeviceLogin is a pending device-code login (RFC 8628). The user opens
+// VerificationURI and enters UserCode while PollDeviceLogin waits.
+type DeviceLogin struct {
+	UserCode                string `json:"userCode"`
+	VerificationURI         string `json:"verificationURI"`
+	VerificationURIComplete string `json:"verificationURIComplete,omitempty"`
+	ExpiresIn               int    `json:"expiresIn"`
+
+	deviceCode string
+	interval   time.Duration
+}
+
+// StartDeviceLogin asks the authorization server for a device code.
+func StartDeviceLogin(ctx context.Context, client *http.Client, auth *GatewayAuth) (*DeviceLogin, error) {
+	if auth == nil || auth.Type != AuthOAuth2 || auth.Flow != OAuthDeviceCode {
+		return nil, fmt.Errorf("gateway is not configured for device-code login")
+	}
+	form := url.Values{}
+	if len(auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+	resp, body, err := postForm(ctx, client, auth, auth.DeviceAuthURL, form)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpError(GatewayProvider, resp, fmt.Sprintf("device authorization returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	var out struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURL         string `json:"verification_url"` // Google's spelling
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse device authorization response: %w", err)
+	}
+	if out.DeviceCode == "" || out.UserCode == "" {
+		return nil, fmt.Errorf("device authorization response has no device_code or user_code")
+	}
+	login := &DeviceLogin{
+		UserCode:                out.UserCode,
+		VerificationURI:         out.VerificationURI,
+		VerificationURIComplete: out.VerificationURIComplete,
+		ExpiresIn:               out.ExpiresIn,
+		deviceCode:              out.DeviceCode,
+		interval:                time.Duration(out.Interval) * time.Second,
+	}
+	if login.VerificationURI == "" {
+		login.VerificationURI = out.VerificationURL
+	}
+	if login.interval <= 0 {
+		login.interval = 5 * time.Second
+	}
+	return login, nil
+}
+
+// PollDeviceLogin polls the token endpoint until the user approves or
+// denies the login, the code expires, or ctx is done. The token is saved to
+// auth.TokenFile.
+func PollDeviceLogin(ctx context.Context, client *http.Client, auth *GatewayAuth, login *DeviceLogin) (*OAuthToken, error) {
+	if login.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(login.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+	interval := login.interval
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("gateway login expired — try again")
+			}
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		form := url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {login.deviceCode},
+		}
+		resp, err := postTokenForm(ctx, client, auth, auth.TokenURL, form)
+		if err != nil {
+			return nil, err
+		}
+		switch resp.Error {
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			interval += 5 * time.Second
+			continue
+		case "access_denied":
+			return nil, fmt.Errorf("gateway login was denied")
+		case "expired_token":
+			return nil, fmt.Errorf("gateway login expired — try again")
+		}
+
+		tok, err := resp.token(nil)
+		if err != nil {
+			return nil, err
+		}
+		if auth.TokenFile != "" {
+			if err := SaveOAuthToken(auth.TokenFile, tok); err != nil {
+				return nil, err
+			}
+		}
+		return tok, nil
+	}
+}
+
+// LoadOAuthToken reads a cached token. A missing file returns nil, nil.
+func LoadOAuthToken(path string) (*OAuthToken, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tok OAuthToken
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return nil, fmt.Errorf("failed to parse cached gateway token: %w", err)
+	}
+	return &tok, nil
+}
+
+// SaveOAuthToken writes a token readable by the current user only.
+func SaveOAuthToken(path string, tok *OAuthToken) error {
+	data, err := json.MarshalIndent(tok, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Written to a new 0600 file and renamed over the old one: rewriting an
+	// existing file would keep its mode, and the token would be readable
+	// through it until a chmod.
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to cache gateway token: %w", err)
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to cache gateway token: %w", err)
+	}
+	return nil
+}
+
+// GatewayAuthStatus describes the cached OAuth login for Settings.
+type GatewayAuthStatus struct {
+	LoggedIn  bool      `json:"loggedIn"`
+	Subject   string    `json:"subject,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+// OAuthStatus reports who the cached token at path belongs to. The subject
+// is read from the ID token's claims, or the access token's if it is a JWT;
+// signatures are not checked since the token is only shown, never trusted.
+func OAuthStatus(path string) GatewayAuthStatus {
+	tok, err := LoadOAuthToken(path)
+	if err != nil || tok == nil || tok.AccessToken == "" {
+		return GatewayAuthStatus{}
+	}
+	// An expired token still counts while it can be refreshed.
+	if !tok.valid() && tok.RefreshToken == "" {
+		return GatewayAuthStatus{}
+	}
+	status := GatewayAuthStatus{LoggedIn: true, ExpiresAt: tok.Expiry}
+	for _, jwt := range []string{tok.IDToken, tok.AccessToken} {
+		if sub := jwtSubject(jwt); sub != "" {
+			status.Subject = sub
+			break
+		}
+	}
+	return status
+}
+
+// jwtSubject returns the most readable identity claim of a JWT, or "".
+func jwtSubject(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+	var claims map[string]any
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	for _, key := range []string{"email", "preferred_username", "name", "sub", "client_id"} {
+		if v, ok := claims[key].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,215 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeJWT builds an unsigned JWT carrying claims.
+func fakeJWT(claims string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"none"}`)) + "." + enc([]byte(claims)) + ".sig"
+}
+
+func TestGatewayClientCredentialsCachesToken(t *testing.T) {
+	var issued atomic.Int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("client_id") != "lay" || r.Form.Get("client_secret") != "shh" {
+			t.Errorf("unexpected token request: %v", r.Form)
+		}
+		if r.Form.Get("scope") != "chat models" {
+			t.Errorf("scope = %q, want %q", r.Form.Get("scope"), "chat models")
+		}
+		n := issued.Add(1)
+		fmt.Fprintf(w, `{"access_token":"at-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	defer tokenSrv.Close()
+
+	gwSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer at-1" {
+			t.Errorf("Authorization = %q, want Bearer at-1", got)
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer gwSrv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "gateway-token.json")
+	cfg := Config{
+		Model: "gpt-4.1",
+		Gateway: &Gateway{URL: gwSrv.URL, Auth: &GatewayAuth{
+			Type: AuthOAuth2, Flow: OAuthClientCredentials, TokenURL: tokenSrv.URL,
+			ClientID: "lay", ClientSecret: "shh", Scopes: []string{"chat", "models"}, TokenFile: tokenFile,
+		}},
+	}
+	c := New()
+	for i := 0; i < 2; i++ {
+		if _, err := c.Send(context.Background(), cfg, "", []Message{{Role: "user", Content: "hi"}}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if n := issued.Load(); n != 1 {
+		t.Fatalf("token endpoint called %d times, want 1", n)
+	}
+
+	info, err := os.Stat(tokenFile)
+	if err != nil {
+		t.Fatalf("token not cached: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("token file mode = %o, want 600", perm)
+	}
+}
+
+func TestGatewayRefreshesExpiringOAuthToken(t *testing.T) {
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "rt" {
+			t.Errorf("unexpected token request: %v", r.Form)
+		}
+		fmt.Fprint(w, `{"access_token":"fresh","expires_in":3600}`)
+	}))
+	defer tokenSrv.Close()
+
+	gwSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer fresh" {
+			t.Errorf("Authorization = %q, want Bearer fresh", got)
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer gwSrv.Close()
+
+	// Still valid for a few seconds, which is inside the refresh margin.
+	tokenFile := filepath.Join(t.TempDir(), "gateway-token.json")
+	if err := SaveOAuthToken(tokenFile, &OAuthToken{AccessToken: "stale", RefreshToken: "rt", Expiry: time.Now().Add(5 * time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := New().Send(context.Background(), Config{
+		Model: "gpt-4.1",
+		Gateway: &Gateway{URL: gwSrv.URL, Auth: &GatewayAuth{
+			Type: AuthOAuth2, Flow: OAuthDeviceCode, TokenURL: tokenSrv.URL, ClientID: "lay", TokenFile: tokenFile,
+		}},
+	}, "", []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	tok, _ := LoadOAuthToken(tokenFile)
+	if tok.AccessToken != "fresh" || tok.RefreshToken != "rt" {
+		t.Fatalf("cached token = %+v, want fresh access token and the old refresh token", tok)
+	}
+}
+
+func TestGatewayDeviceCodeRequiresLogin(t *testing.T) {
+	_, err := New().Send(context.Background(), Config{
+		Model: "gpt-4.1",
+		Gateway: &Gateway{URL: "http://127.0.0.1:0", Auth: &GatewayAuth{
+			Type: AuthOAuth2, Flow: OAuthDeviceCode, TokenURL: "http://127.0.0.1:0", TokenFile: filepath.Join(t.TempDir(), "none.json"),
+		}},
+	}, "", []Message{{Role: "user", Content: "hi"}})
+	if !errors.Is(err, ErrLoginRequired) {
+		t.Fatalf("Send() error = %v, want ErrLoginRequired", err)
+	}
+	if kind := ErrorKindOf(err); kind != ErrorAuth {
+		t.Fatalf("kind = %q, want %q", kind, ErrorAuth)
+	}
+}
+
+func TestDeviceLoginPollsUntilApproved(t *testing.T) {
+	var polls atomic.Int32
+	idToken := fakeJWT(`{"sub":"123","email":"ada@example.com"}`)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"device_code":"dc","user_code":"ABCD-EFGH","verification_uri":"https://sso.example.com/device","expires_in":600,"interval":5}`)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("device_code") != "dc" {
+			t.Errorf("device_code = %q, want dc", r.Form.Get("device_code"))
+		}
+		if polls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"authorization_pending"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"at","refresh_token":"rt","id_token":%q,"expires_in":3600}`, idToken)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "gateway-token.json")
+	auth := &GatewayAuth{
+		Type: AuthOAuth2, Flow: OAuthDeviceCode, ClientID: "lay",
+		DeviceAuthURL: srv.URL + "/device", TokenURL: srv.URL + "/token", TokenFile: tokenFile,
+	}
+
+	if got := OAuthStatus(tokenFile); got.LoggedIn {
+		t.Fatalf("status before login = %+v, want logged out", got)
+	}
+
+	login, err := StartDeviceLogin(context.Background(), nil, auth)
+	if err != nil {
+		t.Fatalf("StartDeviceLogin() error = %v", err)
+	}
+	if login.UserCode != "ABCD-EFGH" || login.VerificationURI != "https://sso.example.com/device" {
+		t.Fatalf("login = %+v", login)
+	}
+	login.interval = time.Millisecond
+
+	if _, err := PollDeviceLogin(context.Background(), nil, auth, login); err != nil {
+		t.Fatalf("PollDeviceLogin() error = %v", err)
+	}
+	if n := polls.Load(); n != 3 {
+		t.Fatalf("token endpoint polled %d times, want 3", n)
+	}
+
+	status := OAuthStatus(tokenFile)
+	if !status.LoggedIn || status.Subject != "ada@example.com" {
+		t.Fatalf("status after login = %+v, want ada@example.com", status)
+	}
+}
+
+func TestDeviceLoginDenied(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"access_denied"}`)
+	}))
+	defer srv.Close()
+
+	auth := &GatewayAuth{Type: AuthOAuth2, Flow: OAuthDeviceCode, TokenURL: srv.URL}
+	_, err := PollDeviceLogin(context.Background(), nil, auth, &DeviceLogin{deviceCode: "dc", interval: time.Millisecond})
+	if err == nil {
+		t.Fatal("PollDeviceLogin() should fail when the user denies the login")
+	}
+}
+
+func TestSaveOAuthTokenReplacesReadableFile(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token.json")
+	if err := os.WriteFile(tokenFile, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveOAuthToken(tokenFile, &OAuthToken{AccessToken: "at"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(tokenFile)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("token file = %v, %v; want mode 600", info.Mode(), err)
+	}
+	if tok, err := LoadOAuthToken(tokenFile); err != nil || tok.AccessToken != "at" {
+		t.Fatalf("LoadOAuthToken() = %+v, %v", tok, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("%d files left in the directory, want only the token", len(entries))
+	}
+}
@@ -0,0 +1,342 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// OpenAIProvider is the provider ID of OpenAI's API.
+const OpenAIProvider = "openai"
+
+type openAIProvider struct{}
+
+func (openAIProvider) ID() string { return OpenAIProvider }
+
+func (openAIProvider) Send(ctx context.Context, cfg Config, req Request, onDelta DeltaFunc) (*Response, error) {
+	if cfg.OpenAIKey == "" {
+		return nil, fmt.Errorf("OpenAI API key not set — open Settings to add your key")
+	}
+
+	messages, files := openAIMessages(req, true, true)
+	httpClient := cfg.httpClient()
+	if len(files) > 0 {
+		withFiles := *httpClient
+		withFiles.Transport = &openAIFileTransport{base: withFiles.Transport, files: files}
+		httpClient = &withFiles
+	}
+	httpClient, retryAfter := recordRetryAfter(httpClient)
+	ocfg := openai.DefaultConfig(cfg.OpenAIKey)
+	ocfg.HTTPClient = httpClient
+	client := openai.NewClientWithConfig(ocfg)
+	chatReq := openai.ChatCompletionRequest{
+		Model:    req.Model.ID,
+		Messages: messages,
+		Tools:    openAITools(req.Tools),
+	}
+	applyOpenAIParams(&chatReq, req, true)
+	resp, err := completeOpenAI(ctx, cfg, client, chatReq, onDelta, "OpenAI")
+	return resp, retryAfter.annotate(err)
+}
+
+// openAIMessages converts req to the Chat Completions format. Images are
+// dropped when the model cannot take them. Text documents become text parts;
+// PDFs become file placeholders when pdf is set, see openAIFileTransport,
+// and a note otherwise.
+func openAIMessages(req Request, vision, pdf bool) (msgs []openai.ChatCompletionMessage, files []Document) {
+	msgs = append(msgs, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: req.System,
+	})
+	for _, m := range req.Messages {
+		role := openai.ChatMessageRoleUser
+		switch m.Role {
+		case "assistant":
+			msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: m.Content}
+			for _, call := range m.ToolCalls {
+				msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+					ID:       call.ID,
+					Type:     openai.ToolTypeFunction,
+					Function: openai.FunctionCall{Name: call.Name, Arguments: string(toolInput(call.Arguments))},
+				})
+			}
+			msgs = append(msgs, msg)
+			continue
+		case "tool":
+			msgs = append(msgs, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, Content: m.Content, ToolCallID: m.ToolCallID})
+			continue
+		}
+		if m.Role != "user" || (len(m.Documents) == 0 && (!vision || len(m.Images) == 0)) {
+			msgs = append(msgs, openai.ChatCompletionMessage{Role: role, Content: m.Content})
+			continue
+		}
+		var parts []openai.ChatMessagePart
+		for _, doc := range m.Documents {
+			text := doc.unreadable()
+			switch {
+			case !doc.isPDF():
+				text = doc.text()
+			case pdf:
+				text = fmt.Sprintf("%s%d", openAIFileMarker, len(files))
+				files = append(files, doc)
+			}
+			parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: text})
+		}
+		if vision {
+			for _, img := range m.Images {
+				parts = append(parts, openai.ChatMessagePart{
+					Type:     openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{URL: imageDataURL(img)},
+				})
+			}
+		}
+		if m.Content != "" {
+			parts = append(parts, openai.ChatMessagePart{
+				Type: openai.ChatMessagePartTypeText,
+				Text: m.Content,
+			})
+		}
+		msgs = append(msgs, openai.ChatCompletionMessage{Role: role, MultiContent: parts})
+	}
+	return msgs, files
+}
+
+// applyOpenAIParams sets req's generation parameters. OpenAI itself takes
+// max_completion_tokens; most local servers only understand max_tokens.
+// Reasoning models reject temperature and top_p, so they are left out.
+func applyOpenAIParams(chatReq *openai.ChatCompletionRequest, req Request, completionTokens bool) {
+	p := req.Params
+	if completionTokens {
+		chatReq.MaxCompletionTokens = p.maxTokens(0)
+	} else {
+		chatReq.MaxTokens = p.maxTokens(0)
+	}
+	chatReq.Stop = p.Stop
+	chatReq.ReasoningEffort = p.reasoningEffort()
+	if s := req.Schema; s != nil {
+		schema, _ := json.Marshal(s.JSON)
+		chatReq.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:        s.Name,
+				Description: s.Description,
+				Schema:      json.RawMessage(schema),
+				Strict:      s.Strict,
+			},
+		}
+	}
+	if isOpenAIReasoningModel(req.Model.ID) {
+		return
+	}
+	if p.Temperature != nil {
+		chatReq.Temperature = openAIFloat(*p.Temperature)
+	}
+	if p.TopP != nil {
+		chatReq.TopP = openAIFloat(*p.TopP)
+	}
+}
+
+// openAIFloat converts v for a field tagged omitempty, where an exact zero
+// would be dropped and the server default used instead.
+func openAIFloat(v float64) float32 {
+	if v == 0 {
+		return math.SmallestNonzeroFloat32
+	}
+	return float32(v)
+}
+
+func openAITools(tools []Tool) []openai.Tool {
+	var out []openai.Tool
+	for _, t := range tools {
+		out = append(out, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+	return out
+}
+
+func openAIToolCalls(calls []openai.ToolCall) []ToolCall {
+	var out []ToolCall
+	for _, c := range calls {
+		out = append(out, ToolCall{ID: c.ID, Name: c.Function.Name, Arguments: c.Function.Arguments})
+	}
+	return out
+}
+
+// completeOpenAI runs a Chat Completions request against any OpenAI-compatible
+// server. name labels errors, e.g. "OpenAI" or "local model". OpenAI itself
+// does not return reasoning over this API; servers that fill in
+// reasoning_content (DeepSeek, llama.cpp, vLLM) have it returned as Thinking.
+func completeOpenAI(ctx context.Context, cfg Config, client *openai.Client, chatReq openai.ChatCompletionRequest, onDelta DeltaFunc, name string) (*Response, error) {
+	if onDelta != nil {
+		return streamOpenAI(ctx, cfg, client, chatReq, onDelta, name)
+	}
+
+	resp, err := client.CreateChatCompletion(ctx, chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("%s API error: %w", name, err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("empty response from %s", name)
+	}
+	msg := resp.Choices[0].Message
+	return &Response{
+		Text:      msg.Content,
+		Thinking:  plainThinking(msg.ReasoningContent),
+		ToolCalls: openAIToolCalls(msg.ToolCalls),
+		Usage:     openAIUsage(&resp.Usage),
+	}, nil
+}
+
+func openAIUsage(u *openai.Usage) Usage {
+	if u == nil {
+		return Usage{}
+	}
+	// OpenAI caches long prompts on its own and counts the hits within
+	// prompt_tokens.
+	cached := 0
+	if u.PromptTokensDetails != nil {
+		cached = u.PromptTokensDetails.CachedTokens
+	}
+	return Usage{InputTokens: u.PromptTokens - cached, OutputTokens: u.CompletionTokens, CacheReadTokens: cached}
+}
+
+func streamOpenAI(ctx context.Context, cfg Config, client *openai.Client, req openai.ChatCompletionRequest, onDelta DeltaFunc, name string) (*Response, error) {
+	req.Stream = true
+	// Usage arrives in a final chunk without choices.
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("%s API error: %w", name, err)
+	}
+	defer stream.Close()
+
+	var sb, reasoning strings.Builder
+	var usage Usage
+	// Tool calls stream as fragments keyed by index: the first carries the ID
+	// and name, the rest append to the arguments.
+	var calls []openai.ToolCall
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s API error: %w", name, err)
+		}
+		if chunk.Usage != nil {
+			usage = openAIUsage(chunk.Usage)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		if delta.ReasoningContent != "" {
+			reasoning.WriteString(delta.ReasoningContent)
+			cfg.thinking(delta.ReasoningContent)
+		}
+		if delta.Content != "" {
+			sb.WriteString(delta.Content)
+			onDelta(delta.Content)
+		}
+		for _, frag := range delta.ToolCalls {
+			i := len(calls) - 1
+			if frag.Index != nil {
+				i = *frag.Index
+			}
+			for i >= len(calls) {
+				calls = append(calls, openai.ToolCall{})
+			}
+			if frag.ID != "" {
+				calls[i].ID = frag.ID
+			}
+			if frag.Function.Name != "" {
+				calls[i].Function.Name = frag.Function.Name
+			}
+			calls[i].Function.Arguments += frag.Function.Arguments
+		}
+	}
+	if sb.Len() == 0 && len(calls) == 0 {
+		return nil, fmt.Errorf("empty response from %s", name)
+	}
+	return &Response{
+		Text:      sb.String(),
+		Thinking:  plainThinking(reasoning.String()),
+		ToolCalls: openAIToolCalls(calls),
+		Usage:     usage,
+	}, nil
+}
+
+// openAIFileMarker stands in for a PDF in the request go-openai builds. The
+// library has no "file" content part, so openAIFileTransport swaps the real
+// part in on the way out.
+const openAIFileMarker = "\x00lay-file:"
+
+type openAIFileTransport struct {
+	base  http.RoundTripper // nil means http.DefaultTransport
+	files []Document
+}
+
+func (t *openAIFileTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if r.Body == nil {
+		return base.RoundTrip(r)
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var payload map[string]any
+	if dec.Decode(&payload) == nil {
+		messages, _ := payload["messages"].([]any)
+		for _, m := range messages {
+			msg, _ := m.(map[string]any)
+			parts, _ := msg["content"].([]any)
+			for i, p := range parts {
+				part, _ := p.(map[string]any)
+				text, _ := part["text"].(string)
+				n, ok := strings.CutPrefix(text, openAIFileMarker)
+				if !ok {
+					continue
+				}
+				if idx, err := strconv.Atoi(n); err == nil && idx < len(t.files) {
+					doc := t.files[idx]
+					parts[i] = map[string]any{"type": "file", "file": map[string]any{
+						"filename":  doc.Name,
+						"file_data": "data:" + mimePDF + ";base64," + doc.Data,
+					}}
+				}
+			}
+		}
+		if out, err := json.Marshal(payload); err == nil {
+			body = out
+		}
+	}
+
+	r = r.Clone(r.Context())
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	return base.RoundTrip(r)
+}
@@ -0,0 +1,137 @@
+package ai
+
+import "strings"
+
+// defaultMaxTokens is the output limit for providers that require one when
+// neither the model nor the config sets it.
+const defaultMaxTokens = 8192
+
+// minThinkingBudget is the smallest extended-thinking budget Anthropic accepts.
+const minThinkingBudget = 1024
+
+// Params tune generation for one model. Zero values leave the provider's
+// default in place.
+type Params struct {
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+
+	// Only sent to models whose capabilities include reasoning. Providers
+	// that take a token budget derive one from the effort when no budget is
+	// set, and vice versa.
+	ReasoningEffort string `json:"reasoningEffort,omitempty"` // "minimal", "low", "medium" or "high"
+	ThinkingBudget  int    `json:"thinkingBudget,omitempty"`  // tokens
+
+	limit int // the model's MaxOutput, which caps maxTokens
+}
+
+// merge returns p with every field that over sets replaced.
+func (p Params) merge(over Params) Params {
+	if over.MaxTokens != 0 {
+		p.MaxTokens = over.MaxTokens
+	}
+	if over.Temperature != nil {
+		p.Temperature = over.Temperature
+	}
+	if over.TopP != nil {
+		p.TopP = over.TopP
+	}
+	if over.Stop != nil {
+		p.Stop = over.Stop
+	}
+	if over.ReasoningEffort != "" {
+		p.ReasoningEffort = over.ReasoningEffort
+	}
+	if over.ThinkingBudget != 0 {
+		p.ThinkingBudget = over.ThinkingBudget
+	}
+	return p
+}
+
+// params resolves the parameters for model: its declared defaults, then the
+// config's profile for it. Profiles are keyed by the ID the user selected,
+// the bare model ID or the qualified "provider/id".
+func (cfg Config) params(model Model) Params {
+	var p Params
+	if model.Params != nil {
+		p = *model.Params
+	}
+	for _, key := range []string{model.ID, model.Provider + "/" + model.ID, cfg.Model} {
+		if over, ok := cfg.Params[key]; ok {
+			p = p.merge(over)
+			break
+		}
+	}
+	if !model.Capabilities.Reasoning {
+		p.ReasoningEffort, p.ThinkingBudget = "", 0
+	}
+	p.limit = model.MaxOutput
+	return p
+}
+
+// maxTokens returns the output limit, or fallback when none is set, no
+// more than the model can produce.
+func (p Params) maxTokens(fallback int) int {
+	n := fallback
+	if p.MaxTokens > 0 {
+		n = p.MaxTokens
+	}
+	if p.limit > 0 {
+		n = min(n, p.limit)
+	}
+	return n
+}
+
+// thinkingBudget returns the token budget for reasoning, derived from the
+// effort when no budget is set. Zero means reasoning is off.
+func (p Params) thinkingBudget() int {
+	if p.ThinkingBudget > 0 {
+		return p.ThinkingBudget
+	}
+	switch p.ReasoningEffort {
+	case "minimal", "low":
+		return 2048
+	case "medium":
+		return 8192
+	case "high":
+		return 24576
+	}
+	return 0
+}
+
+// reasoningEffort returns the effort level, derived from the budget when no
+// effort is set.
+func (p Params) reasoningEffort() string {
+	switch {
+	case p.ReasoningEffort != "":
+		return p.ReasoningEffort
+	case p.ThinkingBudget <= 0:
+		return ""
+	case p.ThinkingBudget <= 4096:
+		return "low"
+	case p.ThinkingBudget <= 16384:
+		return "medium"
+	}
+	return "high"
+}
+
+// sampling returns temperature and top_p for a request to model id. OpenAI
+// reasoning models reject them, so they are left out there.
+func (p Params) sampling(id string) (temperature, topP *float64) {
+	if isOpenAIReasoningModel(id) {
+		return nil, nil
+	}
+	return p.Temperature, p.TopP
+}
+
+// isOpenAIReasoningModel reports whether id is an o-series or GPT-5 model,
+// which take max_completion_tokens and reject sampling parameters.
+func isOpenAIReasoningModel(id string) bool {
+	for _, prefix := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if strings.HasPrefix(id, prefix) {
+			return !strings.Contains(id, "-chat")
+		}
+	}
+	return false
+}
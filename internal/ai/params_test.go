@@ -0,0 +1,135 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// captureTransport records the JSON body of each request and answers with a
+// canned response.
+type captureTransport struct {
+	reply string
+	body  map[string]any
+}
+
+func (c *captureTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	data, _ := io.ReadAll(r.Body)
+	c.body = nil
+	_ = json.Unmarshal(data, &c.body)
+	contentType := "application/json"
+	if strings.HasPrefix(c.reply, "event:") {
+		contentType = "text/event-stream"
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       io.NopCloser(strings.NewReader(c.reply)),
+		Request:    r,
+	}, nil
+}
+
+func ptr(v float64) *float64 { return &v }
+
+func TestConfigParamsMergesProfiles(t *testing.T) {
+	model := Model{ID: "m", Provider: "openai", Params: &Params{MaxTokens: 100, Temperature: ptr(0.5)}}
+	cfg := Config{Model: "openai/m", Params: map[string]Params{
+		"openai/m": {MaxTokens: 4000, ReasoningEffort: "high"},
+	}}
+	p := cfg.params(model)
+	if p.MaxTokens != 4000 || *p.Temperature != 0.5 {
+		t.Fatalf("params = %+v, want the profile over the declared defaults", p)
+	}
+	if p.ReasoningEffort != "" {
+		t.Fatalf("reasoning effort should be dropped for models without reasoning, got %q", p.ReasoningEffort)
+	}
+	model.Capabilities.Reasoning = true
+	if p := cfg.params(model); p.ReasoningEffort != "high" {
+		t.Fatalf("ReasoningEffort = %q, want high", p.ReasoningEffort)
+	}
+}
+
+func TestAnthropicAppliesParams(t *testing.T) {
+	tr := &captureTransport{reply: anthropicSSE(
+		`{"type":"message_start","message":{"id":"msg","type":"message","role":"assistant","content":[],"usage":{"input_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ok"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_stop"}`,
+	)}
+	cfg := Config{AnthropicKey: "k", Model: "claude-sonnet-4-6", HTTPClient: &http.Client{Transport: tr}}
+	send := func(p Params) map[string]any {
+		t.Helper()
+		cfg.Params = map[string]Params{"claude-sonnet-4-6": p}
+		if _, err := New().Send(context.Background(), cfg, "", []Message{{Role: "user", Content: "hi"}}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		return tr.body
+	}
+
+	body := send(Params{})
+	if body["max_tokens"] != float64(defaultMaxTokens) {
+		t.Fatalf("max_tokens = %v, want the default", body["max_tokens"])
+	}
+
+	body = send(Params{MaxTokens: 32000, Temperature: ptr(0.2), Stop: []string{"END"}})
+	if body["max_tokens"] != float64(32000) || body["temperature"] != 0.2 || body["stop_sequences"].([]any)[0] != "END" {
+		t.Fatalf("body = %v", body)
+	}
+
+	body = send(Params{MaxTokens: 4000, Temperature: ptr(0.2), ThinkingBudget: 10000})
+	thinking := body["thinking"].(map[string]any)
+	if thinking["budget_tokens"] != float64(10000) || body["max_tokens"].(float64) <= 10000 {
+		t.Fatalf("thinking = %v, max_tokens = %v; want the budget with room for the answer", thinking, body["max_tokens"])
+	}
+	if _, ok := body["temperature"]; ok {
+		t.Fatal("temperature cannot be combined with thinking")
+	}
+}
+
+func TestOpenAIAppliesParams(t *testing.T) {
+	tr := &captureTransport{reply: `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`}
+	cfg := Config{OpenAIKey: "k", HTTPClient: &http.Client{Transport: tr}, Params: map[string]Params{
+		"gpt-5-mini": {MaxTokens: 5000, Temperature: ptr(0.3), ThinkingBudget: 20000},
+		"gpt-4.1":    {MaxTokens: 5000, Temperature: ptr(0)},
+	}}
+
+	cfg.Model = "gpt-5-mini"
+	if _, err := New().Send(context.Background(), cfg, "", []Message{{Role: "user", Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if tr.body["max_completion_tokens"] != float64(5000) || tr.body["reasoning_effort"] != "high" {
+		t.Fatalf("body = %v, want max_completion_tokens and an effort derived from the budget", tr.body)
+	}
+	if _, ok := tr.body["temperature"]; ok {
+		t.Fatal("reasoning models reject temperature")
+	}
+
+	cfg.Model = "gpt-4.1"
+	if _, err := New().Send(context.Background(), cfg, "", []Message{{Role: "user", Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tr.body["temperature"]; !ok {
+		t.Fatalf("a temperature of 0 should still be sent, got %v", tr.body)
+	}
+	if _, ok := tr.body["reasoning_effort"]; ok {
+		t.Fatal("reasoning effort sent to a model without reasoning")
+	}
+}
+
+func TestGeminiAppliesParams(t *testing.T) {
+	tr := &captureTransport{reply: `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`}
+	cfg := Config{GeminiKey: "k", Model: "gemini-2.5-pro", HTTPClient: &http.Client{Transport: tr}, Params: map[string]Params{
+		"gemini-2.5-pro": {MaxTokens: 9000, TopP: ptr(0.9), ReasoningEffort: "medium"},
+	}}
+	if _, err := New().Send(context.Background(), cfg, "", []Message{{Role: "user", Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	gc := tr.body["generationConfig"].(map[string]any)
+	if gc["maxOutputTokens"] != float64(9000) || gc["topP"] != 0.9 || gc["thinkingConfig"].(map[string]any)["thinkingBudget"] != float64(8192) {
+		t.Fatalf("generationConfig = %v", gc)
+	}
+}
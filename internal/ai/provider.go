@@ -0,0 +1,185 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Provider sends chat requests to one vendor's API.
+type Provider interface {
+	// ID is the key the provider is registered under, e.g. "anthropic".
+	ID() string
+	// Send returns the full answer. When onDelta is non-nil the provider
+	// streams and calls it with each chunk of text as it arrives.
+	Send(ctx context.Context, cfg Config, req Request, onDelta DeltaFunc) (*Response, error)
+}
+
+// Request is what a provider needs to answer one chat turn.
+type Request struct {
+	Model        Model
+	System       string        // SystemBlocks joined, for providers without prompt caching
+	SystemBlocks []SystemBlock // the same prompt with its cache breakpoints
+	Messages     []Message
+	Tools        []Tool
+	Params       Params
+	Schema       *Schema // answer with a JSON document matching it; set by Extract
+}
+
+// SystemBlock is one part of the system prompt. Cache marks the end of a
+// prefix that stays the same between requests, such as the instructions
+// and a finished transcript, so providers with prompt caching can reuse it.
+type SystemBlock struct {
+	Text  string
+	Cache bool
+}
+
+// systemText joins blocks into one prompt.
+func systemText(blocks []SystemBlock) string {
+	var sb strings.Builder
+	for _, b := range blocks {
+		sb.WriteString(b.Text)
+	}
+	return sb.String()
+}
+
+// Response is a provider's complete answer.
+type Response struct {
+	Text      string
+	Thinking  []Thinking // reasoning the model did before answering, kept apart from Text
+	ToolCalls []ToolCall // tools to run before the model can finish answering
+	Usage     Usage
+	Model     Model  // the model that answered, filled in by the client
+	Provider  string // ID of the provider that was called, e.g. "gateway"
+	Fallback  int    // 0 if Config.Model answered, else the position in Config.Fallbacks plus one
+}
+
+// Thinking is one block of a model's reasoning: Anthropic thinking, Gemini
+// thought summaries or the reasoning_content of OpenAI-compatible servers.
+type Thinking struct {
+	Text      string `json:"text"`
+	Signature string `json:"signature,omitempty"` // Anthropic: proves the block is unmodified
+	Redacted  string `json:"redacted,omitempty"`  // Anthropic: encrypted reasoning, Text is empty
+}
+
+// plainThinking wraps unsigned reasoning text, or returns nil if it is empty.
+func plainThinking(text string) []Thinking {
+	if text == "" {
+		return nil
+	}
+	return []Thinking{{Text: text}}
+}
+
+// ThinkingText joins the readable reasoning of r.
+func (r *Response) ThinkingText() string {
+	var parts []string
+	for _, t := range r.Thinking {
+		if t.Text != "" {
+			parts = append(parts, t.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Usage counts the tokens billed for one call. Providers that don't report
+// usage leave it zero. Prompt tokens served from the provider's cache (a
+// hit) or written to it are counted apart from InputTokens, the misses,
+// because they are billed at different rates.
+type Usage struct {
+	InputTokens      int `json:"inputTokens"`
+	OutputTokens     int `json:"outputTokens"`
+	CacheReadTokens  int `json:"cacheReadTokens,omitempty"`
+	CacheWriteTokens int `json:"cacheWriteTokens,omitempty"`
+}
+
+// Model declares a model and the provider that serves it.
+type Model struct {
+	ID            string       `json:"id"`       // sent to the provider's API
+	Provider      string       `json:"provider"` // registry key of the provider
+	Name          string       `json:"name"`     // shown in the UI
+	Capabilities  Capabilities `json:"capabilities"`
+	Params        *Params      `json:"params,omitempty"`        // defaults, overridden by Config.Params
+	ContextWindow int          `json:"contextWindow,omitempty"` // input plus output tokens, 0 for the provider default
+	MaxOutput     int          `json:"maxOutput,omitempty"`     // most tokens one answer may take, 0 for no known limit
+}
+
+// matches reports whether id names m, either bare or as "provider/id".
+func (m Model) matches(id string) bool {
+	return m.ID == id || (m.Provider != "" && m.Provider+"/"+m.ID == id)
+}
+
+// Capabilities say what a model can take. Requests are fitted to them
+// before sending; see Client.Fit.
+type Capabilities struct {
+	Vision    bool `json:"vision"`
+	Documents bool `json:"documents,omitempty"` // reads attached PDFs; text files always work
+	Streaming bool `json:"streaming"`
+	Tools     bool `json:"tools,omitempty"`
+	Reasoning bool `json:"reasoning,omitempty"` // takes a reasoning effort or thinking budget
+}
+
+// Registry maps provider IDs to providers and model IDs to their declarations.
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+	models    map[string]Model
+	order     []string // model IDs in registration order
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: make(map[string]Provider),
+		models:    make(map[string]Model),
+	}
+}
+
+// Register adds p, replacing any provider with the same ID.
+func (r *Registry) Register(p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[p.ID()] = p
+}
+
+// RegisterModel declares m, replacing any model with the same ID.
+func (r *Registry) RegisterModel(m Model) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.models[m.ID]; !ok {
+		r.order = append(r.order, m.ID)
+	}
+	r.models[m.ID] = m
+}
+
+func (r *Registry) Provider(id string) (Provider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.providers[id]
+	return p, ok
+}
+
+// Model returns the declaration for id, which may be qualified with its
+// provider, e.g. "anthropic/claude-sonnet-4-6".
+func (r *Registry) Model(id string) (Model, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if m, ok := r.models[id]; ok {
+		return m, true
+	}
+	if _, name, ok := strings.Cut(id, "/"); ok {
+		if m, ok := r.models[name]; ok && m.matches(id) {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
+
+// Models returns the declared models in registration order.
+func (r *Registry) Models() []Model {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Model, 0, len(r.order))
+	for _, id := range r.order {
+		out = append(out, r.models[id])
+	}
+	return out
+}
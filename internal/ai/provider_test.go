@@ -0,0 +1,83 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type fakeProvider struct {
+	id  string
+	got Request
+}
+
+func (f *fakeProvider) ID() string { return f.id }
+
+func (f *fakeProvider) Send(_ context.Context, _ Config, req Request, onDelta DeltaFunc) (*Response, error) {
+	f.got = req
+	if onDelta != nil {
+		onDelta("streamed")
+	}
+	return &Response{Text: "from " + f.id, Usage: Usage{InputTokens: 3, OutputTokens: 2}}, nil
+}
+
+func TestSendRoutesDeclaredModelsToTheirProvider(t *testing.T) {
+	c := New()
+	fake := &fakeProvider{id: "acme"}
+	c.Registry().Register(fake)
+
+	got, err := c.Send(context.Background(), Config{
+		Model:  "acme-large",
+		Models: []Model{{ID: "acme-large", Provider: "acme", Capabilities: chatCaps}},
+	}, "prompt", []Message{{Role: "user", Content: "hi"}})
+	if err != nil || got != "from acme" {
+		t.Fatalf("Send() = %q, %v", got, err)
+	}
+	if fake.got.Model.ID != "acme-large" || fake.got.System != "prompt" {
+		t.Fatalf("provider got unexpected request: %+v", fake.got)
+	}
+}
+
+func TestSendRoutesQualifiedModelIDs(t *testing.T) {
+	c := New()
+	fake := &fakeProvider{id: "acme"}
+	c.Registry().Register(fake)
+
+	if _, err := c.Send(context.Background(), Config{Model: "acme/custom-v2"}, "", nil); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if fake.got.Model.ID != "custom-v2" || fake.got.Model.Provider != "acme" {
+		t.Fatalf("qualified model should be stripped of its provider prefix, got %+v", fake.got.Model)
+	}
+
+	_, err := c.Send(context.Background(), Config{Model: "nobody/custom-v2"}, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "is not a recognized provider") {
+		t.Fatalf("expected unknown provider error, got %v", err)
+	}
+}
+
+func TestSendStreamFallsBackForNonStreamingModels(t *testing.T) {
+	c := New()
+	c.Registry().Register(&fakeProvider{id: "acme"})
+	c.Registry().RegisterModel(Model{ID: "acme-batch", Provider: "acme"})
+
+	var deltas []string
+	got, err := c.SendStream(context.Background(), Config{Model: "acme-batch"}, "", nil, func(d string) {
+		deltas = append(deltas, d)
+	})
+	if err != nil || got != "from acme" || len(deltas) != 1 || deltas[0] != "from acme" {
+		t.Fatalf("SendStream() = %q, %v with deltas %q", got, err, deltas)
+	}
+}
+
+func TestRegistryModelsKeepRegistrationOrder(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterModel(Model{ID: "b"})
+	r.RegisterModel(Model{ID: "a"})
+	r.RegisterModel(Model{ID: "b", Name: "B"})
+
+	models := r.Models()
+	if len(models) != 2 || models[0].ID != "b" || models[0].Name != "B" || models[1].ID != "a" {
+		t.Fatalf("Models() = %+v", models)
+	}
+}
@@ -0,0 +1,108 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how transient provider failures are retried. The zero
+// value means DefaultRetryPolicy.
+type RetryPolicy struct {
+	MaxAttempts   int           // total attempts including the first
+	BaseDelay     time.Duration // delay before the first retry, doubled each time
+	MaxDelay      time.Duration // cap on the computed backoff
+	MaxRetryAfter time.Duration // give up instead of waiting longer than this
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:   4,
+	BaseDelay:     time.Second,
+	MaxDelay:      16 * time.Second,
+	MaxRetryAfter: time.Minute,
+}
+
+// RetryNotice describes a retry that is about to happen.
+type RetryNotice struct {
+	Attempt int // the attempt that failed, starting at 1
+	Delay   time.Duration
+	Err     error
+}
+
+// Message is a short human-readable progress line, e.g.
+// "Rate limited — retrying in 4s…".
+func (n RetryNotice) Message() string {
+	secs := int(math.Ceil(n.Delay.Seconds()))
+	return fmt.Sprintf("%s — retrying in %ds…", ErrorKindOf(n.Err).friendly(), secs)
+}
+
+func (p RetryPolicy) orDefault() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		return DefaultRetryPolicy
+	}
+	return p
+}
+
+// backoff returns the exponential delay with jitter before retry number
+// attempt (1-based), never shorter than the server's Retry-After.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	// Equal jitter: half fixed, half random, so clients spread out but
+	// never retry immediately.
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int64N(half+1))
+	}
+	if retryAfter > d {
+		d = retryAfter
+	}
+	return d
+}
+
+// withRetry calls send until it succeeds, fails with a non-retryable error,
+// or the policy runs out of attempts. A streamed request is never retried
+// once text has reached the caller.
+func withRetry(ctx context.Context, provider string, policy RetryPolicy, onRetry func(RetryNotice), onDelta DeltaFunc, send func(DeltaFunc) (*Response, error)) (*Response, error) {
+	policy = policy.orDefault()
+	for attempt := 1; ; attempt++ {
+		streamed := false
+		var delta DeltaFunc
+		if onDelta != nil {
+			delta = func(d string) {
+				streamed = true
+				onDelta(d)
+			}
+		}
+
+		resp, err := send(delta)
+		if err == nil {
+			return resp, nil
+		}
+		err = classifyError(provider, err)
+
+		var apiErr *APIError
+		if streamed || ctx.Err() != nil || !errors.As(err, &apiErr) || !apiErr.Retryable() || attempt >= policy.MaxAttempts {
+			return nil, err
+		}
+		if apiErr.RetryAfter > policy.MaxRetryAfter {
+			return nil, err
+		}
+
+		wait := policy.backoff(attempt, apiErr.RetryAfter)
+		if onRetry != nil {
+			onRetry(RetryNotice{Attempt: attempt, Delay: wait, Err: err})
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
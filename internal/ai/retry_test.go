@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond, MaxRetryAfter: time.Second}
+
+func TestSendRetriesRateLimitsAndReportsProgress(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0.01")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"slow down"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	var notices []RetryNotice
+	got, err := New().Send(context.Background(), Config{
+		Model:   "gpt-4.1",
+		Gateway: &Gateway{URL: srv.URL},
+		Retry:   fastRetry,
+		OnRetry: func(n RetryNotice) { notices = append(notices, n) },
+	}, "", []Message{{Role: "user", Content: "hi"}})
+	if err != nil || got != "ok" {
+		t.Fatalf("Send() = %q, %v", got, err)
+	}
+	if calls != 2 || len(notices) != 1 {
+		t.Fatalf("calls = %d, notices = %d; want 2 and 1", calls, len(notices))
+	}
+	if n := notices[0]; ErrorKindOf(n.Err) != ErrorRateLimit || n.Delay < 10*time.Millisecond {
+		t.Fatalf("unexpected notice: kind %s, delay %v", ErrorKindOf(n.Err), n.Delay)
+	}
+	if msg := notices[0].Message(); msg != "Rate limited — retrying in 1s…" {
+		t.Fatalf("Message() = %q", msg)
+	}
+}
+
+func TestSendReadsRetryAfterFromOpenAIStyleServers(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"message":"slow down"}}`)
+	}))
+	defer srv.Close()
+
+	_, err := New().Send(context.Background(), Config{
+		Model:    "local/llama3.2",
+		LocalURL: srv.URL,
+		Models:   []Model{{ID: "llama3.2", Provider: "local"}},
+		Retry:    fastRetry,
+	}, "", []Message{{Role: "user", Content: "hi"}})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Kind != ErrorRateLimit || apiErr.RetryAfter != 30*time.Second {
+		t.Fatalf("Send() error = %#v, want a rate limit with Retry-After 30s", err)
+	}
+	// Waiting longer than MaxRetryAfter is not worth it.
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+}
+
+func TestSendDoesNotRetryAuthErrors(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	_, err := New().Send(context.Background(), Config{
+		Model:   "claude-sonnet-4-6",
+		Gateway: &Gateway{URL: srv.URL},
+		Retry:   fastRetry,
+	}, "", []Message{{Role: "user", Content: "hi"}})
+	if calls != 1 || ErrorKindOf(err) != ErrorAuth {
+		t.Fatalf("calls = %d, kind = %s; want 1 and auth", calls, ErrorKindOf(err))
+	}
+}
+
+func TestSendGivesUpAfterMaxAttempts(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(529)
+	}))
+	defer srv.Close()
+
+	_, err := New().Send(context.Background(), Config{
+		Model:   "claude-sonnet-4-6",
+		Gateway: &Gateway{URL: srv.URL},
+		Retry:   fastRetry,
+	}, "", []Message{{Role: "user", Content: "hi"}})
+	if calls != fastRetry.MaxAttempts || ErrorKindOf(err) != ErrorOverloaded {
+		t.Fatalf("calls = %d, kind = %s", calls, ErrorKindOf(err))
+	}
+}
+
+func TestBackoffGrowsAndHonoursRetryAfter(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 8 * time.Second}
+	for attempt, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 8 * time.Second} {
+		d := p.backoff(attempt, 0)
+		if d < max/2 || d > max {
+			t.Fatalf("backoff(%d) = %v, want within [%v, %v]", attempt, d, max/2, max)
+		}
+	}
+	if d := p.backoff(1, 30*time.Second); d != 30*time.Second {
+		t.Fatalf("backoff should wait at least Retry-After, got %v", d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("4"); got != 4*time.Second {
+		t.Fatalf("parseRetryAfter(4) = %v", got)
+	}
+	date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got <= 0 || got > 10*time.Second {
+		t.Fatalf("parseRetryAfter(date) = %v", got)
+	}
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Fatalf("parseRetryAfter(soon) = %v", got)
+	}
+}
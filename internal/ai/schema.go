@@ -0,0 +1,197 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Schema describes the JSON document Extract asks a model for.
+type Schema struct {
+	Name        string         // identifies the output to the API, letters, digits, _ and - only
+	Description string         // what the output is for
+	JSON        map[string]any // JSON Schema of the document; the top level must be an object
+
+	// Strict asks OpenAI to enforce the schema while generating. Strict
+	// schemas must mark every property required and set
+	// "additionalProperties": false on every object.
+	Strict bool
+}
+
+// Validate checks that data is a JSON document matching s. It understands
+// the keywords structured-output schemas are written with: type, enum,
+// properties, required, additionalProperties, items, minItems, maxItems and
+// anyOf. Others are ignored.
+func (s Schema) Validate(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("not valid JSON: %w", err)
+	}
+	return validateValue(s.JSON, v, "$")
+}
+
+func validateValue(schema map[string]any, v any, path string) error {
+	if alts, ok := schema["anyOf"].([]any); ok {
+		var errs []string
+		for _, alt := range alts {
+			sub, _ := alt.(map[string]any)
+			err := validateValue(sub, v, path)
+			if err == nil {
+				errs = nil
+				break
+			}
+			errs = append(errs, err.Error())
+		}
+		if errs != nil {
+			return fmt.Errorf("%s matches none of the allowed forms (%s)", path, strings.Join(errs, "; "))
+		}
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return hasType(v, t) }) {
+		return fmt.Errorf("%s is %s, want %s", path, typeName(v), strings.Join(types, " or "))
+	}
+	if enum := schemaEnum(schema["enum"]); enum != nil && !slices.ContainsFunc(enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+		return fmt.Errorf("%s is %v, not one of the allowed values", path, v)
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		for _, r := range schemaStrings(schema["required"]) {
+			if _, ok := v[r]; !ok {
+				return fmt.Errorf("%s is missing %q", path, r)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			sub, ok := props[k].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					return fmt.Errorf("%s has unexpected property %q", path, k)
+				}
+				continue
+			}
+			if err := validateValue(sub, v[k], path+"."+k); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if n, ok := schemaInt(schema["minItems"]); ok && len(v) < n {
+			return fmt.Errorf("%s has %d items, want at least %d", path, len(v), n)
+		}
+		if n, ok := schemaInt(schema["maxItems"]); ok && len(v) > n {
+			return fmt.Errorf("%s has %d items, want at most %d", path, len(v), n)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validateValue(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// schemaTypes reads "type", which is a name or a list of names.
+func schemaTypes(t any) []string {
+	if s, ok := t.(string); ok {
+		return []string{s}
+	}
+	return schemaStrings(t)
+}
+
+// schemaStrings reads a list of strings, whether built in Go or decoded
+// from JSON.
+func schemaStrings(v any) []string {
+	switch v := v.(type) {
+	case []string:
+		return v
+	case []any:
+		var out []string
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// schemaEnum reads "enum", converting a Go []string to match decoded values.
+func schemaEnum(v any) []any {
+	switch v := v.(type) {
+	case []any:
+		return v
+	case []string:
+		out := make([]any, len(v))
+		for i, s := range v {
+			out[i] = s
+		}
+		return out
+	}
+	return nil
+}
+
+// schemaInt reads a count, whether built in Go or decoded from JSON.
+func schemaInt(v any) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+func hasType(v any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return true
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// readSSE calls fn with the data payload of each server-sent event in r.
+// It stops at the OpenAI-style "[DONE]" sentinel or at the end of the body.
+func readSSE(r io.Reader, fn func(data []byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	var data []byte
+	flush := func() (bool, error) {
+		if len(data) == 0 {
+			return false, nil
+		}
+		payload := data
+		data = nil
+		if string(payload) == "[DONE]" {
+			return true, nil
+		}
+		return false, fn(payload)
+	}
+
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			done, err := flush()
+			if done || err != nil {
+				return err
+			}
+			continue
+		}
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue // event names, ids and comments carry nothing we need
+		}
+		line = bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("data:")), []byte(" "))
+		if len(data) > 0 {
+			data = append(data, '\n')
+		}
+		data = append(data, line...)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read event stream: %w", err)
+	}
+	_, err := flush()
+	return err
+}
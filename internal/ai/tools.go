@@ -0,0 +1,44 @@
+package ai
+
+import "encoding/json"
+
+// Tool is a function the model may call. Parameters is a JSON Schema object
+// describing the arguments.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any
+}
+
+// ToolCall is the model asking to run a tool. Arguments is a JSON object.
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// schemaProperties splits a tool's schema into the parts Anthropic's API
+// takes separately.
+func (t Tool) schemaProperties() (properties any, required []string) {
+	properties = t.Parameters["properties"]
+	switch r := t.Parameters["required"].(type) {
+	case []string:
+		required = r
+	case []any:
+		for _, v := range r {
+			if s, ok := v.(string); ok {
+				required = append(required, s)
+			}
+		}
+	}
+	return properties, required
+}
+
+// toolInput returns a call's arguments as raw JSON, substituting an empty
+// object for missing or malformed input so the request stays valid.
+func toolInput(args string) json.RawMessage {
+	if args == "" || !json.Valid([]byte(args)) {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(args)
+}
@@ -0,0 +1,115 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var testTools = []Tool{{
+	Name:        "search_transcripts",
+	Description: "Search transcripts.",
+	Parameters: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"query": map[string]any{"type": "string"}},
+		"required":   []string{"query"},
+	},
+}}
+
+func TestCompleteStreamsOpenAIToolCalls(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"search_transcripts","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"query\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"budget\"}"}}]}}]}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	cfg := Config{
+		Model:    "local/llama3.2",
+		LocalURL: srv.URL,
+		Models:   []Model{{ID: "llama3.2", Provider: "local", Capabilities: Capabilities{Streaming: true, Tools: true}}},
+		Tools:    testTools,
+	}
+	history := []Message{
+		{Role: "user", Content: "what did we say about the budget?"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_0", Name: "list_transcripts", Arguments: "{}"}}},
+		{Role: "tool", ToolCallID: "call_0", Content: "2026-03-14-09-00-00 (12 lines)"},
+	}
+	resp, err := New().Complete(context.Background(), cfg, "sys", history, func(string) {})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	want := ToolCall{ID: "call_1", Name: "search_transcripts", Arguments: `{"query":"budget"}`}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0] != want {
+		t.Fatalf("ToolCalls = %+v, want %+v", resp.ToolCalls, want)
+	}
+
+	if tools := got["tools"].([]any); len(tools) != 1 {
+		t.Fatalf("tools = %v, want one tool", tools)
+	}
+	msgs := got["messages"].([]any)
+	if call := msgs[2].(map[string]any)["tool_calls"].([]any)[0].(map[string]any); call["id"] != "call_0" {
+		t.Fatalf("assistant tool call not sent back: %v", msgs[2])
+	}
+	if result := msgs[3].(map[string]any); result["role"] != "tool" || result["tool_call_id"] != "call_0" {
+		t.Fatalf("tool result = %v, want role tool for call_0", result)
+	}
+}
+
+func TestCompleteOmitsToolsForModelsWithoutThem(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`)
+	}))
+	defer srv.Close()
+
+	_, err := New().Complete(context.Background(), Config{
+		Model:    "local/llama3.2",
+		LocalURL: srv.URL,
+		Models:   []Model{{ID: "llama3.2", Provider: "local"}},
+		Tools:    testTools,
+	}, "", []Message{{Role: "user", Content: "hi"}}, nil)
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if _, ok := got["tools"]; ok {
+		t.Fatalf("tools sent to a model without tool support: %v", got["tools"])
+	}
+}
+
+func TestAnthropicMessagesMergesToolResults(t *testing.T) {
+	msgs := anthropicMessages([]Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "Let me look.", ToolCalls: []ToolCall{
+			{ID: "a", Name: "read_notes"},
+			{ID: "b", Name: "search_transcripts", Arguments: `{"query":"x"}`},
+		}},
+		{Role: "tool", ToolCallID: "a", Content: "notes"},
+		{Role: "tool", ToolCallID: "b", Content: "matches"},
+	})
+	if len(msgs) != 3 {
+		t.Fatalf("got %d messages, want 3", len(msgs))
+	}
+	if n := len(msgs[1].Content); n != 3 || msgs[1].Content[1].OfToolUse == nil {
+		t.Fatalf("assistant turn should carry text and two tool_use blocks, got %d blocks", n)
+	}
+	if input := msgs[1].Content[1].OfToolUse.Input; string(input.(json.RawMessage)) != "{}" {
+		t.Fatalf("missing arguments should become {}, got %s", input)
+	}
+	results := msgs[2].Content
+	if len(results) != 2 || results[0].OfToolResult.ToolUseID != "a" || results[1].OfToolResult.ToolUseID != "b" {
+		t.Fatalf("tool results should be merged into one user turn, got %+v", results)
+	}
+}
@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Network configures the HTTP transport shared by all providers.
+type Network struct {
+	ProxyURL       string // http(s) proxy; empty uses HTTPS_PROXY / HTTP_PROXY
+	CAFile         string // PEM bundle trusted in addition to the system roots
+	ClientCert     string // PEM client certificate for mTLS
+	ClientKey      string // PEM key for ClientCert
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration // longest silence while waiting for or reading a response
+}
+
+const (
+	defaultConnectTimeout = 15 * time.Second
+	defaultReadTimeout    = 5 * time.Minute
+)
+
+// NewHTTPClient builds a client from n. ReadTimeout is enforced per read
+// rather than as a whole-request deadline, so long streams aren't cut off
+// as long as tokens keep arriving.
+func NewHTTPClient(n Network) (*http.Client, error) {
+	connectTimeout := n.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = defaultConnectTimeout
+	}
+	readTimeout := n.ReadTimeout
+	if readTimeout <= 0 {
+		readTimeout = defaultReadTimeout
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if n.ProxyURL != "" {
+		u, err := url.Parse(n.ProxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", n.ProxyURL)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if n.CAFile != "" {
+		pem, err := os.ReadFile(n.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", n.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if n.ClientCert != "" || n.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(n.ClientCert, n.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &idleTimeoutConn{Conn: conn, timeout: readTimeout}, nil
+		},
+		TLSClientConfig:       tlsCfg,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: readTimeout,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          20,
+		ForceAttemptHTTP2:     true,
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// idleTimeoutConn fails a read that sees no data for timeout.
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+// httpClient returns the shared client, or http.DefaultClient when none is
+// configured.
+func (c Config) httpClient() *http.Client {
+	return orDefaultClient(c.HTTPClient)
+}
+
+func orDefaultClient(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientTrustsCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{Model: "gpt-4.1", Gateway: &Gateway{URL: srv.URL}}
+	msgs := []Message{{Role: "user", Content: "hi"}}
+	_, err := New().Send(context.Background(), cfg, "", msgs)
+	if kind := ErrorKindOf(err); kind != ErrorTLS {
+		t.Fatalf("default client error = %v (kind %q), want an untrusted certificate", err, kind)
+	}
+
+	client, err := NewHTTPClient(Network{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	cfg.HTTPClient = client
+	if _, err := New().Send(context.Background(), cfg, "", msgs); err != nil {
+		t.Fatalf("Send() with CA file error = %v", err)
+	}
+}
+
+func TestHTTPClientUsesProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String() // absolute URL when the client talks to a proxy
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(Network{ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	_, err = New().Send(context.Background(), Config{
+		Model:      "gpt-4.1",
+		Gateway:    &Gateway{URL: "http://gateway.invalid/v1/chat"},
+		HTTPClient: client,
+	}, "", []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if proxied != "http://gateway.invalid/v1/chat" {
+		t.Fatalf("proxy saw %q, want the gateway URL", proxied)
+	}
+}
+
+func TestHTTPClientReadTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client, err := NewHTTPClient(Network{ReadTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	_, err = New().Send(context.Background(), Config{
+		Model:      "gpt-4.1",
+		Gateway:    &Gateway{URL: srv.URL},
+		HTTPClient: client,
+		Retry:      RetryPolicy{MaxAttempts: 1},
+	}, "", []Message{{Role: "user", Content: "hi"}})
+	if kind := ErrorKindOf(err); kind != ErrorTimeout {
+		t.Fatalf("error = %v (kind %q), want a timeout", err, kind)
+	}
+}
+
+func TestNewHTTPClientRejectsBadSettings(t *testing.T) {
+	if _, err := NewHTTPClient(Network{ProxyURL: "not a url"}); err == nil {
+		t.Fatal("expected an error for an invalid proxy URL")
+	}
+	if _, err := NewHTTPClient(Network{CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Fatal("expected an error for a missing CA file")
+	}
+}
@@ -2,15 +2,22 @@ package app
 
 import (
 	"context"
+	"crypto/sha256"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"lay/internal/ai"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 //go:embed all:defaults
@@ -20,35 +27,92 @@ type App struct {
 	ctx               context.Context
 	aiClient          *ai.Client
 	currentTranscript string
+	currentSession    string // the recording the transcript comes from, see saveTranscript
 	liveCancel        context.CancelFunc
 	liveChunkSeq      int
 	liveSegments      []string
 	liveMu            sync.Mutex
+	inflight          map[string]*context.CancelFunc // chat requests by request ID
+	inflightMu        sync.Mutex
+	localModels       []ai.Model // last listing from the local model server
+	localModelsMu     sync.Mutex
+	modelsMu          sync.Mutex                    // guards ~/.lay/models.json
+	logins            map[string]context.CancelFunc // pending device logins by gateway name
+	loginMu           sync.Mutex
+	gatewayHealth     map[string]GatewayStatus // by gateway name, see watchGateways
+	gatewayHealthMu   sync.Mutex
+	http              *http.Client // shared by all providers, built from httpNetwork
+	httpNetwork       NetworkConfig
+	httpMu            sync.Mutex
+	usageMu           sync.Mutex                   // guards ~/.lay/usage.jsonl
+	summaries         map[[sha256.Size]byte]string // see summarize
+	summariesMu       sync.Mutex
+	chatsMu           sync.Mutex // guards ~/.lay/chats
 }
 
+// ErrCancelled is returned by SendMessage when CancelMessage aborts the request.
+var ErrCancelled = errors.New("request cancelled")
+
 type Config struct {
-	AnthropicKey   string `json:"anthropicKey"`
-	OpenAIKey      string `json:"openaiKey"`
-	Model          string `json:"model"`
-	GatewayURL     string `json:"gatewayURL"`     // full gateway endpoint URL, or "" (disabled)
-	TranscribeLang string `json:"transcribeLang"` // whisper -l value, "" or "auto" means auto-detect
-}
+	AnthropicKey   string        `json:"anthropicKey"`
+	OpenAIKey      string        `json:"openaiKey"`
+	GeminiKey      string        `json:"geminiKey"`
+	Model          string        `json:"model"`
+	GatewayURL     string        `json:"gatewayURL"`        // full gateway endpoint URL, or "" (disabled)
+	LocalURL       string        `json:"localURL"`          // Ollama / llama-server base URL, or "" (disabled)
+	TranscribeLang string        `json:"transcribeLang"`    // whisper -l value, "" or "auto" means auto-detect
+	Models         []ai.Model    `json:"models,omitempty"`  // extra model declarations, e.g. custom or fine-tuned IDs
+	Network        NetworkConfig `json:"network,omitempty"` // proxy, CA and timeouts
+	Budget         BudgetConfig  `json:"budget,omitempty"`  // spending caps
 
-type GatewayModel struct {
-	Value string `json:"value"`
-	Label string `json:"label"`
-}
+	// GatewayModels sends a model through the named gateway first, while
+	// it is up, instead of in priority order.
+	GatewayModels map[string]string `json:"gatewayModels,omitempty"`
 
-type GatewayConfig struct {
-	Name   string         `json:"name"`
-	URL    string         `json:"url"`
-	Models []GatewayModel `json:"models"`
+	// Fallbacks are tried in order when Model is unavailable. Entries are
+	// model IDs; "gateway/<model>" routes one through the gateway.
+	Fallbacks  []string       `json:"fallbacks,omitempty"`
+	FallbackOn []ai.ErrorKind `json:"fallbackOn,omitempty"` // defaults to outages: overloaded, server, timeout, network
+
+	Params map[string]ai.Params `json:"params,omitempty"` // generation parameters per model ID
 }
 
+// Message is one turn of a conversation, as saved in ~/.lay/chats. Messages
+// form a tree: an edited question or a regenerated answer is a sibling of
+// the one it replaces, with the same parent.
 type Message struct {
-	Role    string   `json:"role"`
-	Content string   `json:"content"`
-	Images  []string `json:"images,omitempty"` // base64-encoded image data
+	ID         string        `json:"id"`
+	ParentID   string        `json:"parentId,omitempty"` // the message this one follows, "" for the first question
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	Images     []string      `json:"images,omitempty"`     // base64-encoded image data
+	Documents  []ai.Document `json:"documents,omitempty"`  // attached PDFs and text files
+	Thinking   string        `json:"thinking,omitempty"`   // the model's reasoning, on answers
+	Model      string        `json:"model,omitempty"`      // the model that answered
+	FellBack   bool          `json:"fellBack,omitempty"`   // Model stood in for the chosen one
+	Compacted  string        `json:"compacted,omitempty"`  // what was summarized to fit the context window
+	Adapted    string        `json:"adapted,omitempty"`    // what was left out because the model cannot take it
+	Transcript string        `json:"transcript,omitempty"` // the meeting asked about, named as in ~/.lay/transcripts
+	CreatedAt  time.Time     `json:"createdAt"`
+}
+
+// ChatEvent is the payload of the chat:delta, chat:thinking, chat:retry,
+// chat:fallback, chat:tool, chat:adapted, chat:compacted, chat:done and
+// chat:error events.
+// RequestID is chosen by the frontend so it can match events to the message
+// being rendered.
+type ChatEvent struct {
+	RequestID string       `json:"requestId"`
+	Delta     string       `json:"delta,omitempty"`
+	Content   string       `json:"content,omitempty"`
+	Thinking  string       `json:"thinking,omitempty"` // reasoning delta on chat:thinking, all of it on chat:done
+	Error     string       `json:"error,omitempty"`
+	Kind      ai.ErrorKind `json:"kind,omitempty"`    // rate_limit, auth, overloaded… on chat:error and chat:retry
+	Notice    string       `json:"notice,omitempty"`  // on chat:retry, chat:fallback, chat:adapted and chat:compacted
+	RetryIn   float64      `json:"retryIn,omitempty"` // seconds until the next attempt
+	Cancelled bool         `json:"cancelled,omitempty"`
+	Tool      *ToolEvent   `json:"tool,omitempty"`  // on chat:tool
+	Model     string       `json:"model,omitempty"` // on chat:done, the model that answered
 }
 
 func New() *App {
@@ -58,6 +122,7 @@ func New() *App {
 func (a *App) Startup(ctx context.Context) {
 	a.ctx = ctx
 	_ = os.MkdirAll(layDir(), 0o755)
+	go a.watchGateways(ctx)
 }
 
 func layDir() string {
@@ -100,28 +165,93 @@ func (a *App) GetConfig() Config {
 	return cfg
 }
 
-func (a *App) GetGatewayConfig() *GatewayConfig {
-	// User override: ~/.lay/gateway.json
-	data, err := os.ReadFile(filepath.Join(layDir(), "gateway.json"))
-	if err != nil {
-		// Fall back to build-time embedded default
-		data, err = defaults.ReadFile("defaults/gateway.json")
+// GetModels returns the built-in models, those declared in config.json and,
+// when a local model server is configured, the models it serves.
+func (a *App) GetModels() []ai.Model {
+	cfg := a.GetConfig()
+	models := a.aiClient.Registry().Models()
+	models = append(models, cfg.Models...)
+	return append(models, a.refreshLocalModels(cfg)...)
+}
+
+// refreshLocalModels lists the local server's models and remembers them so
+// SendMessage knows their capabilities. Errors leave the list empty: an
+// unreachable server just means there is nothing local to offer.
+func (a *App) refreshLocalModels(cfg Config) []ai.Model {
+	var models []ai.Model
+	if client, err := a.httpClient(cfg); err == nil && cfg.LocalURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		models, _ = ai.ListLocalModels(ctx, client, cfg.LocalURL)
+	}
+	a.localModelsMu.Lock()
+	a.localModels = models
+	a.localModelsMu.Unlock()
+	return models
+}
+
+func (a *App) knownLocalModels(cfg Config) []ai.Model {
+	a.localModelsMu.Lock()
+	models := a.localModels
+	a.localModelsMu.Unlock()
+	if models == nil && cfg.LocalURL != "" {
+		models = a.refreshLocalModels(cfg)
+	}
+	return models
+}
+
+// SaveConfig updates the settings editable in the UI. Fields that are only
+// set by hand in config.json are kept as they are.
+func (a *App) SaveConfig(anthropicKey string, openAIKey string, geminiKey string, model string, gatewayURL string, localURL string, transcribeLang string) error {
+	cfg := a.GetConfig()
+	cfg.AnthropicKey = anthropicKey
+	cfg.OpenAIKey = openAIKey
+	cfg.GeminiKey = geminiKey
+	cfg.Model = model
+	cfg.GatewayURL = gatewayURL
+	cfg.LocalURL = localURL
+	cfg.TranscribeLang = transcribeLang
+	return a.writeConfig(cfg)
+}
+
+// aiConfig builds the client configuration for a request with the chosen
+// model and its fallbacks. With several gateways, the chosen model is
+// tried through each before the fallbacks.
+func (a *App) aiConfig(cfg Config, client *http.Client) (ai.Config, error) {
+	aiCfg := ai.Config{
+		AnthropicKey: cfg.AnthropicKey,
+		OpenAIKey:    cfg.OpenAIKey,
+		GeminiKey:    cfg.GeminiKey,
+		Model:        cfg.Model,
+		LocalURL:     cfg.LocalURL,
+		Models:       slices.Concat(cfg.Models, a.declaredGatewayModels(cfg), a.discoveredModels(cfg)),
+		HTTPClient:   client,
+		Params:       cfg.Params,
+		Fallbacks:    a.fallbacksFor(cfg),
+		FallbackOn:   cfg.FallbackOn,
 	}
+	gateways, err := a.gatewaysFor(cfg, cfg.Model)
 	if err != nil {
-		return nil
+		return ai.Config{}, err
 	}
-	var gw GatewayConfig
-	if json.Unmarshal(data, &gw) != nil {
-		return nil
+	if len(gateways) > 0 {
+		aiCfg.Gateway = gateways[0]
+		if !isLocal(cfg.Model) {
+			aiCfg.Fallbacks = append(failoverFor(cfg.Model, gateways), aiCfg.Fallbacks...)
+		}
 	}
-	if gw.Name == "" || gw.URL == "" {
-		return nil
+	if isLocal(cfg.Model) || slices.ContainsFunc(cfg.Fallbacks, isLocal) {
+		aiCfg.Models = append(aiCfg.Models, a.knownLocalModels(cfg)...)
 	}
-	return &gw
+	return aiCfg, nil
+}
+
+// isLocal reports whether model runs on the local model server.
+func isLocal(model string) bool {
+	return strings.HasPrefix(model, ai.LocalProvider+"/")
 }
 
-func (a *App) SaveConfig(anthropicKey string, openAIKey string, model string, gatewayURL string, transcribeLang string) error {
-	cfg := Config{AnthropicKey: anthropicKey, OpenAIKey: openAIKey, Model: model, GatewayURL: gatewayURL, TranscribeLang: transcribeLang}
+func (a *App) writeConfig(cfg Config) error {
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
@@ -129,31 +259,333 @@ func (a *App) SaveConfig(anthropicKey string, openAIKey string, model string, ga
 	return os.WriteFile(filepath.Join(layDir(), "config.json"), data, 0o600)
 }
 
-func (a *App) SendMessage(conversationJSON string) (string, error) {
+// SendMessage adds the question in messageJSON to the saved conversation
+// with the given ID, starting it if the ID is new, streams the answer as
+// chat:delta events and saves it once it is complete. The question follows
+// the last answer of the active branch; one left without an answer, because
+// its request failed, is kept as an earlier version of this one, so the
+// model never sees two questions in a row.
+func (a *App) SendMessage(requestID string, conversationID string, messageJSON string) (string, error) {
+	var msg Message
+	if err := json.Unmarshal([]byte(messageJSON), &msg); err != nil {
+		return "", fmt.Errorf("invalid message format: %w", err)
+	}
+	if err := checkNotEmpty(msg); err != nil {
+		a.emit("chat:error", ChatEvent{RequestID: requestID, Error: err.Error(), Kind: ai.ErrorInvalid})
+		return "", err
+	}
+	docs, err := checkDocuments(msg.Documents)
+	if err != nil {
+		a.emit("chat:error", ChatEvent{RequestID: requestID, Error: err.Error(), Kind: ai.ErrorInvalid})
+		return "", err
+	}
+	msg.Documents = docs
+	return a.answer(requestID, conversationID, true, func(c *Conversation) (string, error) {
+		parent := c.Current
+		if m := c.message(parent); m != nil && m.Role == "user" {
+			parent = m.ParentID
+		}
+		return a.ask(c, msg, parent), nil
+	})
+}
+
+// checkNotEmpty refuses a question with no text and nothing attached.
+func checkNotEmpty(m Message) error {
+	if strings.TrimSpace(m.Content) == "" && len(m.Images) == 0 && len(m.Documents) == 0 {
+		return fmt.Errorf("message is empty")
+	}
+	return nil
+}
+
+// ask adds the question m to c after the message parentID and returns its
+// ID.
+func (a *App) ask(c *Conversation, m Message, parentID string) string {
+	m.ID, m.ParentID, m.Role, m.CreatedAt, m.Transcript = "", parentID, "user", time.Now(), ""
+	if a.chatContext().transcript != "" {
+		m.Transcript = a.currentSession
+	}
+	return c.add(m)
+}
+
+// answer saves the change branch makes to the conversation, answers the
+// question whose ID branch returns, with the active branch up to it as the
+// history, and saves the answer after it. With create, a conversation that
+// doesn't exist yet is started.
+func (a *App) answer(requestID, conversationID string, create bool, branch func(*Conversation) (string, error)) (string, error) {
 	cfg := a.GetConfig()
 
+	var questionID string
 	var messages []Message
-	if err := json.Unmarshal([]byte(conversationJSON), &messages); err != nil {
-		return "", fmt.Errorf("invalid conversation format: %w", err)
+	err := a.updateConversation(conversationID, create, func(c *Conversation) error {
+		var err error
+		if questionID, err = branch(c); err != nil {
+			return err
+		}
+		messages = c.path(questionID)
+		return nil
+	})
+	if err != nil {
+		a.emit("chat:error", ChatEvent{RequestID: requestID, Error: err.Error(), Kind: ai.ErrorInvalid})
+		return "", err
 	}
 
-	aiCfg := ai.Config{
-		AnthropicKey: cfg.AnthropicKey,
-		OpenAIKey:    cfg.OpenAIKey,
-		Model:        cfg.Model,
-		GatewayURL:   cfg.GatewayURL,
+	client, err := a.httpClient(cfg)
+	if err != nil {
+		a.emit("chat:error", ChatEvent{RequestID: requestID, Error: err.Error(), Kind: ai.ErrorInvalid})
+		return "", err
+	}
+	if !isLocal(cfg.Model) {
+		if err := a.checkBudget(cfg.Budget); err != nil {
+			a.emit("chat:error", ChatEvent{RequestID: requestID, Error: err.Error(), Kind: ErrorBudget})
+			return "", err
+		}
+	}
+
+	aiCfg, err := a.aiConfig(cfg, client)
+	if err != nil {
+		a.emit("chat:error", ChatEvent{RequestID: requestID, Error: err.Error(), Kind: ai.ErrorInvalid})
+		return "", err
 	}
 
 	aiMessages := make([]ai.Message, 0, len(messages))
 	for _, m := range messages {
 		aiMessages = append(aiMessages, ai.Message{
-			Role:    m.Role,
-			Content: m.Content,
-			Images:  m.Images,
+			Role:      m.Role,
+			Content:   m.Content,
+			Images:    m.Images,
+			Documents: m.Documents,
+		})
+	}
+
+	aiCfg.OnRetry = func(n ai.RetryNotice) {
+		a.emit("chat:retry", ChatEvent{
+			RequestID: requestID,
+			Error:     n.Err.Error(),
+			Kind:      ai.ErrorKindOf(n.Err),
+			Notice:    n.Message(),
+			RetryIn:   n.Delay.Seconds(),
+		})
+	}
+	aiCfg.OnFallback = func(n ai.FallbackNotice) {
+		a.reportGatewayFailure(n.FromGateway, n.Err)
+		a.emit("chat:fallback", ChatEvent{
+			RequestID: requestID,
+			Error:     n.Err.Error(),
+			Kind:      ai.ErrorKindOf(n.Err),
+			Notice:    n.Message(),
+		})
+	}
+
+	aiCfg.Tools = chatTools
+	aiMessages, adapted, err := a.aiClient.Fit(aiCfg, aiMessages)
+	if err != nil {
+		a.emit("chat:error", ChatEvent{RequestID: requestID, Error: err.Error(), Kind: ai.ErrorKindOf(err)})
+		return "", err
+	}
+	if len(adapted) > 0 {
+		a.emit("chat:adapted", ChatEvent{RequestID: requestID, Notice: strings.Join(adapted, " ")})
+	}
+
+	ctx, done := a.trackRequest(requestID)
+	defer done()
+
+	cc, aiMessages, notice, err := a.compactContext(ctx, aiCfg, a.chatContext(), aiMessages, len(aiMessages)-1)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			a.emit("chat:error", ChatEvent{RequestID: requestID, Error: ErrCancelled.Error(), Cancelled: true})
+			return "", ErrCancelled
+		}
+		a.emit("chat:error", ChatEvent{RequestID: requestID, Error: err.Error(), Kind: ai.ErrorKindOf(err)})
+		return "", err
+	}
+	if notice != "" {
+		a.emit("chat:compacted", ChatEvent{RequestID: requestID, Notice: notice})
+	}
+	system := cc.blocks()
+
+	// Tool calls go back to the model with their results until it answers
+	// without calling any. Text and reasoning from every round make up the
+	// reply.
+	var reply, thinking strings.Builder
+	var streamed strings.Builder // what the user has seen, kept if they stop the answer
+	for round := 1; ; round++ {
+		if round > 1 {
+			var more string
+			cc, aiMessages, more, err = a.fitToolRounds(ctx, aiCfg, cc, aiMessages)
+			if err != nil {
+				if errors.Is(ctx.Err(), context.Canceled) {
+					if streamed.Len() > 0 {
+						_ = a.saveAnswer(conversationID, Message{Role: "assistant", ParentID: questionID, Content: streamed.String(), Model: aiCfg.Model})
+					}
+					a.emit("chat:error", ChatEvent{RequestID: requestID, Error: ErrCancelled.Error(), Cancelled: true})
+					return "", ErrCancelled
+				}
+				a.emit("chat:error", ChatEvent{RequestID: requestID, Error: err.Error(), Kind: ai.ErrorKindOf(err)})
+				return "", err
+			}
+			if more != "" {
+				notice = strings.TrimSpace(notice + " " + more)
+				a.emit("chat:compacted", ChatEvent{RequestID: requestID, Notice: notice})
+				system = cc.blocks()
+			}
+		}
+		separate, separateThinking := reply.Len() > 0, thinking.Len() > 0
+		aiCfg.OnThinking = func(delta string) {
+			if separateThinking {
+				a.emit("chat:thinking", ChatEvent{RequestID: requestID, Thinking: "\n\n"})
+				separateThinking = false
+			}
+			a.emit("chat:thinking", ChatEvent{RequestID: requestID, Thinking: delta})
+		}
+		resp, err := a.aiClient.CompleteBlocks(ctx, aiCfg, system, aiMessages, func(delta string) {
+			if separate {
+				a.emit("chat:delta", ChatEvent{RequestID: requestID, Delta: "\n\n"})
+				streamed.WriteString("\n\n")
+				separate = false
+			}
+			a.emit("chat:delta", ChatEvent{RequestID: requestID, Delta: delta})
+			streamed.WriteString(delta)
 		})
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				if streamed.Len() > 0 {
+					_ = a.saveAnswer(conversationID, Message{Role: "assistant", ParentID: questionID, Content: streamed.String(), Model: aiCfg.Model})
+				}
+				a.emit("chat:error", ChatEvent{RequestID: requestID, Error: ErrCancelled.Error(), Cancelled: true})
+				return "", ErrCancelled
+			}
+			a.emit("chat:error", ChatEvent{RequestID: requestID, Error: err.Error(), Kind: ai.ErrorKindOf(err)})
+			return "", err
+		}
+		// A ledger write failure must not cost the user their answer.
+		_ = a.recordUsage(resp)
+		// Later rounds stay on the model that answered.
+		aiCfg = aiCfg.FromFallback(resp)
+		if resp.Text != "" {
+			if reply.Len() > 0 {
+				reply.WriteString("\n\n")
+			}
+			reply.WriteString(resp.Text)
+		}
+		if t := resp.ThinkingText(); t != "" {
+			if thinking.Len() > 0 {
+				thinking.WriteString("\n\n")
+			}
+			thinking.WriteString(t)
+		}
+		if len(resp.ToolCalls) == 0 {
+			break
+		}
+		if round > maxToolRounds {
+			// The model still wants tools; say so rather than leave the
+			// answer looking cut off.
+			if reply.Len() > 0 {
+				reply.WriteString("\n\n")
+			}
+			fmt.Fprintf(&reply, "_Stopped after %d rounds of tool calls, so this answer may be incomplete._", maxToolRounds)
+			break
+		}
+
+		aiMessages = append(aiMessages, ai.Message{Role: "assistant", Content: resp.Text, ToolCalls: resp.ToolCalls, Thinking: resp.Thinking})
+		for _, call := range resp.ToolCalls {
+			ev := ToolEvent{ID: call.ID, Name: call.Name, Arguments: call.Arguments, Status: "running"}
+			a.emit("chat:tool", ChatEvent{RequestID: requestID, Tool: &ev})
+
+			// The last round's calls are refused, not run: the model is told
+			// they failed, so they must not have side effects.
+			var result string
+			if round == maxToolRounds {
+				err = fmt.Errorf("tool call limit reached — answer with what you have")
+			} else {
+				result, err = a.runTool(call)
+			}
+			ev.Status = "done"
+			if err != nil {
+				ev.Status = "error"
+				result = "Error: " + err.Error()
+			}
+			result = clip(result, maxToolResultChars)
+			ev.Result = clip(result, 300)
+			a.emit("chat:tool", ChatEvent{RequestID: requestID, Tool: &ev})
+			aiMessages = append(aiMessages, ai.Message{Role: "tool", Content: result, ToolCallID: call.ID})
+		}
+	}
+
+	err = a.saveAnswer(conversationID, Message{
+		Role:      "assistant",
+		ParentID:  questionID,
+		Content:   reply.String(),
+		Thinking:  thinking.String(),
+		Model:     aiCfg.Model,
+		FellBack:  aiCfg.Model != cfg.Model,
+		Compacted: notice,
+		Adapted:   strings.Join(adapted, " "),
+	})
+	if err != nil {
+		err = fmt.Errorf("the answer could not be saved: %w", err)
+		a.emit("chat:error", ChatEvent{RequestID: requestID, Error: err.Error()})
+		return "", err
+	}
+	a.emit("chat:done", ChatEvent{RequestID: requestID, Content: reply.String(), Thinking: thinking.String(), Model: aiCfg.Model})
+	return reply.String(), nil
+}
+
+// saveAnswer adds an answer to the saved conversation and makes its branch
+// the active one. A conversation deleted while the answer streamed is not
+// brought back.
+func (a *App) saveAnswer(conversationID string, m Message) error {
+	return a.updateConversation(conversationID, false, func(c *Conversation) error {
+		c.add(m)
+		return nil
+	})
+}
+
+// CancelMessage aborts the in-flight SendMessage call with the given request
+// ID. Unknown or already finished requests are ignored.
+func (a *App) CancelMessage(requestID string) {
+	a.inflightMu.Lock()
+	cancel := a.inflight[requestID]
+	a.inflightMu.Unlock()
+	if cancel != nil {
+		(*cancel)()
+	}
+}
+
+// trackRequest derives a cancellable context for a chat request from the app
+// context and registers it under requestID until done is called. A reused
+// ID takes over the entry; the earlier request's done leaves it alone.
+func (a *App) trackRequest(requestID string) (ctx context.Context, done func()) {
+	parent := a.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+
+	a.inflightMu.Lock()
+	if a.inflight == nil {
+		a.inflight = make(map[string]*context.CancelFunc)
+	}
+	entry := &cancel
+	a.inflight[requestID] = entry
+	a.inflightMu.Unlock()
+
+	return ctx, func() {
+		a.inflightMu.Lock()
+		if a.inflight[requestID] == entry {
+			delete(a.inflight, requestID)
+		}
+		a.inflightMu.Unlock()
+		cancel()
 	}
+}
 
-	return a.aiClient.Send(context.Background(), aiCfg, a.systemPrompt(), aiMessages)
+// emit sends a Wails event to the frontend. It is a no-op before Startup so
+// the app can be exercised without a running window.
+func (a *App) emit(name string, data ...interface{}) {
+	if a.ctx == nil {
+		return
+	}
+	runtime.EventsEmit(a.ctx, name, data...)
 }
 
 func (a *App) ExportToFile(content string, path string) error {
@@ -174,20 +606,3 @@ func (a *App) GetHomePath() string {
 	home, _ := os.UserHomeDir()
 	return home
 }
-
-func (a *App) systemPrompt() string {
-	base := "You are a helpful meeting assistant. Be concise and practical. Format responses in markdown when it aids clarity."
-
-	if a.currentTranscript != "" {
-		return base + "\n\nThe user has a meeting transcript from this session. Use it to answer questions about the meeting.\n\n<transcript>\n" + a.currentTranscript + "\n</transcript>"
-	}
-
-	a.liveMu.Lock()
-	live := strings.Join(a.liveSegments, "\n")
-	a.liveMu.Unlock()
-
-	if live == "" {
-		return base
-	}
-	return base + "\n\nThe meeting is currently being recorded. Below is the live transcript so far — it may be incomplete.\n\n<transcript>\n" + live + "\n</transcript>"
-}
@@ -0,0 +1,280 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lay/internal/ai"
+)
+
+func TestCancelMessageCancelsTrackedRequest(t *testing.T) {
+	a := New()
+	ctx, done := a.trackRequest("req-1")
+	defer done()
+
+	a.CancelMessage("unknown") // must be a no-op
+	if ctx.Err() != nil {
+		t.Fatalf("unrelated cancel should not affect request")
+	}
+
+	a.CancelMessage("req-1")
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Fatalf("ctx.Err() = %v, want context.Canceled", ctx.Err())
+	}
+}
+
+func TestTrackRequestDoneUnregisters(t *testing.T) {
+	a := New()
+	_, done := a.trackRequest("req-1")
+	done()
+
+	a.inflightMu.Lock()
+	n := len(a.inflight)
+	a.inflightMu.Unlock()
+	if n != 0 {
+		t.Fatalf("expected no in-flight requests after done, got %d", n)
+	}
+}
+
+func TestTrackRequestReusedID(t *testing.T) {
+	a := New()
+	_, doneFirst := a.trackRequest("req-1")
+	ctx, done := a.trackRequest("req-1")
+	defer done()
+
+	// The first request finishing must not unregister the second.
+	doneFirst()
+	a.CancelMessage("req-1")
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Fatalf("ctx.Err() = %v, want the second request cancelled", ctx.Err())
+	}
+}
+
+func TestSaveConfigKeepsHandEditedFields(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := New()
+	if err := os.MkdirAll(layDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	custom := `{"model":"acme-large","models":[{"id":"acme-large","provider":"openai","name":"Acme"}]}`
+	if err := os.WriteFile(filepath.Join(layDir(), "config.json"), []byte(custom), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SaveConfig("ak", "ok", "gk", "acme-large", "", "", "en"); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+
+	cfg := a.GetConfig()
+	if cfg.AnthropicKey != "ak" || cfg.GeminiKey != "gk" || cfg.TranscribeLang != "en" {
+		t.Fatalf("UI fields not saved: %+v", cfg)
+	}
+	if len(cfg.Models) != 1 || cfg.Models[0].ID != "acme-large" {
+		t.Fatalf("declared models were dropped: %+v", cfg.Models)
+	}
+	if models := a.GetModels(); models[len(models)-1].Name != "Acme" {
+		t.Fatalf("GetModels() should end with declared models, got %+v", models)
+	}
+}
+
+func TestGatewayForExpandsSecretReferences(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("LAY_TEST_TOKEN", "s3cret")
+	t.Setenv("LAY_TEST_TENANT", "acme")
+	a := New()
+	if err := os.MkdirAll(layDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.gatewaysFor(Config{GatewayURL: "https://gw.example.com"}, "gpt-4.1"); err == nil {
+		t.Fatal("expected an error for a gateway URL without gateway.json")
+	}
+
+	gw := `{"name":"Corp","url":"https://gw.example.com","models":[],
+		"headers":{"X-Tenant":"${LAY_TEST_TENANT}"},
+		"auth":{"type":"bearer","token":"tok-${LAY_TEST_TOKEN}-$KEEP"},
+		"dialect":"custom","custom":{"request":{"m":"{{model}}"},"response":{"text":"$.out"}}}`
+	if err := os.WriteFile(filepath.Join(layDir(), "gateway.json"), []byte(gw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := a.gatewaysFor(Config{}, "gpt-4.1"); got != nil || err != nil {
+		t.Fatalf("gatewaysFor() with no gateway URL = %+v, %v, want nil", got, err)
+	}
+	// A URL that drifted from gateway.json keeps the configured gateway.
+	if got, err := a.gatewaysFor(Config{GatewayURL: "https://GW.example.com/"}, "gpt-4.1"); err != nil || len(got) != 1 || got[0].URL != "https://gw.example.com" || got[0].Auth == nil {
+		t.Fatalf("gatewaysFor() with a trailing slash = %+v, %v", got, err)
+	}
+	gws, err := a.gatewaysFor(Config{GatewayURL: "https://gw.example.com"}, "gpt-4.1")
+	if err != nil || len(gws) != 1 || gws[0].Auth == nil {
+		t.Fatalf("gatewaysFor() = %+v, %v, want auth from gateway.json", gws, err)
+	}
+	got := gws[0]
+	if got.Headers["X-Tenant"] != "acme" {
+		t.Fatalf("header = %q, want acme", got.Headers["X-Tenant"])
+	}
+	if got.Auth.Token != "tok-s3cret-$KEEP" {
+		t.Fatalf("token = %q, want tok-s3cret-$KEEP", got.Auth.Token)
+	}
+	if got.Dialect != ai.DialectCustom || got.Custom == nil || got.Custom.Text != "$.out" || string(got.Custom.Request) != `{"m":"{{model}}"}` {
+		t.Fatalf("dialect = %q, custom = %+v", got.Dialect, got.Custom)
+	}
+}
+
+func TestFallbacksForRoutesGatewayEntries(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := New()
+	if err := os.MkdirAll(layDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Fallbacks: []string{"gpt-4.1", "gateway/claude-sonnet-4-6"}}
+
+	// Without gateway.json the gateway entry has nowhere to go.
+	if got := a.fallbacksFor(cfg); len(got) != 1 || got[0].Model != "gpt-4.1" || got[0].Gateway != nil {
+		t.Fatalf("fallbacksFor() = %+v", got)
+	}
+
+	gw := `{"name":"Corp","url":"https://gw.example.com","models":[]}`
+	if err := os.WriteFile(filepath.Join(layDir(), "gateway.json"), []byte(gw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got := a.fallbacksFor(cfg)
+	if len(got) != 2 || got[1].Model != "claude-sonnet-4-6" || got[1].Gateway == nil || got[1].Gateway.URL != "https://gw.example.com" {
+		t.Fatalf("fallbacksFor() = %+v", got)
+	}
+}
+
+func TestLogoutGatewayForgetsToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := New()
+	if err := os.MkdirAll(layDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	gw := `{"name":"Corp","url":"https://gw.example.com","models":[],
+		"auth":{"type":"oauth2","flow":"deviceCode","tokenURL":"https://sso.example.com/token","clientID":"lay"}}`
+	if err := os.WriteFile(filepath.Join(layDir(), "gateway.json"), []byte(gw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ai.SaveOAuthToken(gatewayTokenFile(), &ai.OAuthToken{AccessToken: "at"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !a.GetGatewayAuthStatus("").LoggedIn {
+		t.Fatal("expected a cached login")
+	}
+	if err := a.LogoutGateway("Corp"); err != nil {
+		t.Fatalf("LogoutGateway() error = %v", err)
+	}
+	if a.GetGatewayAuthStatus("Corp").LoggedIn {
+		t.Fatal("expected no login after LogoutGateway")
+	}
+}
+
+func TestGatewayLoginPerGateway(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := New()
+	if err := os.MkdirAll(layDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The device endpoint serves both gateways; each login must land in its
+	// own gateway's token file.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/device":
+			fmt.Fprintf(w, `{"device_code":"dc-%s","user_code":"CODE","verification_uri":"https://sso.example.com/activate","interval":1}`, r.Form.Get("client_id"))
+		case "/token":
+			fmt.Fprintf(w, `{"access_token":"at-%s","token_type":"Bearer","expires_in":3600}`, r.Form.Get("client_id"))
+		}
+	}))
+	defer srv.Close()
+	gw := fmt.Sprintf(`{"gateways":[
+		{"name":"Corp","url":"https://gw.example.com","auth":{"type":"oauth2","flow":"deviceCode","tokenURL":"%[1]s/token","deviceAuthURL":"%[1]s/device","clientID":"corp"}},
+		{"name":"Backup","url":"https://backup.example.com","priority":1,"auth":{"type":"oauth2","flow":"deviceCode","tokenURL":"%[1]s/token","deviceAuthURL":"%[1]s/device","clientID":"backup"}}]}`, srv.URL)
+	if err := os.WriteFile(filepath.Join(layDir(), "gateway.json"), []byte(gw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.StartGatewayLogin("Backup"); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); !a.GetGatewayAuthStatus("Backup").LoggedIn; time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("login did not finish")
+		}
+	}
+	if !a.GetGatewayAuthStatus("Backup").LoggedIn || a.GetGatewayAuthStatus("Corp").LoggedIn {
+		t.Fatal("expected only Backup to be signed in")
+	}
+	backup := a.GetGateways()[1]
+	tok, err := ai.LoadOAuthToken(backup.tokenFile())
+	if err != nil || tok.AccessToken != "at-backup" {
+		t.Fatalf("Backup token = %+v, %v", tok, err)
+	}
+
+	if err := a.LogoutGateway("Backup"); err != nil {
+		t.Fatal(err)
+	}
+	if a.GetGatewayAuthStatus("Backup").LoggedIn {
+		t.Fatal("expected no Backup login after LogoutGateway")
+	}
+	if _, err := a.StartGatewayLogin("Nope"); err == nil {
+		t.Fatal("expected an error for an unknown gateway")
+	}
+}
+
+func TestGatewayTokensSurviveReordering(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := New()
+	if err := os.MkdirAll(layDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(corp, backup int) {
+		t.Helper()
+		gw := fmt.Sprintf(`{"gateways":[
+			{"name":"Corp","url":"https://gw.example.com","priority":%d,"auth":{"type":"oauth2","clientID":"corp"}},
+			{"name":"Backup","url":"https://backup.example.com","priority":%d,"auth":{"type":"oauth2","clientID":"backup"}}]}`, corp, backup)
+		if err := os.WriteFile(filepath.Join(layDir(), "gateway.json"), []byte(gw), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	byName := func() map[string]*GatewayConfig {
+		out := map[string]*GatewayConfig{}
+		for _, gw := range a.GetGateways() {
+			out[gw.Name] = &gw
+		}
+		return out
+	}
+
+	write(0, 1)
+	before := byName()
+	for name, gw := range before {
+		if err := ai.SaveOAuthToken(gw.tokenFile(), &ai.OAuthToken{AccessToken: "at-" + name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if before["Corp"].tokenFile() != gatewayTokenFile() {
+		t.Fatalf("Corp token file = %s, want %s", before["Corp"].tokenFile(), gatewayTokenFile())
+	}
+
+	write(1, 0)
+	if got := a.GetGatewayConfig().Name; got != "Backup" {
+		t.Fatalf("GetGatewayConfig() = %s, want Backup", got)
+	}
+	for name, gw := range byName() {
+		if gw.tokenFile() != before[name].tokenFile() || gw.modelSourceKey() != before[name].modelSourceKey() {
+			t.Errorf("%s moved from %s/%s to %s/%s", name, before[name].tokenFile(), before[name].modelSourceKey(), gw.tokenFile(), gw.modelSourceKey())
+		}
+		tok, err := ai.LoadOAuthToken(gw.tokenFile())
+		if err != nil || tok.AccessToken != "at-"+name {
+			t.Errorf("%s token = %+v, %v", name, tok, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"lay/internal/ai"
+)
+
+// maxDocumentBytes matches the smallest per-file limit among the providers
+// (Anthropic's 32 MB request cap, less room for the rest of the turn).
+const maxDocumentBytes = 24 << 20
+
+// checkDocuments decodes each attachment, rejects ones no provider can read
+// and sets its MIME type from the contents rather than trusting the file
+// name or browser.
+func checkDocuments(docs []ai.Document) ([]ai.Document, error) {
+	out := make([]ai.Document, 0, len(docs))
+	for _, d := range docs {
+		data, err := base64.StdEncoding.DecodeString(d.Data)
+		if err != nil {
+			return nil, fmt.Errorf("attachment %q is not valid base64: %w", d.Name, err)
+		}
+		if len(data) > maxDocumentBytes {
+			return nil, fmt.Errorf("attachment %q is %d MB — the limit is %d MB", d.Name, len(data)>>20, maxDocumentBytes>>20)
+		}
+		mime, err := ai.DocumentMIME(data)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", d.Name, err)
+		}
+		d.MIMEType = mime
+		out = append(out, d)
+	}
+	return out, nil
+}
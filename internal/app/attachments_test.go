@@ -0,0 +1,29 @@
+package app
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"lay/internal/ai"
+)
+
+func TestCheckDocumentsSniffsType(t *testing.T) {
+	enc := base64.StdEncoding.EncodeToString
+	docs, err := checkDocuments([]ai.Document{
+		{Name: "spec.txt", MIMEType: "text/plain", Data: enc([]byte("%PDF-1.4 really a PDF"))},
+		{Name: "notes.md", Data: enc([]byte("# Notes"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docs[0].MIMEType != "application/pdf" || docs[1].MIMEType != "text/plain" {
+		t.Fatalf("types = %q, %q; want them taken from the contents", docs[0].MIMEType, docs[1].MIMEType)
+	}
+
+	if _, err := checkDocuments([]ai.Document{{Name: "a.zip", Data: enc([]byte("PK\x03\x04\x00"))}}); err == nil {
+		t.Fatal("binary attachment should be rejected")
+	}
+	if _, err := checkDocuments([]ai.Document{{Name: "bad", Data: "%%%"}}); err == nil {
+		t.Fatal("invalid base64 should be rejected")
+	}
+}
@@ -0,0 +1,462 @@
+package app
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxTitleChars is how much of the first question names a new conversation.
+const maxTitleChars = 60
+
+// Conversation is a chat saved in ~/.lay/chats/<id>.json. Messages holds
+// every branch in the order the messages were written; Current is the last
+// message of the branch the user is on.
+type Conversation struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Messages  []Message `json:"messages"`
+	Current   string    `json:"current,omitempty"`
+}
+
+// Turn is a message on the active branch of a conversation. Siblings lists
+// the IDs of the versions of it, itself included and oldest first, when it
+// has been edited or regenerated.
+type Turn struct {
+	Message
+	Siblings []string `json:"siblings,omitempty"`
+}
+
+// ConversationSummary describes a saved conversation for the history list.
+type ConversationSummary struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	Messages   int       `json:"messages"`             // on the active branch
+	Preview    string    `json:"preview,omitempty"`    // start of the last message
+	Transcript string    `json:"transcript,omitempty"` // the meeting last asked about
+}
+
+// ErrConversationNotFound is returned for a conversation ID with no file.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// ErrMessageNotFound is returned for a message ID not in the conversation.
+var ErrMessageNotFound = errors.New("message not found")
+
+// conversationIDPattern keeps IDs, which the frontend chooses, safe to use
+// as file names.
+var conversationIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func chatsDir() string {
+	return filepath.Join(layDir(), "chats")
+}
+
+func conversationFile(id string) (string, error) {
+	if !conversationIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid conversation ID %q", id)
+	}
+	return filepath.Join(chatsDir(), id+".json"), nil
+}
+
+// ListConversations returns the saved conversations, most recent first.
+// A non-empty query keeps those whose title or messages contain it,
+// ignoring case.
+func (a *App) ListConversations(query string) ([]ConversationSummary, error) {
+	a.chatsMu.Lock()
+	defer a.chatsMu.Unlock()
+	entries, err := os.ReadDir(chatsDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	var out []ConversationSummary
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		conv, err := readConversation(id)
+		if err != nil {
+			continue // skip files that are not conversations
+		}
+		if query != "" && !conv.contains(query) {
+			continue
+		}
+		out = append(out, conv.summary())
+	}
+	slices.SortFunc(out, func(x, y ConversationSummary) int {
+		return cmp.Or(y.UpdatedAt.Compare(x.UpdatedAt), cmp.Compare(x.ID, y.ID))
+	})
+	return out, nil
+}
+
+// LoadConversation returns the saved conversation with the given ID.
+func (a *App) LoadConversation(id string) (*Conversation, error) {
+	a.chatsMu.Lock()
+	defer a.chatsMu.Unlock()
+	return readConversation(id)
+}
+
+// DeleteConversation removes a saved conversation.
+func (a *App) DeleteConversation(id string) error {
+	path, err := conversationFile(id)
+	if err != nil {
+		return err
+	}
+	a.chatsMu.Lock()
+	defer a.chatsMu.Unlock()
+	if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+		return ErrConversationNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RenameConversation sets the title of a saved conversation.
+func (a *App) RenameConversation(id, title string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return fmt.Errorf("title is required")
+	}
+	return a.updateConversation(id, false, func(c *Conversation) error {
+		c.Title = title
+		return nil
+	})
+}
+
+// LoadThread returns the active branch of a saved conversation.
+func (a *App) LoadThread(id string) ([]Turn, error) {
+	conv, err := a.LoadConversation(id)
+	if err != nil {
+		return nil, err
+	}
+	return conv.thread(), nil
+}
+
+// SwitchBranch makes the branch through the message msgID the active one,
+// following its most recent replies, and returns it.
+func (a *App) SwitchBranch(conversationID, msgID string) ([]Turn, error) {
+	var turns []Turn
+	err := a.updateConversation(conversationID, false, func(c *Conversation) error {
+		if c.message(msgID) == nil {
+			return ErrMessageNotFound
+		}
+		c.Current = c.latest(msgID)
+		turns = c.thread()
+		return nil
+	})
+	return turns, err
+}
+
+// RegenerateMessage answers a question again. msgID is either the answer
+// to replace or the question itself; the new answer becomes a sibling of
+// the earlier ones and the active branch, which is streamed and saved like
+// SendMessage.
+func (a *App) RegenerateMessage(requestID, conversationID, msgID string) (string, error) {
+	return a.answer(requestID, conversationID, false, func(c *Conversation) (string, error) {
+		m := c.message(msgID)
+		if m == nil {
+			return "", ErrMessageNotFound
+		}
+		if m.Role == "assistant" {
+			m = c.message(m.ParentID)
+		}
+		if m == nil || m.Role != "user" {
+			return "", fmt.Errorf("message %s does not answer a question", msgID)
+		}
+		return m.ID, nil
+	})
+}
+
+// EditAndResend asks the question msgID again with its text replaced by
+// content, keeping its attachments. The edited question starts a new branch
+// beside the original, which stays in the conversation.
+func (a *App) EditAndResend(requestID, conversationID, msgID, content string) (string, error) {
+	return a.answer(requestID, conversationID, false, func(c *Conversation) (string, error) {
+		m := c.message(msgID)
+		if m == nil {
+			return "", ErrMessageNotFound
+		}
+		if m.Role != "user" {
+			return "", fmt.Errorf("only questions can be edited")
+		}
+		edited := *m
+		edited.Content = content
+		if err := checkNotEmpty(edited); err != nil {
+			return "", err
+		}
+		return a.ask(c, edited, m.ParentID), nil
+	})
+}
+
+// ExportConversation renders a saved conversation as "markdown" or "json".
+func (a *App) ExportConversation(id, format string) (string, error) {
+	conv, err := a.LoadConversation(id)
+	if err != nil {
+		return "", err
+	}
+	switch format {
+	case "markdown", "md":
+		return conv.markdown(), nil
+	case "json":
+		data, err := json.MarshalIndent(conv, "", "  ")
+		return string(data), err
+	}
+	return "", fmt.Errorf("unknown export format %q — use markdown or json", format)
+}
+
+// updateConversation applies change to a saved conversation and writes it
+// back. With create, a conversation that doesn't exist yet starts empty.
+// A change that fails leaves the file as it was.
+func (a *App) updateConversation(id string, create bool, change func(*Conversation) error) error {
+	a.chatsMu.Lock()
+	defer a.chatsMu.Unlock()
+	conv, err := readConversation(id)
+	if create && errors.Is(err, ErrConversationNotFound) {
+		now := time.Now()
+		conv, err = &Conversation{ID: id, CreatedAt: now, UpdatedAt: now}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := change(conv); err != nil {
+		return err
+	}
+	return writeConversation(conv)
+}
+
+func readConversation(id string) (*Conversation, error) {
+	path, err := conversationFile(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var conv Conversation
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return nil, fmt.Errorf("invalid conversation %s: %w", id, err)
+	}
+	conv.ID = id
+	conv.chain()
+	return &conv, nil
+}
+
+func writeConversation(conv *Conversation) error {
+	path, err := conversationFile(conv.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(chatsDir(), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(conv, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write a copy and rename it over the old file, so a crash mid-write
+	// cannot lose the conversation.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// add appends m, makes it the end of the active branch and names a new
+// conversation after its first question. It returns the ID of m.
+func (c *Conversation) add(m Message) string {
+	if m.ID == "" {
+		m.ID = newMessageID()
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	c.Messages = append(c.Messages, m)
+	c.Current = m.ID
+	c.UpdatedAt = m.CreatedAt
+	if c.Title == "" && m.Role == "user" {
+		c.Title = conversationTitle(m)
+	}
+	return m.ID
+}
+
+func newMessageID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// chain turns messages saved before conversations could branch, which have
+// no IDs, into a single branch.
+func (c *Conversation) chain() {
+	if !slices.ContainsFunc(c.Messages, func(m Message) bool { return m.ID == "" }) {
+		return
+	}
+	parent := ""
+	for i := range c.Messages {
+		m := &c.Messages[i]
+		if m.ID == "" {
+			m.ID, m.ParentID = newMessageID(), parent
+		}
+		parent = m.ID
+	}
+	if c.message(c.Current) == nil {
+		c.Current = parent
+	}
+}
+
+func (c *Conversation) message(id string) *Message {
+	if id == "" {
+		return nil
+	}
+	for i := range c.Messages {
+		if c.Messages[i].ID == id {
+			return &c.Messages[i]
+		}
+	}
+	return nil
+}
+
+// path returns the messages from the first question down to id.
+func (c *Conversation) path(id string) []Message {
+	var out []Message
+	for m := c.message(id); m != nil && len(out) < len(c.Messages); m = c.message(m.ParentID) {
+		out = append(out, *m)
+	}
+	slices.Reverse(out)
+	return out
+}
+
+// active returns the messages of the active branch.
+func (c *Conversation) active() []Message {
+	return c.path(c.Current)
+}
+
+// replies returns the IDs of the messages that follow parentID, oldest
+// first.
+func (c *Conversation) replies(parentID string) []string {
+	var ids []string
+	for _, m := range c.Messages {
+		if m.ParentID == parentID {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
+}
+
+// latest follows the most recent reply from id down to the end of its
+// branch.
+func (c *Conversation) latest(id string) string {
+	for {
+		ids := c.replies(id)
+		if len(ids) == 0 {
+			return id
+		}
+		id = ids[len(ids)-1]
+	}
+}
+
+func (c *Conversation) thread() []Turn {
+	path := c.active()
+	turns := make([]Turn, len(path))
+	for i, m := range path {
+		turns[i].Message = m
+		if ids := c.replies(m.ParentID); len(ids) > 1 {
+			turns[i].Siblings = ids
+		}
+	}
+	return turns
+}
+
+func conversationTitle(m Message) string {
+	title := strings.Join(strings.Fields(m.Content), " ")
+	switch {
+	case title == "" && len(m.Documents) > 0:
+		title = m.Documents[0].Name
+	case title == "" && len(m.Images) > 0:
+		title = "Image"
+	case title == "":
+		title = "New chat"
+	}
+	return clip(title, maxTitleChars)
+}
+
+func (c *Conversation) contains(query string) bool {
+	if strings.Contains(strings.ToLower(c.Title), query) {
+		return true
+	}
+	return slices.ContainsFunc(c.Messages, func(m Message) bool {
+		return strings.Contains(strings.ToLower(m.Content), query)
+	})
+}
+
+func (c *Conversation) summary() ConversationSummary {
+	path := c.active()
+	s := ConversationSummary{ID: c.ID, Title: c.Title, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, Messages: len(path)}
+	if n := len(path); n > 0 {
+		s.Preview = clip(strings.Join(strings.Fields(path[n-1].Content), " "), 120)
+	}
+	for _, m := range slices.Backward(path) {
+		if m.Transcript != "" {
+			s.Transcript = m.Transcript
+			break
+		}
+	}
+	return s
+}
+
+// markdown renders the active branch of c for reading: each message under
+// a heading with its time, attachments listed by name.
+func (c *Conversation) markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n*%s*\n", c.Title, c.CreatedAt.Format("2006-01-02 15:04"))
+	for _, m := range c.active() {
+		who := "You"
+		if m.Role == "assistant" {
+			who = "Assistant"
+			if m.Model != "" {
+				who += " (" + m.Model + ")"
+			}
+		}
+		fmt.Fprintf(&sb, "\n## %s — %s\n\n", who, m.CreatedAt.Format("15:04"))
+		if m.Transcript != "" {
+			fmt.Fprintf(&sb, "*About the meeting %s*\n\n", m.Transcript)
+		}
+		var attached []string
+		for _, d := range m.Documents {
+			attached = append(attached, d.Name)
+		}
+		if n := len(m.Images); n == 1 {
+			attached = append(attached, "1 image")
+		} else if n > 1 {
+			attached = append(attached, fmt.Sprintf("%d images", n))
+		}
+		if len(attached) > 0 {
+			fmt.Fprintf(&sb, "*Attached: %s*\n\n", strings.Join(attached, ", "))
+		}
+		sb.WriteString(strings.TrimSpace(m.Content))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,251 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lay/internal/ai"
+)
+
+// newChatTestApp returns an app whose chat model is served by a local
+// server that numbers its answers and records the messages of each request.
+func newChatTestApp(t *testing.T) (*App, *[][]map[string]any) {
+	t.Helper()
+	a := newUsageTestApp(t)
+	var sent [][]map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Messages []map[string]any `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sent = append(sent, req.Messages)
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"answer %d"}}]}`, len(sent))
+	}))
+	t.Cleanup(srv.Close)
+	cfg := Config{
+		Model:    "local/llama3.2",
+		LocalURL: srv.URL,
+		Models:   []ai.Model{{ID: "llama3.2", Provider: "local", Capabilities: ai.Capabilities{Vision: true}}},
+	}
+	if err := a.writeConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return a, &sent
+}
+
+func TestSendMessageSavesConversation(t *testing.T) {
+	a, sent := newChatTestApp(t)
+	a.currentTranscript, a.currentSession = "[00:00] Alice: hello", "2026-10-17-09-00-00"
+
+	if _, err := a.SendMessage("req-1", "chat-1", `{"content":"What did   Alice say?","images":["aW1n"]}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.SendMessage("req-2", "chat-1", `{"content":"And then?"}`); err != nil {
+		t.Fatal(err)
+	}
+	// The second request carries the saved history after the system prompt,
+	// not just the question.
+	if len(*sent) != 2 || len((*sent)[1]) != 4 || (*sent)[1][2]["content"] != "answer 1" {
+		t.Fatalf("sent = %v, want the whole conversation on the second request", *sent)
+	}
+
+	conv, err := a.LoadConversation("chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.Title != "What did Alice say?" || len(conv.Messages) != 4 {
+		t.Fatalf("conversation = %+v", conv)
+	}
+	q, ans := conv.Messages[0], conv.Messages[1]
+	if q.Role != "user" || len(q.Images) != 1 || q.Transcript != "2026-10-17-09-00-00" || q.CreatedAt.IsZero() {
+		t.Fatalf("question = %+v", q)
+	}
+	if ans.Role != "assistant" || ans.Content != "answer 1" || ans.Model != "local/llama3.2" || ans.FellBack || ans.CreatedAt.IsZero() {
+		t.Fatalf("answer = %+v", ans)
+	}
+	if !conv.UpdatedAt.Equal(conv.Messages[3].CreatedAt) {
+		t.Fatalf("UpdatedAt = %v, want the time of the last message", conv.UpdatedAt)
+	}
+}
+
+func TestConversationHistory(t *testing.T) {
+	a, _ := newChatTestApp(t)
+	for i, question := range []string{"Budget for Q3", "Hiring plan"} {
+		id := fmt.Sprintf("chat-%d", i+1)
+		if _, err := a.SendMessage("req-"+id, id, fmt.Sprintf(`{"content":%q}`, question)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := a.ListConversations("")
+	if err != nil || len(list) != 2 || list[0].ID != "chat-2" || list[0].Messages != 2 || list[0].Preview != "answer 2" {
+		t.Fatalf("ListConversations() = %+v, %v", list, err)
+	}
+	if list, _ := a.ListConversations("budget"); len(list) != 1 || list[0].ID != "chat-1" {
+		t.Fatalf("ListConversations(budget) = %+v", list)
+	}
+	if list, _ := a.ListConversations("ANSWER 2"); len(list) != 1 || list[0].ID != "chat-2" {
+		t.Fatalf("ListConversations(ANSWER 2) = %+v, want a match on message text", list)
+	}
+
+	if err := a.RenameConversation("chat-1", "  Q3 budget  "); err != nil {
+		t.Fatal(err)
+	}
+	md, err := a.ExportConversation("chat-1", "markdown")
+	if err != nil || !strings.HasPrefix(md, "# Q3 budget\n") || !strings.Contains(md, "## Assistant (local/llama3.2) — ") || !strings.Contains(md, "Budget for Q3") {
+		t.Fatalf("ExportConversation(markdown) = %q, %v", md, err)
+	}
+	data, err := a.ExportConversation("chat-1", "json")
+	var conv Conversation
+	if err != nil || json.Unmarshal([]byte(data), &conv) != nil || conv.Title != "Q3 budget" {
+		t.Fatalf("ExportConversation(json) = %q, %v", data, err)
+	}
+	if _, err := a.ExportConversation("chat-1", "pdf"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+
+	if err := a.DeleteConversation("chat-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.LoadConversation("chat-1"); !errors.Is(err, ErrConversationNotFound) {
+		t.Fatalf("LoadConversation() after delete error = %v", err)
+	}
+	if err := a.RenameConversation("chat-1", "x"); !errors.Is(err, ErrConversationNotFound) {
+		t.Fatalf("RenameConversation() of a deleted conversation error = %v", err)
+	}
+	if _, err := a.LoadConversation("../config"); err == nil {
+		t.Fatal("expected an error for an ID that is not a file name")
+	}
+}
+
+func TestConversationBranches(t *testing.T) {
+	a, sent := newChatTestApp(t)
+	for i, question := range []string{"First", "Second"} {
+		if _, err := a.SendMessage(fmt.Sprintf("req-%d", i), "chat-1", fmt.Sprintf(`{"content":%q,"images":["aW1n"]}`, question)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	thread, err := a.LoadThread("chat-1")
+	if err != nil || len(thread) != 4 {
+		t.Fatalf("LoadThread() = %+v, %v", thread, err)
+	}
+	first, second, answer2 := thread[0], thread[2], thread[3]
+
+	// Regenerating the last answer sends the same history again and adds a
+	// sibling answer.
+	if _, err := a.RegenerateMessage("req-3", "chat-1", answer2.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len((*sent)[2]) != len((*sent)[1]) {
+		t.Fatalf("regenerate sent %v, want the history up to the question", (*sent)[2])
+	}
+	thread, _ = a.LoadThread("chat-1")
+	if len(thread) != 4 || thread[3].Content != "answer 3" || len(thread[3].Siblings) != 2 || thread[3].Siblings[0] != answer2.ID {
+		t.Fatalf("thread after regenerate = %+v", thread)
+	}
+
+	// Editing the second question branches off after the first answer and
+	// keeps the attachment.
+	if _, err := a.EditAndResend("req-4", "chat-1", second.ID, "Second, edited"); err != nil {
+		t.Fatal(err)
+	}
+	last := (*sent)[3]
+	if len(last) != 4 || !strings.Contains(fmt.Sprint(last[3]["content"]), "Second, edited") || strings.Contains(fmt.Sprint(last), "answer 2") {
+		t.Fatalf("edit sent %v, want the first exchange and the edited question", last)
+	}
+	thread, _ = a.LoadThread("chat-1")
+	if len(thread) != 4 || thread[2].Content != "Second, edited" || len(thread[2].Images) != 1 || len(thread[2].Siblings) != 2 || thread[3].Siblings != nil {
+		t.Fatalf("thread after edit = %+v", thread)
+	}
+
+	// Switching back to the original question follows its latest answer.
+	thread, err = a.SwitchBranch("chat-1", second.ID)
+	if err != nil || len(thread) != 4 || thread[2].ID != second.ID || thread[3].Content != "answer 3" {
+		t.Fatalf("SwitchBranch() = %+v, %v", thread, err)
+	}
+	list, _ := a.ListConversations("")
+	if len(list) != 1 || list[0].Messages != 4 || list[0].Preview != "answer 3" {
+		t.Fatalf("ListConversations() = %+v, want the active branch summarized", list)
+	}
+	conv, _ := a.LoadConversation("chat-1")
+	if len(conv.Messages) != 7 {
+		t.Fatalf("conversation has %d messages, want every branch kept", len(conv.Messages))
+	}
+
+	if _, err := a.EditAndResend("req-5", "chat-1", answer2.ID, "x"); err == nil {
+		t.Fatal("expected an error editing an answer")
+	}
+	if _, err := a.RegenerateMessage("req-6", "chat-1", "missing"); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("RegenerateMessage(missing) error = %v", err)
+	}
+	if thread, _ := a.LoadThread("chat-1"); thread[0].ID != first.ID || thread[3].Content != "answer 3" {
+		t.Fatalf("failed edits changed the thread: %+v", thread)
+	}
+}
+
+func TestConversationWithoutIDsIsChained(t *testing.T) {
+	newUsageTestApp(t)
+	if err := os.MkdirAll(chatsDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old := `{"title":"Old","messages":[{"role":"user","content":"q"},{"role":"assistant","content":"a"}]}`
+	if err := os.WriteFile(filepath.Join(chatsDir(), "old.json"), []byte(old), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	conv, err := readConversation("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, ans := conv.Messages[0], conv.Messages[1]
+	if q.ID == "" || q.ParentID != "" || ans.ParentID != q.ID || conv.Current != ans.ID {
+		t.Fatalf("conversation = %+v, want one branch", conv)
+	}
+}
+
+func TestFailedQuestionIsReplacedByTheNext(t *testing.T) {
+	a, sent := newChatTestApp(t)
+	if _, err := a.SendMessage("req-1", "chat-1", `{"content":"First"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.SendMessage("req-2", "chat-1", `{"content":"  "}`); err == nil {
+		t.Fatal("expected an error for an empty message")
+	}
+
+	// A question whose request fails stays unanswered.
+	cfg := a.GetConfig()
+	model := cfg.Model
+	cfg.Model = "nope/missing"
+	if err := a.writeConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.SendMessage("req-3", "chat-1", `{"content":"Lost"}`); err == nil {
+		t.Fatal("expected an error for an unknown model")
+	}
+	cfg.Model = model
+	if err := a.writeConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.SendMessage("req-4", "chat-1", `{"content":"Second"}`); err != nil {
+		t.Fatal(err)
+	}
+	last := (*sent)[len(*sent)-1]
+	if len(last) != 4 || last[2]["content"] != "answer 1" || last[3]["content"] != "Second" {
+		t.Fatalf("sent %v, want the first exchange and the new question only", last)
+	}
+	thread, _ := a.LoadThread("chat-1")
+	if len(thread) != 4 || thread[2].Content != "Second" || len(thread[2].Siblings) != 2 {
+		t.Fatalf("thread = %+v, want the failed question kept as an earlier version", thread)
+	}
+}
@@ -0,0 +1,314 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"strings"
+
+	"lay/internal/ai"
+)
+
+const (
+	keepRecentMessages    = 6    // the latest chat messages are always sent verbatim
+	chatSummaryGroup      = 8    // older messages are summarized this many at a time
+	maxSummaryChunkTokens = 6000 // largest piece of chat or transcript sent to be summarized
+	maxCachedSummaries    = 256
+)
+
+const chatSummaryPrompt = "Summarize this earlier part of a conversation between a user and a meeting assistant. Keep every decision, fact, number, name and open question the rest of the conversation may rely on. Reply with a compact bullet list and nothing else."
+
+const transcriptSummaryPrompt = "Summarize this section of a meeting transcript. Keep who said what, decisions, action items with their owners, numbers and open questions, and the timestamp where each topic starts. Reply with a compact bullet list and nothing else."
+
+// chatContext is what the system prompt is built from: the meeting
+// transcript and, once a conversation outgrows the model's context, the
+// summaries that stand in for its older parts.
+type chatContext struct {
+	transcript        string // sent verbatim
+	live              bool   // transcript is still being recorded
+	transcriptSummary string // summary of the transcript before transcript
+	chatSummary       string // summary of the chat messages no longer sent
+}
+
+func (a *App) chatContext() chatContext {
+	if a.currentTranscript != "" {
+		return chatContext{transcript: a.currentTranscript}
+	}
+	a.liveMu.Lock()
+	live := strings.Join(a.liveSegments, "\n")
+	a.liveMu.Unlock()
+	return chatContext{transcript: live, live: live != ""}
+}
+
+// liveCacheLines is how often the cached prefix of a live transcript moves
+// on: lines are cached in runs of this many, so questions asked while the
+// meeting goes on still reuse most of it.
+const liveCacheLines = 50
+
+// blocks builds the system prompt from its most to its least stable part:
+// the instructions and transcript, marked for caching, then the newest live
+// lines and the chat summary, which change from turn to turn.
+func (c chatContext) blocks() []ai.SystemBlock {
+	var sb strings.Builder
+	sb.WriteString("You are a helpful meeting assistant. Be concise and practical. Format responses in markdown when it aids clarity.")
+	var blocks []ai.SystemBlock
+
+	if c.transcript != "" || c.transcriptSummary != "" {
+		if c.live {
+			sb.WriteString("\n\nThe meeting is currently being recorded. Below is the live transcript so far — it may be incomplete.")
+		} else {
+			sb.WriteString("\n\nThe user has a meeting transcript from this session. Use it to answer questions about the meeting.")
+		}
+		if c.transcriptSummary != "" {
+			sb.WriteString(" Its beginning was summarized to fit your context window; the rest follows verbatim.\n\n<transcript_summary>\n" + c.transcriptSummary + "\n</transcript_summary>")
+		}
+		stable, recent := c.transcript, ""
+		if c.live {
+			lines := strings.Split(c.transcript, "\n")
+			if cut := len(lines) / liveCacheLines * liveCacheLines; cut < len(lines) {
+				stable = strings.Join(lines[:cut], "\n")
+				if cut > 0 {
+					stable += "\n"
+				}
+				recent = strings.Join(lines[cut:], "\n")
+			}
+		}
+		sb.WriteString("\n\n<transcript>\n" + stable)
+		blocks = append(blocks,
+			ai.SystemBlock{Text: sb.String(), Cache: true},
+			ai.SystemBlock{Text: recent + "\n</transcript>"})
+	} else {
+		blocks = append(blocks, ai.SystemBlock{Text: sb.String()})
+	}
+
+	if c.chatSummary != "" {
+		blocks = append(blocks, ai.SystemBlock{Text: "\n\nEarlier messages of this chat were summarized to fit your context window:\n\n<earlier_chat_summary>\n" + c.chatSummary + "\n</earlier_chat_summary>"})
+	}
+	return blocks
+}
+
+// system returns the system prompt as one string.
+func (c chatContext) system() string {
+	var sb strings.Builder
+	for _, b := range c.blocks() {
+		sb.WriteString(b.Text)
+	}
+	return sb.String()
+}
+
+// compactContext makes the request fit the model's context window. When
+// the estimate overflows, older chat messages are summarized first, keeping
+// the latest ones verbatim, then the transcript from its start, keeping the
+// most recent section verbatim. notice describes what was summarized and is
+// empty when nothing had to be. messages[question] is the user's message
+// being answered; it and the tool rounds after it are never summarized.
+//
+// Pieces are cut at fixed points from the start of the chat and transcript,
+// so as a conversation grows the earlier summaries come from the cache.
+func (a *App) compactContext(ctx context.Context, cfg ai.Config, cc chatContext, messages []ai.Message, question int) (_ chatContext, _ []ai.Message, notice string, _ error) {
+	budget, err := a.aiClient.InputBudget(cfg)
+	if err != nil {
+		// Leave unknown models for Complete to report.
+		return cc, messages, "", nil
+	}
+	size := func() int { return ai.EstimateTokens(cc.system()) + ai.EstimateMessages(messages) }
+	if size() <= budget {
+		return cc, messages, "", nil
+	}
+	chunkTokens := min(budget/2, maxSummaryChunkTokens)
+	cfg.Tools, cfg.OnThinking = nil, nil
+
+	var done []string
+	n := min(question, max(0, (len(messages)-keepRecentMessages)/chatSummaryGroup*chatSummaryGroup))
+	// The messages sent on must start with the user's turn. The question is
+	// one, so a tool call is never parted from its results.
+	for n > 0 && n < question && messages[n].Role != "user" {
+		n++
+	}
+	if n > 0 {
+		var parts []string
+		if cc.chatSummary != "" {
+			parts = append(parts, cc.chatSummary) // from an earlier round of this answer
+		}
+		for i := 0; i < n; i += chatSummaryGroup {
+			group := messages[i:min(i+chatSummaryGroup, n)]
+			s, err := a.summarize(ctx, cfg, chatSummaryPrompt, clipTokens(chatText(group), chunkTokens))
+			if err != nil {
+				return cc, messages, "", err
+			}
+			parts = append(parts, s)
+		}
+		cc.chatSummary = strings.Join(parts, "\n\n")
+		messages = messages[n:]
+		done = append(done, fmt.Sprintf("%d earlier messages", n))
+	}
+
+	if size() > budget && cc.transcript != "" {
+		chunks := splitTranscript(cc.transcript, chunkTokens)
+		var parts []string
+		if cc.transcriptSummary != "" {
+			parts = append(parts, cc.transcriptSummary)
+		}
+		summarized := len(parts)
+		for k := 0; k < len(chunks)-1 && size() > budget; k++ {
+			s, err := a.summarize(ctx, cfg, transcriptSummaryPrompt, chunks[k])
+			if err != nil {
+				return cc, messages, "", err
+			}
+			parts = append(parts, s)
+			cc.transcriptSummary = strings.Join(parts, "\n\n")
+			cc.transcript = strings.Join(chunks[k+1:], "\n")
+		}
+		if len(parts) > summarized {
+			what := "the start of the transcript"
+			if ts, ok := lineTimestamp(cc.transcript); ok {
+				what = "the transcript before " + formatTS(ts)[:8]
+			}
+			done = append(done, what)
+		}
+	}
+
+	if size() > budget {
+		return cc, messages, "", fmt.Errorf("the conversation is too long for %s even after summarizing its earlier parts — start a new chat or choose a model with a larger context window", cfg.Model)
+	}
+	if len(done) == 0 {
+		return cc, messages, "", nil
+	}
+	return cc, messages, "Summarized " + strings.Join(done, " and ") + " to fit the model's context window.", nil
+}
+
+// toolResultOmitted replaces a tool result left out by fitToolRounds.
+const toolResultOmitted = "[Result left out to fit the context window. Call the tool again if you still need it.]"
+
+// fitToolRounds keeps the request within the model's context window as tool
+// results pile up over the rounds of one answer. Results from before the
+// latest round are left out first, oldest first; if that is not enough,
+// the chat and transcript are compacted further. notice describes what
+// was done and is empty when the request already fit.
+func (a *App) fitToolRounds(ctx context.Context, cfg ai.Config, cc chatContext, messages []ai.Message) (_ chatContext, _ []ai.Message, notice string, _ error) {
+	budget, err := a.aiClient.InputBudget(cfg)
+	if err != nil {
+		return cc, messages, "", nil
+	}
+	size := func() int { return ai.EstimateTokens(cc.system()) + ai.EstimateMessages(messages) }
+	if size() <= budget {
+		return cc, messages, "", nil
+	}
+
+	// Tool messages are only ever sent within the answer being written, so
+	// all of them come from its rounds; the latest round's stay.
+	latest := len(messages)
+	for latest > 0 && (messages[latest-1].Role != "assistant" || len(messages[latest-1].ToolCalls) == 0) {
+		latest--
+	}
+	messages = slices.Clone(messages)
+	left := 0
+	for i := 0; i < latest-1 && size() > budget; i++ {
+		if messages[i].Role == "tool" && messages[i].Content != toolResultOmitted {
+			messages[i].Content = toolResultOmitted
+			left++
+		}
+	}
+	var done []string
+	switch {
+	case left == 1:
+		done = append(done, "Left out an earlier tool result to fit the model's context window.")
+	case left > 1:
+		done = append(done, fmt.Sprintf("Left out %d earlier tool results to fit the model's context window.", left))
+	}
+
+	if size() > budget {
+		// The tool rounds of this answer follow the question directly.
+		question := slices.IndexFunc(messages, func(m ai.Message) bool { return m.Role == "tool" || len(m.ToolCalls) > 0 }) - 1
+		if question < 0 {
+			question = len(messages) - 1
+		}
+		cc, messages, notice, err = a.compactContext(ctx, cfg, cc, messages, question)
+		if err != nil {
+			return cc, messages, "", err
+		}
+		if notice != "" {
+			done = append(done, notice)
+		}
+	}
+	return cc, messages, strings.Join(done, " "), nil
+}
+
+// summarize asks the chat model to summarize text, reusing an earlier
+// summary of the same text by the same model.
+func (a *App) summarize(ctx context.Context, cfg ai.Config, prompt, text string) (string, error) {
+	key := sha256.Sum256([]byte(cfg.Model + "\x00" + prompt + "\x00" + text))
+	a.summariesMu.Lock()
+	s, ok := a.summaries[key]
+	a.summariesMu.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	resp, err := a.aiClient.Complete(ctx, cfg, prompt, []ai.Message{{Role: "user", Content: text}}, nil)
+	if err != nil {
+		return "", fmt.Errorf("summarizing the earlier conversation failed: %w", err)
+	}
+	_ = a.recordUsage(resp)
+
+	a.summariesMu.Lock()
+	if a.summaries == nil || len(a.summaries) >= maxCachedSummaries {
+		a.summaries = make(map[[sha256.Size]byte]string)
+	}
+	a.summaries[key] = resp.Text
+	a.summariesMu.Unlock()
+	return resp.Text, nil
+}
+
+// chatText renders messages as a plain-text exchange for summarizing.
+func chatText(messages []ai.Message) string {
+	var sb strings.Builder
+	for _, m := range messages {
+		role := "User"
+		switch m.Role {
+		case "assistant":
+			role = "Assistant"
+		case "tool":
+			role = "Tool result"
+		}
+		sb.WriteString(role + ": " + m.Content)
+		if len(m.Images) > 0 {
+			fmt.Fprintf(&sb, " [%d image(s) attached]", len(m.Images))
+		}
+		for _, doc := range m.Documents {
+			fmt.Fprintf(&sb, " [attached: %s]", doc.Name)
+		}
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
+
+// splitTranscript cuts text into runs of whole lines of at most maxTokens
+// each, from the start. A line longer than that is a chunk of its own.
+func splitTranscript(text string, maxTokens int) []string {
+	var chunks []string
+	var cur []string
+	tokens := 0
+	for _, line := range strings.Split(text, "\n") {
+		t := ai.EstimateTokens(line) + 1
+		if len(cur) > 0 && tokens+t > maxTokens {
+			chunks = append(chunks, strings.Join(cur, "\n"))
+			cur, tokens = nil, 0
+		}
+		cur = append(cur, line)
+		tokens += t
+	}
+	if len(cur) > 0 {
+		chunks = append(chunks, strings.Join(cur, "\n"))
+	}
+	return chunks
+}
+
+// clipTokens shortens s to roughly maxTokens.
+func clipTokens(s string, maxTokens int) string {
+	if ai.EstimateTokens(s) <= maxTokens {
+		return s
+	}
+	return clip(s, maxTokens*4)
+}
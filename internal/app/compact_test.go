@@ -0,0 +1,201 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"lay/internal/ai"
+)
+
+func TestCompactContextSummarizesOldestFirst(t *testing.T) {
+	a := newUsageTestApp(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"- summary"}}]}`)
+	}))
+	defer srv.Close()
+	cfg := ai.Config{
+		Model:    "local/tiny",
+		LocalURL: srv.URL,
+		Models:   []ai.Model{{ID: "tiny", Provider: "local", ContextWindow: 4000}},
+	}
+
+	var messages []ai.Message
+	for i := 0; i < 20; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages = append(messages, ai.Message{Role: role, Content: fmt.Sprintf("message %d ", i) + strings.Repeat("word ", 38)})
+	}
+	var lines []string
+	for i := 0; i < 120; i++ {
+		lines = append(lines, fmt.Sprintf("[00:%02d:%02d.000] [Them] point number %d about the plan", i/60, i%60, i))
+	}
+	cc := chatContext{transcript: strings.Join(lines, "\n")}
+
+	got, kept, notice, err := a.compactContext(context.Background(), cfg, cc, messages, len(messages)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 12 || kept[0].Role != "user" || kept[11].Content != messages[19].Content {
+		t.Fatalf("kept %d messages, want the latest 12 starting with the user's", len(kept))
+	}
+	if got.chatSummary != "- summary" || !strings.Contains(got.transcriptSummary, "- summary") {
+		t.Fatalf("summaries = %q / %q", got.chatSummary, got.transcriptSummary)
+	}
+	if !strings.HasSuffix(got.transcript, lines[119]) || strings.Contains(got.transcript, lines[0]) {
+		t.Fatal("the most recent transcript section should stay verbatim and the oldest go")
+	}
+	if !strings.Contains(notice, "8 earlier messages") || !strings.Contains(notice, "transcript before 00:") {
+		t.Fatalf("notice = %q", notice)
+	}
+	if budget, _ := a.aiClient.InputBudget(cfg); ai.EstimateTokens(got.system())+ai.EstimateMessages(kept) > budget {
+		t.Fatal("compacted request still over budget")
+	}
+
+	n := calls.Load()
+	if _, _, _, err := a.compactContext(context.Background(), cfg, cc, messages, len(messages)-1); err != nil || calls.Load() != n {
+		t.Fatalf("second request made %d more summary calls, want them cached", calls.Load()-n)
+	}
+}
+
+func TestCompactContextLeavesFittingRequests(t *testing.T) {
+	a := newUsageTestApp(t)
+	cc := chatContext{transcript: "[00:00:01.000] [You] hello"}
+	msgs := []ai.Message{{Role: "user", Content: "hi"}}
+	got, kept, notice, err := a.compactContext(context.Background(), ai.Config{Model: "claude-sonnet-4-6"}, cc, msgs, len(msgs)-1)
+	if err != nil || notice != "" || got != cc || len(kept) != 1 {
+		t.Fatalf("got %+v, %d messages, %q, %v; want it unchanged", got, len(kept), notice, err)
+	}
+}
+
+func TestFitToolRoundsLeavesOutOldResults(t *testing.T) {
+	a := newUsageTestApp(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"- summary"}}]}`)
+	}))
+	defer srv.Close()
+	cfg := ai.Config{
+		Model:    "local/tiny",
+		LocalURL: srv.URL,
+		Models:   []ai.Model{{ID: "tiny", Provider: "local", ContextWindow: 4000}},
+	}
+	budget, err := a.aiClient.InputBudget(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(id string) []ai.ToolCall { return []ai.ToolCall{{ID: id, Name: "read_notes", Arguments: "{}"}} }
+	messages := []ai.Message{
+		{Role: "user", Content: "What do my notes say?"},
+		{Role: "assistant", ToolCalls: call("c1")},
+		{Role: "tool", ToolCallID: "c1", Content: strings.Repeat("note ", budget*2/3)},
+		{Role: "assistant", ToolCalls: call("c2")},
+		{Role: "tool", ToolCallID: "c2", Content: strings.Repeat("more ", budget/2)},
+	}
+	cc := chatContext{}
+
+	_, kept, notice, err := a.fitToolRounds(context.Background(), cfg, cc, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept[2].Content != toolResultOmitted || kept[4].Content != messages[4].Content || len(kept) != len(messages) {
+		t.Fatalf("kept = %+v, want only the earlier result left out", kept)
+	}
+	if messages[2].Content == toolResultOmitted {
+		t.Fatal("fitToolRounds changed the caller's messages")
+	}
+	if notice != "Left out an earlier tool result to fit the model's context window." || calls.Load() != 0 {
+		t.Fatalf("notice = %q after %d summary calls", notice, calls.Load())
+	}
+	if ai.EstimateTokens(cc.system())+ai.EstimateMessages(kept) > budget {
+		t.Fatal("request still over budget")
+	}
+
+	// A request that fits is left alone.
+	if _, kept, notice, err := a.fitToolRounds(context.Background(), cfg, cc, messages[:3]); err != nil || notice != "" || kept[2].Content != messages[2].Content {
+		t.Fatalf("fitToolRounds() on a fitting request = %q, %v", notice, err)
+	}
+}
+
+func TestFitToolRoundsKeepsTheQuestion(t *testing.T) {
+	a := newUsageTestApp(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"- summary"}}]}`)
+	}))
+	defer srv.Close()
+	cfg := ai.Config{
+		Model:    "local/tiny",
+		LocalURL: srv.URL,
+		Models:   []ai.Model{{ID: "tiny", Provider: "local", ContextWindow: 4000}},
+	}
+	budget, err := a.aiClient.InputBudget(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A short history, then enough large tool rounds that the split point
+	// for summarizing falls among them.
+	messages := []ai.Message{
+		{Role: "user", Content: strings.Repeat("earlier ", budget/3)},
+		{Role: "assistant", Content: strings.Repeat("reply ", budget/3)},
+		{Role: "user", Content: "What do my notes say?"},
+	}
+	for i := range 6 {
+		id := fmt.Sprintf("c%d", i)
+		messages = append(messages,
+			ai.Message{Role: "assistant", ToolCalls: []ai.ToolCall{{ID: id, Name: "read_notes", Arguments: "{}"}}},
+			ai.Message{Role: "tool", ToolCallID: id, Content: strings.Repeat("note ", budget/3)})
+	}
+
+	got, kept, notice, err := a.fitToolRounds(context.Background(), cfg, chatContext{}, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != len(messages)-2 || kept[0].Content != messages[2].Content {
+		t.Fatalf("kept %d messages starting with %q, want everything from the question on", len(kept), kept[0].Role)
+	}
+	for i, m := range kept[1:] {
+		if m.Role != messages[3+i].Role || m.ToolCallID != messages[3+i].ToolCallID || len(m.ToolCalls) != len(messages[3+i].ToolCalls) {
+			t.Fatalf("message %d = %+v, want the tool rounds intact", i+1, m)
+		}
+	}
+	if got.chatSummary != "- summary" || !strings.Contains(notice, "2 earlier messages") {
+		t.Fatalf("summary = %q, notice = %q", got.chatSummary, notice)
+	}
+	if ai.EstimateTokens(got.system())+ai.EstimateMessages(kept) > budget {
+		t.Fatal("request still over budget")
+	}
+}
+
+func TestChatContextBlocksCacheStableTranscript(t *testing.T) {
+	var lines []string
+	for i := 0; i < 120; i++ {
+		lines = append(lines, fmt.Sprintf("[00:%02d:%02d.000] [You] line %d", i/60, i%60, i))
+	}
+	transcript := strings.Join(lines, "\n")
+	cc := chatContext{transcript: transcript, live: true, chatSummary: "- earlier"}
+
+	blocks := cc.blocks()
+	if len(blocks) != 3 || !blocks[0].Cache || blocks[1].Cache || blocks[2].Cache {
+		t.Fatalf("blocks = %+v, want a cached prefix then two uncached blocks", blocks)
+	}
+	if !strings.HasSuffix(blocks[0].Text, lines[99]+"\n") || !strings.HasPrefix(blocks[1].Text, lines[100]) {
+		t.Fatal("live transcript should be cached up to a multiple of liveCacheLines")
+	}
+	if !strings.Contains(cc.system(), "<transcript>\n"+transcript+"\n</transcript>") {
+		t.Fatal("blocks should join to the whole transcript")
+	}
+
+	cc.transcript += "\n[00:02:00.000] [You] one more"
+	if cc.blocks()[0] != blocks[0] {
+		t.Fatal("a new live line changed the cached prefix")
+	}
+}
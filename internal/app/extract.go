@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"lay/internal/ai"
+)
+
+// nullableString is a string property that is null when the transcript
+// doesn't say.
+func nullableString(description string) map[string]any {
+	return map[string]any{"type": []string{"string", "null"}, "description": description}
+}
+
+// listSchema is an object with one array property, items, of objects with
+// the given properties, all required, as strict structured output wants.
+func listSchema(name, description string, properties map[string]any) ai.Schema {
+	required := slices.Sorted(maps.Keys(properties))
+	return ai.Schema{
+		Name:        name,
+		Description: description,
+		Strict:      true,
+		JSON: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"items": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type":                 "object",
+						"properties":           properties,
+						"required":             required,
+						"additionalProperties": false,
+					},
+				},
+			},
+			"required":             []string{"items"},
+			"additionalProperties": false,
+		},
+	}
+}
+
+const timestampHint = "transcript timestamp (HH:MM:SS) where it comes up, or null"
+
+// extractions are the artifacts ExtractFromTranscript can pull out of a
+// meeting, by kind.
+var extractions = map[string]struct {
+	schema ai.Schema
+	prompt string
+}{
+	"action_items": {
+		schema: listSchema("action_items", "the action items agreed in a meeting", map[string]any{
+			"task":      map[string]any{"type": "string", "description": "what has to be done, as an imperative sentence"},
+			"owner":     nullableString("who will do it, as named in the transcript, or null if nobody took it"),
+			"due":       nullableString("due date as YYYY-MM-DD, resolved against today's date, or null if none was given"),
+			"timestamp": nullableString(timestampHint),
+		}),
+		prompt: "List every action item agreed in this meeting: tasks someone committed to or was asked to do. Leave out ideas nobody took on.",
+	},
+	"decisions": {
+		schema: listSchema("decisions", "the decisions made in a meeting", map[string]any{
+			"decision":  map[string]any{"type": "string", "description": "what was decided, in one sentence"},
+			"madeBy":    nullableString("who made or approved it, or null if it was the group"),
+			"timestamp": nullableString(timestampHint),
+		}),
+		prompt: "List every decision made in this meeting. Leave out proposals that were not settled.",
+	},
+	"open_questions": {
+		schema: listSchema("open_questions", "the questions left open in a meeting", map[string]any{
+			"question":  map[string]any{"type": "string", "description": "the open question, phrased as a question"},
+			"askedBy":   nullableString("who raised it, or null if unclear"),
+			"timestamp": nullableString(timestampHint),
+		}),
+		prompt: "List the questions raised in this meeting that were not answered or settled by its end.",
+	},
+}
+
+// ExtractFromTranscript pulls structured data out of the current or live
+// transcript with the chat model: kind is "action_items", "decisions" or
+// "open_questions". It returns a JSON document {"items": [...]} matching the
+// kind's schema.
+func (a *App) ExtractFromTranscript(kind string) (string, error) {
+	ex, ok := extractions[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown extraction %q", kind)
+	}
+	transcript := a.chatContext().transcript
+	if strings.TrimSpace(transcript) == "" {
+		return "", errors.New("no transcript yet — record or transcribe a meeting first")
+	}
+
+	cfg := a.GetConfig()
+	client, err := a.httpClient(cfg)
+	if err != nil {
+		return "", err
+	}
+	if !isLocal(cfg.Model) {
+		if err := a.checkBudget(cfg.Budget); err != nil {
+			return "", err
+		}
+	}
+	aiCfg, err := a.aiConfig(cfg, client)
+	if err != nil {
+		return "", err
+	}
+
+	prompt := fmt.Sprintf("<transcript>\n%s\n</transcript>\n\nToday is %s. %s", transcript, time.Now().Format("Monday, 2006-01-02"), ex.prompt)
+	if budget, err := a.aiClient.InputBudget(aiCfg); err == nil && ai.EstimateTokens(prompt) > budget {
+		return "", fmt.Errorf("the transcript is too long for %s — choose a model with a larger context window", cfg.Model)
+	}
+
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	resp, err := a.aiClient.Extract(ctx, aiCfg, ex.schema, []ai.Message{{Role: "user", Content: prompt}})
+	if resp != nil {
+		_ = a.recordUsage(resp)
+	}
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractFromTranscript(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var prompt string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content any `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		prompt = fmt.Sprint(body.Messages[len(body.Messages)-1].Content)
+		doc, _ := json.Marshal(`{"items":[{"task":"Send the deck","owner":"Ana","due":null,"timestamp":"00:01:10"}]}`)
+		fmt.Fprintf(w, `{"choices":[{"message":{"content":%s}}],"usage":{"prompt_tokens":100,"completion_tokens":20}}`, doc)
+	}))
+	defer srv.Close()
+
+	a := New()
+	if err := os.MkdirAll(layDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gw := fmt.Sprintf(`{"name":"Test","url":%q}`, srv.URL)
+	if err := os.WriteFile(filepath.Join(layDir(), "gateway.json"), []byte(gw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.writeConfig(Config{Model: "gpt-4.1", GatewayURL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.ExtractFromTranscript("action_items"); err == nil {
+		t.Fatal("expected an error without a transcript")
+	}
+	if _, err := a.ExtractFromTranscript("jokes"); err == nil {
+		t.Fatal("expected an error for an unknown kind")
+	}
+
+	a.currentTranscript = "[00:01:10.000] [Them] Ana, can you send the deck?\n[00:01:12.000] [You] Sure."
+	got, err := a.ExtractFromTranscript("action_items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Items []struct{ Task, Owner string }
+	}
+	if err := json.Unmarshal([]byte(got), &doc); err != nil || len(doc.Items) != 1 || doc.Items[0].Owner != "Ana" {
+		t.Fatalf("ExtractFromTranscript() = %s (%v)", got, err)
+	}
+	if !strings.Contains(prompt, "send the deck?") {
+		t.Fatalf("the transcript should be sent, got %q", prompt)
+	}
+	if s, err := a.GetUsageSummary("day"); err != nil || s.Requests != 1 {
+		t.Fatalf("usage = %+v, %v; want the extraction recorded", s, err)
+	}
+}
@@ -0,0 +1,468 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"lay/internal/ai"
+)
+
+// GatewayModel is a model listed for a gateway. The capabilities are
+// optional and override those the model is otherwise known by: its
+// built-in declaration or, for one lay doesn't know, images, PDFs and
+// streaming but no tool calls.
+type GatewayModel struct {
+	Value         string `json:"value"`
+	Label         string `json:"label"`
+	Vision        *bool  `json:"vision,omitempty"`
+	Documents     *bool  `json:"documents,omitempty"`
+	Tools         *bool  `json:"tools,omitempty"`
+	Streaming     *bool  `json:"streaming,omitempty"`
+	ContextWindow int    `json:"contextWindow,omitempty"` // tokens, 0 for the default
+	MaxOutput     int    `json:"maxOutput,omitempty"`     // tokens, 0 for no limit
+}
+
+// declares reports whether m sets any capability.
+func (m GatewayModel) declares() bool {
+	return m.Vision != nil || m.Documents != nil || m.Tools != nil || m.Streaming != nil || m.ContextWindow > 0 || m.MaxOutput > 0
+}
+
+// model turns m into a declaration of a gateway model, starting from base.
+func (m GatewayModel) model(base ai.Model) ai.Model {
+	out := base
+	out.ID, out.Provider, out.Name = m.Value, ai.GatewayProvider, m.Label
+	for _, f := range []struct {
+		set *bool
+		cap *bool
+	}{
+		{m.Vision, &out.Capabilities.Vision},
+		{m.Documents, &out.Capabilities.Documents},
+		{m.Tools, &out.Capabilities.Tools},
+		{m.Streaming, &out.Capabilities.Streaming},
+	} {
+		if f.set != nil {
+			*f.cap = *f.set
+		}
+	}
+	if m.ContextWindow > 0 {
+		out.ContextWindow = m.ContextWindow
+	}
+	if m.MaxOutput > 0 {
+		out.MaxOutput = m.MaxOutput
+	}
+	return out
+}
+
+// GatewayConfig is one gateway in gateway.json.
+type GatewayConfig struct {
+	Name    string             `json:"name"`
+	URL     string             `json:"url"`
+	Models  []GatewayModel     `json:"models"`
+	Headers map[string]string  `json:"headers,omitempty"` // extra headers, e.g. a tenant ID
+	Auth    *GatewayAuthConfig `json:"auth,omitempty"`
+
+	// Priority orders several gateways: lower is tried first, ties keep
+	// the order of the file.
+	Priority int `json:"priority,omitempty"`
+
+	// HealthURL is checked in the background; it must answer 2xx. Without
+	// it, any answer from URL short of a server error counts as up.
+	HealthURL string `json:"healthURL,omitempty"`
+
+	// ModelsURL is the gateway's OpenAI-style model list, e.g.
+	// https://gw.example.com/v1/models. When set, its models are offered
+	// alongside Models.
+	ModelsURL string `json:"modelsURL,omitempty"`
+
+	// PromptCache marks the stable part of the system prompt with
+	// Anthropic-style cache_control hints, for gateways that pass them on.
+	PromptCache bool `json:"promptCache,omitempty"`
+
+	// Dialect is the wire format of URL: "openai-chat", "openai-responses",
+	// "anthropic-messages" or "custom". Empty keeps lay's original format.
+	Dialect string               `json:"dialect,omitempty"`
+	Custom  *GatewayCustomConfig `json:"custom,omitempty"` // for the custom dialect
+
+	primary bool // first in gateway.json, whatever its priority; keeps the original token file
+}
+
+// gatewayFile is gateway.json: a single gateway, or several under
+// "gateways".
+type gatewayFile struct {
+	GatewayConfig
+	Gateways []GatewayConfig `json:"gateways"`
+}
+
+// GatewayCustomConfig is the "custom" block of gateway.json: a request body
+// template with {{placeholders}} and JSONPath-style selectors for the
+// answer. See ai.CustomDialect.
+type GatewayCustomConfig struct {
+	Request  json.RawMessage          `json:"request"`
+	Response GatewayResponseSelectors `json:"response"`
+}
+
+// GatewayResponseSelectors pick the parts of a custom gateway's answer.
+type GatewayResponseSelectors struct {
+	Text         string `json:"text"`
+	Thinking     string `json:"thinking,omitempty"`
+	InputTokens  string `json:"inputTokens,omitempty"`
+	OutputTokens string `json:"outputTokens,omitempty"`
+	Error        string `json:"error,omitempty"`
+	StreamText   string `json:"streamText,omitempty"` // per server-sent event; without it the answer is not streamed
+}
+
+// GatewayAuthConfig is the "auth" block of gateway.json. String values may
+// reference environment variables as ${VAR} so secrets stay out of the file.
+type GatewayAuthConfig struct {
+	Type    string `json:"type"`              // "bearer", "apiKey", "command" or "oauth2"
+	Token   string `json:"token,omitempty"`   // bearer / apiKey value
+	Header  string `json:"header,omitempty"`  // apiKey header name, default X-API-Key
+	Command string `json:"command,omitempty"` // prints a token on stdout; re-run when the gateway answers 401
+
+	// oauth2
+	Flow          string   `json:"flow,omitempty"` // "clientCredentials" or "deviceCode"
+	TokenURL      string   `json:"tokenURL,omitempty"`
+	DeviceAuthURL string   `json:"deviceAuthURL,omitempty"`
+	ClientID      string   `json:"clientID,omitempty"`
+	ClientSecret  string   `json:"clientSecret,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+}
+
+// GatewayLoginEvent is the payload of the gateway:login event, sent when a
+// device login started by StartGatewayLogin finishes.
+type GatewayLoginEvent struct {
+	Gateway string               `json:"gateway"`
+	Status  ai.GatewayAuthStatus `json:"status"`
+	Error   string               `json:"error,omitempty"`
+}
+
+// gatewayTokenFile caches OAuth tokens for the gateway.
+func gatewayTokenFile() string {
+	return filepath.Join(layDir(), "gateway-token.json")
+}
+
+// tokenFile caches c's OAuth tokens. The first gateway in the file uses
+// gatewayTokenFile, so logins survive adding a second one; the others are
+// keyed by name and URL, so a token is never sent to another host.
+func (c *GatewayConfig) tokenFile() string {
+	if c.primary {
+		return gatewayTokenFile()
+	}
+	return filepath.Join(layDir(), "gateway-token-"+fingerprint(c.Name+"\x00"+c.URL)+".json")
+}
+
+// GetGateways returns the gateways in gateway.json in the order they are
+// tried: by priority, then as listed. Entries without a name or URL are
+// skipped.
+func (a *App) GetGateways() []GatewayConfig {
+	// User override: ~/.lay/gateway.json
+	data, err := os.ReadFile(filepath.Join(layDir(), "gateway.json"))
+	if err != nil {
+		// Fall back to build-time embedded default
+		data, err = defaults.ReadFile("defaults/gateway.json")
+	}
+	if err != nil {
+		return nil
+	}
+	var file gatewayFile
+	if json.Unmarshal(data, &file) != nil {
+		return nil
+	}
+	list := file.Gateways
+	if len(list) == 0 {
+		list = []GatewayConfig{file.GatewayConfig}
+	}
+	var out []GatewayConfig
+	for _, gw := range list {
+		if gw.Name != "" && gw.URL != "" {
+			// Marked before sorting, so a change of priorities doesn't hand
+			// the first gateway's token and model list to another.
+			gw.primary = len(out) == 0
+			out = append(out, gw)
+		}
+	}
+	slices.SortStableFunc(out, func(x, y GatewayConfig) int { return cmp.Compare(x.Priority, y.Priority) })
+	return out
+}
+
+// GetGatewayConfig returns the gateway tried first, the one with the lowest
+// priority in gateway.json, or nil.
+func (a *App) GetGatewayConfig() *GatewayConfig {
+	gws := a.GetGateways()
+	if len(gws) == 0 {
+		return nil
+	}
+	return &gws[0]
+}
+
+// gatewaysFor returns the gateways to send model through, in the order to
+// try them, or nil when the gateway is disabled. Credentials come from
+// gateway.json with ${VAR} references resolved at request time.
+//
+// The URL in config.json only switches the gateway on. One that no longer
+// matches an entry exactly, because of a trailing slash or an edit in
+// gateway.json, still routes through the configured gateways with their
+// auth and dialect. With none configured it is an error.
+func (a *App) gatewaysFor(cfg Config, model string) ([]*ai.Gateway, error) {
+	if cfg.GatewayURL == "" {
+		return nil, nil
+	}
+	confs := a.GetGateways()
+	if len(confs) == 0 {
+		return nil, fmt.Errorf("gateway %s is not in gateway.json", cfg.GatewayURL)
+	}
+	return a.routeGateways(cfg, confs, model), nil
+}
+
+// routeGateways orders confs for model: gateways that are up before those
+// whose last check or request failed, the one Settings picked for the
+// model first among them, then by priority. Gateways whose model lists
+// don't include model are left out, unless none does.
+func (a *App) routeGateways(cfg Config, confs []GatewayConfig, model string) []*ai.Gateway {
+	serving := slices.DeleteFunc(slices.Clone(confs), func(c GatewayConfig) bool { return !a.gatewayServes(c, model) })
+	if len(serving) == 0 {
+		serving = slices.Clone(confs)
+	}
+	health := a.gatewayHealthSnapshot()
+	rank := func(c GatewayConfig) int {
+		r := 0
+		if health[c.Name].down() {
+			r += 2
+		}
+		if c.Name != cfg.GatewayModels[model] {
+			r++
+		}
+		return r
+	}
+	slices.SortStableFunc(serving, func(x, y GatewayConfig) int { return cmp.Compare(rank(x), rank(y)) })
+	out := make([]*ai.Gateway, len(serving))
+	for i := range serving {
+		out[i] = serving[i].gateway()
+	}
+	return out
+}
+
+// gatewayServes reports whether model is in c's models or in the list
+// last fetched from its modelsURL.
+func (a *App) gatewayServes(c GatewayConfig, model string) bool {
+	if slices.ContainsFunc(c.Models, func(m GatewayModel) bool { return m.Value == model }) {
+		return true
+	}
+	if c.ModelsURL == "" {
+		return false
+	}
+	entry := a.readModelCache().Sources[c.modelSourceKey()]
+	return slices.ContainsFunc(entry.Models, func(m ai.Model) bool { return m.ID == model })
+}
+
+// SaveGatewayModel makes requests for model go through the named gateway
+// first, as long as it is up. An empty gateway goes back to priority order.
+func (a *App) SaveGatewayModel(model, gateway string) error {
+	if gateway != "" && !slices.ContainsFunc(a.GetGateways(), func(c GatewayConfig) bool { return c.Name == gateway }) {
+		return fmt.Errorf("no gateway named %q in gateway.json", gateway)
+	}
+	cfg := a.GetConfig()
+	if gateway == "" {
+		delete(cfg.GatewayModels, model)
+	} else {
+		if cfg.GatewayModels == nil {
+			cfg.GatewayModels = make(map[string]string)
+		}
+		cfg.GatewayModels[model] = gateway
+	}
+	return a.writeConfig(cfg)
+}
+
+// gateway builds the client-side gateway from gateway.json.
+func (c *GatewayConfig) gateway() *ai.Gateway {
+	gw := &ai.Gateway{Name: c.Name, URL: c.URL, ModelsURL: c.ModelsURL, PromptCache: c.PromptCache, Dialect: c.Dialect}
+	if c.Custom != nil {
+		r := c.Custom.Response
+		gw.Custom = &ai.CustomDialect{
+			Request:      c.Custom.Request,
+			Text:         r.Text,
+			Thinking:     r.Thinking,
+			InputTokens:  r.InputTokens,
+			OutputTokens: r.OutputTokens,
+			Error:        r.Error,
+			StreamText:   r.StreamText,
+		}
+	}
+	if len(c.Headers) > 0 {
+		gw.Headers = make(map[string]string, len(c.Headers))
+		for k, v := range c.Headers {
+			gw.Headers[k] = expandEnvRefs(v)
+		}
+	}
+	gw.Auth = c.auth()
+	return gw
+}
+
+// fallbacksFor turns the configured fallback chain into client fallbacks.
+// Entries written "gateway/<model>" go through the gateways in gateway.json,
+// each in turn, even while the gateway toggle is off; the rest are called
+// directly.
+func (a *App) fallbacksFor(cfg Config) []ai.Fallback {
+	var out []ai.Fallback
+	for _, id := range cfg.Fallbacks {
+		model, ok := strings.CutPrefix(id, ai.GatewayProvider+"/")
+		if !ok {
+			out = append(out, ai.Fallback{Model: id})
+			continue
+		}
+		if confs := a.GetGateways(); len(confs) > 0 {
+			for _, gw := range a.routeGateways(cfg, confs, model) {
+				out = append(out, ai.Fallback{Model: model, Gateway: gw})
+			}
+		}
+	}
+	return out
+}
+
+// failoverFor returns the gateways after the first to try model through
+// when the first is down, as fallbacks ahead of the configured ones.
+func failoverFor(model string, gateways []*ai.Gateway) []ai.Fallback {
+	if len(gateways) < 2 {
+		return nil
+	}
+	out := make([]ai.Fallback, 0, len(gateways)-1)
+	for _, gw := range gateways[1:] {
+		out = append(out, ai.Fallback{Model: model, Gateway: gw})
+	}
+	return out
+}
+
+func (c *GatewayConfig) auth() *ai.GatewayAuth {
+	if c.Auth == nil {
+		return nil
+	}
+	return &ai.GatewayAuth{
+		Type:          c.Auth.Type,
+		Token:         expandEnvRefs(c.Auth.Token),
+		Header:        c.Auth.Header,
+		Command:       expandEnvRefs(c.Auth.Command),
+		Flow:          c.Auth.Flow,
+		TokenURL:      c.Auth.TokenURL,
+		DeviceAuthURL: c.Auth.DeviceAuthURL,
+		ClientID:      expandEnvRefs(c.Auth.ClientID),
+		ClientSecret:  expandEnvRefs(c.Auth.ClientSecret),
+		Scopes:        c.Auth.Scopes,
+		TokenFile:     c.tokenFile(),
+	}
+}
+
+// oauthGateway returns the named gateway from gateway.json, or the first
+// one for "", or an error if it doesn't use OAuth.
+func (a *App) oauthGateway(name string) (*GatewayConfig, error) {
+	gws := a.GetGateways()
+	i := 0
+	if name != "" {
+		i = slices.IndexFunc(gws, func(c GatewayConfig) bool { return c.Name == name })
+	}
+	if i < 0 || i >= len(gws) {
+		return nil, fmt.Errorf("unknown gateway %q", name)
+	}
+	conf := &gws[i]
+	if conf.Auth == nil || conf.Auth.Type != ai.AuthOAuth2 {
+		return nil, fmt.Errorf("gateway %s does not use OAuth login", conf.Name)
+	}
+	return conf, nil
+}
+
+// GetGatewayAuthStatus reports whether there is a cached OAuth login for the
+// named gateway ("" for the first) and whom it belongs to.
+func (a *App) GetGatewayAuthStatus(name string) ai.GatewayAuthStatus {
+	conf, err := a.oauthGateway(name)
+	if err != nil {
+		return ai.GatewayAuthStatus{}
+	}
+	return ai.OAuthStatus(conf.tokenFile())
+}
+
+// StartGatewayLogin starts a device-code login to the named gateway and
+// returns the code the user has to enter. The result arrives later as a
+// gateway:login event. Client-credentials gateways sign in on their own and
+// need no login.
+func (a *App) StartGatewayLogin(name string) (*ai.DeviceLogin, error) {
+	conf, err := a.oauthGateway(name)
+	if err != nil {
+		return nil, err
+	}
+	auth := conf.auth()
+
+	parent := a.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	client, err := a.httpClient(a.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+	login, err := ai.StartDeviceLogin(parent, client, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	a.loginMu.Lock()
+	if pending := a.logins[conf.Name]; pending != nil {
+		pending() // a newer login replaces a pending one
+	}
+	if a.logins == nil {
+		a.logins = make(map[string]context.CancelFunc)
+	}
+	a.logins[conf.Name] = cancel
+	a.loginMu.Unlock()
+
+	go func() {
+		defer cancel()
+		_, err := ai.PollDeviceLogin(ctx, client, auth, login)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		ev := GatewayLoginEvent{Gateway: conf.Name, Status: ai.OAuthStatus(auth.TokenFile)}
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		a.emit("gateway:login", ev)
+	}()
+	return login, nil
+}
+
+// LogoutGateway forgets the cached OAuth token of the named gateway.
+func (a *App) LogoutGateway(name string) error {
+	conf, err := a.oauthGateway(name)
+	if err != nil {
+		return err
+	}
+	a.loginMu.Lock()
+	if pending := a.logins[conf.Name]; pending != nil {
+		pending()
+		delete(a.logins, conf.Name)
+	}
+	a.loginMu.Unlock()
+
+	if err := os.Remove(conf.tokenFile()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnvRefs replaces ${VAR} with the value of the environment variable.
+// Unlike os.ExpandEnv it leaves bare $VAR alone, so tokens containing "$"
+// survive untouched.
+func expandEnvRefs(s string) string {
+	return envRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
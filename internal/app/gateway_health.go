@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"lay/internal/ai"
+)
+
+// gatewayProbeInterval is how often the gateways are checked while the
+// gateway is on.
+const gatewayProbeInterval = time.Minute
+
+// gatewayProbeTimeout bounds each check.
+const gatewayProbeTimeout = 5 * time.Second
+
+// GatewayStatus is the health of one gateway, as GetGatewayStatus reports it.
+type GatewayStatus struct {
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Priority  int       `json:"priority"`
+	Healthy   bool      `json:"healthy"`             // passed its last check; true until checked
+	CheckedAt time.Time `json:"checkedAt"`           // zero until the first check
+	LatencyMs int64     `json:"latencyMs,omitempty"` // of the last check that passed
+	LastError string    `json:"lastError,omitempty"` // the latest failure, from a check or a request
+	Active    bool      `json:"active"`              // requests for the chosen model go here first
+}
+
+// down reports whether the gateway failed its last check.
+func (s GatewayStatus) down() bool {
+	return !s.CheckedAt.IsZero() && !s.Healthy
+}
+
+// GetGatewayStatus reports the health of each gateway in gateway.json, in
+// priority order. Gateways that were never checked are checked first.
+func (a *App) GetGatewayStatus() []GatewayStatus {
+	confs := a.GetGateways()
+	if len(confs) == 0 {
+		return nil
+	}
+	health := a.gatewayHealthSnapshot()
+	for _, c := range confs {
+		if health[c.Name].CheckedAt.IsZero() {
+			ctx := a.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			a.probeGateways(ctx)
+			health = a.gatewayHealthSnapshot()
+			break
+		}
+	}
+
+	cfg := a.GetConfig()
+	active := a.routeGateways(cfg, confs, cfg.Model)[0].Name
+	out := make([]GatewayStatus, 0, len(confs))
+	for _, c := range confs {
+		st := health[c.Name]
+		st.Name, st.URL, st.Priority = c.Name, c.URL, c.Priority
+		st.Healthy = !st.down()
+		st.Active = cfg.GatewayURL != "" && c.Name == active
+		out = append(out, st)
+	}
+	return out
+}
+
+// watchGateways checks the gateways every gatewayProbeInterval while the
+// gateway is on, so requests skip one that is down. It returns when ctx
+// ends.
+func (a *App) watchGateways(ctx context.Context) {
+	ticker := time.NewTicker(gatewayProbeInterval)
+	defer ticker.Stop()
+	for {
+		if a.GetConfig().GatewayURL != "" {
+			a.probeGateways(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeGateways checks every gateway at once and records the results.
+func (a *App) probeGateways(ctx context.Context) {
+	client, err := a.httpClient(a.GetConfig())
+	if err != nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, conf := range a.GetGateways() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latency, err := probeGateway(ctx, client, conf)
+			if ctx.Err() == nil {
+				a.recordGatewayCheck(conf.Name, latency, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// probeGateway sends a GET to the gateway's health URL, or to its URL.
+func probeGateway(ctx context.Context, client *http.Client, conf GatewayConfig) (time.Duration, error) {
+	target := conf.HealthURL
+	if target == "" {
+		target = conf.URL
+	}
+	ctx, cancel := context.WithTimeout(ctx, gatewayProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range conf.Headers {
+		req.Header.Set(k, expandEnvRefs(v))
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	latency := time.Since(start)
+	// A chat endpoint rejects a bare GET, but rejecting it shows the
+	// gateway is up; only a server error counts against it.
+	if resp.StatusCode >= http.StatusInternalServerError || conf.HealthURL != "" && resp.StatusCode >= http.StatusMultipleChoices {
+		return latency, fmt.Errorf("%s answered %s", target, resp.Status)
+	}
+	return latency, nil
+}
+
+// recordGatewayCheck stores the result of a check of the named gateway.
+func (a *App) recordGatewayCheck(name string, latency time.Duration, err error) {
+	a.gatewayHealthMu.Lock()
+	defer a.gatewayHealthMu.Unlock()
+	if a.gatewayHealth == nil {
+		a.gatewayHealth = make(map[string]GatewayStatus)
+	}
+	st := a.gatewayHealth[name]
+	st.CheckedAt = time.Now()
+	st.Healthy = err == nil
+	if err != nil {
+		st.LastError = err.Error()
+	} else {
+		st.LatencyMs = latency.Milliseconds()
+	}
+	a.gatewayHealth[name] = st
+}
+
+// reportGatewayFailure marks a gateway down after a request through it
+// failed with an outage, until its next check passes.
+func (a *App) reportGatewayFailure(name string, err error) {
+	if name == "" || err == nil {
+		return
+	}
+	switch ai.ErrorKindOf(err) {
+	case ai.ErrorOverloaded, ai.ErrorServer, ai.ErrorTimeout, ai.ErrorNetwork:
+		a.recordGatewayCheck(name, 0, err)
+	}
+}
+
+func (a *App) gatewayHealthSnapshot() map[string]GatewayStatus {
+	a.gatewayHealthMu.Lock()
+	defer a.gatewayHealthMu.Unlock()
+	return maps.Clone(a.gatewayHealth)
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"lay/internal/ai"
+)
+
+// writeGateways writes a gateway.json with a primary and a backup gateway
+// in reverse file order, so priority has to sort them.
+func writeGateways(t *testing.T, primary, backup string) {
+	t.Helper()
+	if err := os.MkdirAll(layDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gw := fmt.Sprintf(`{"gateways":[
+		{"name":"Backup","url":%q,"priority":2,"models":[{"value":"gpt-4.1","label":"GPT-4.1"}]},
+		{"name":"Primary","url":%q,"priority":1,"models":[{"value":"gpt-4.1","label":"GPT-4.1"},{"value":"acme/only","label":"Only here"}]},
+		{"name":"","url":"https://unnamed.example.com"}
+	]}`, backup, primary)
+	if err := os.WriteFile(filepath.Join(layDir(), "gateway.json"), []byte(gw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// gatewayNames lists the names of the gateways gatewaysFor returns, or the
+// error.
+func gatewayNames(gws []*ai.Gateway, err error) []string {
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var names []string
+	for _, gw := range gws {
+		names = append(names, gw.Name)
+	}
+	return names
+}
+
+func TestGatewaysFailOver(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"from the backup"}}]}`)
+	}))
+	defer backup.Close()
+	writeGateways(t, primary.URL, backup.URL)
+
+	a := New()
+	if got := a.GetGatewayConfig(); got == nil || got.Name != "Primary" {
+		t.Fatalf("GetGatewayConfig() = %+v, want the gateway with the lowest priority", got)
+	}
+	cfg := Config{Model: "gpt-4.1", GatewayURL: primary.URL}
+	aiCfg, err := a.aiConfig(cfg, http.DefaultClient)
+	if err != nil || aiCfg.Gateway.Name != "Primary" || len(aiCfg.Fallbacks) != 1 || aiCfg.Fallbacks[0].Gateway.Name != "Backup" {
+		t.Fatalf("aiConfig() = gateway %+v, fallbacks %+v", aiCfg.Gateway, aiCfg.Fallbacks)
+	}
+
+	var notices []ai.FallbackNotice
+	aiCfg.Retry = ai.RetryPolicy{MaxAttempts: 1}
+	aiCfg.OnFallback = func(n ai.FallbackNotice) {
+		notices = append(notices, n)
+		a.reportGatewayFailure(n.FromGateway, n.Err)
+	}
+	resp, err := a.aiClient.Complete(context.Background(), aiCfg, "", []ai.Message{{Role: "user", Content: "hi"}}, nil)
+	if err != nil || resp.Text != "from the backup" {
+		t.Fatalf("Complete() = %+v, %v", resp, err)
+	}
+	if len(notices) != 1 || notices[0].FromGateway != "Primary" || !strings.HasSuffix(notices[0].Message(), "switching to Backup…") {
+		t.Fatalf("notices = %+v", notices)
+	}
+
+	// The failed gateway goes last until a check passes.
+	if got := gatewayNames(a.gatewaysFor(cfg, "gpt-4.1")); fmt.Sprint(got) != "[Backup Primary]" {
+		t.Fatalf("gatewaysFor() after a failure = %v", got)
+	}
+	a.recordGatewayCheck("Primary", time.Millisecond, nil)
+	if got := gatewayNames(a.gatewaysFor(cfg, "gpt-4.1")); fmt.Sprint(got) != "[Primary Backup]" {
+		t.Fatalf("gatewaysFor() after a passing check = %v", got)
+	}
+
+	// A model only one gateway serves has nowhere to fail over to.
+	if got := gatewayNames(a.gatewaysFor(cfg, "acme/only")); fmt.Sprint(got) != "[Primary]" {
+		t.Fatalf("gatewaysFor(acme/only) = %v", got)
+	}
+}
+
+func TestSaveGatewayModelPinsGateway(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeGateways(t, "https://primary.example.com", "https://backup.example.com")
+	a := New()
+
+	if err := a.SaveGatewayModel("gpt-4.1", "Nowhere"); err == nil {
+		t.Fatal("expected an error for an unknown gateway")
+	}
+	if err := a.SaveGatewayModel("gpt-4.1", "Backup"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := a.GetConfig()
+	cfg.GatewayURL = "https://primary.example.com"
+	if got := gatewayNames(a.gatewaysFor(cfg, "gpt-4.1")); fmt.Sprint(got) != "[Backup Primary]" {
+		t.Fatalf("gatewaysFor() = %v, want the pinned gateway first", got)
+	}
+
+	// A pinned gateway that is down gives way to one that is up.
+	a.reportGatewayFailure("Backup", &ai.APIError{Kind: ai.ErrorNetwork, Err: errors.New("connection refused")})
+	if got := gatewayNames(a.gatewaysFor(cfg, "gpt-4.1")); fmt.Sprint(got) != "[Primary Backup]" {
+		t.Fatalf("gatewaysFor() = %v, want the healthy gateway first", got)
+	}
+
+	if err := a.SaveGatewayModel("gpt-4.1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.GetConfig().GatewayModels; len(got) != 0 {
+		t.Fatalf("GatewayModels = %v, want the pin removed", got)
+	}
+}
+
+func TestGetGatewayStatusChecksGateways(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed) // a chat endpoint that only takes POST is still up
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer backup.Close()
+	writeGateways(t, primary.URL, backup.URL)
+
+	a := New()
+	if err := a.writeConfig(Config{Model: "gpt-4.1", GatewayURL: primary.URL}); err != nil {
+		t.Fatal(err)
+	}
+	got := a.GetGatewayStatus()
+	if len(got) != 2 || got[0].Name != "Primary" || got[1].Name != "Backup" {
+		t.Fatalf("GetGatewayStatus() = %+v", got)
+	}
+	if !got[0].Healthy || !got[0].Active || got[0].CheckedAt.IsZero() || got[0].LastError != "" {
+		t.Fatalf("primary = %+v, want healthy and active", got[0])
+	}
+	if got[1].Healthy || got[1].Active || got[1].LastError == "" {
+		t.Fatalf("backup = %+v, want down with its error", got[1])
+	}
+}
@@ -0,0 +1,282 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"lay/internal/ai"
+)
+
+// modelCacheTTL is how long a provider's model list is used before it is
+// fetched again. New models appear every few weeks, not every few minutes.
+const modelCacheTTL = 24 * time.Hour
+
+// discoveryTimeout bounds each provider's listing, so one slow endpoint
+// doesn't hold up Settings.
+const discoveryTimeout = 10 * time.Second
+
+// ModelList is the result of ListModels.
+type ModelList struct {
+	Models []ai.Model        `json:"models"`
+	Errors map[string]string `json:"errors,omitempty"` // by provider ID or gateway/<name>, for lists that could not be fetched
+}
+
+// modelCache is ~/.lay/models.json: the last model list fetched from each
+// provider.
+type modelCache struct {
+	Sources map[string]modelCacheEntry `json:"sources"`
+}
+
+type modelCacheEntry struct {
+	Account   string     `json:"account"` // fingerprint of the key or URL the list was fetched with
+	FetchedAt time.Time  `json:"fetchedAt"`
+	Models    []ai.Model `json:"models"`
+}
+
+// modelSource is a provider whose models can be listed with the current
+// configuration.
+type modelSource struct {
+	key      string // in the cache and ModelList.Errors: the provider ID, or gateway/<name> for gateways after the first
+	provider string
+	account  string
+	cfg      ai.Config
+}
+
+// modelSourceKey is the key of c's list in the model cache.
+func (c *GatewayConfig) modelSourceKey() string {
+	if c.primary {
+		return ai.GatewayProvider
+	}
+	return ai.GatewayProvider + "/" + c.Name
+}
+
+func modelCacheFile() string {
+	return filepath.Join(layDir(), "models.json")
+}
+
+// ListModels returns the models to offer in Settings: the built-in ones,
+// those each configured provider and the gateway list for the account,
+// those declared in config.json, which override the rest, and the local
+// server's. Provider lists are cached for a day.
+func (a *App) ListModels() ModelList {
+	return a.listModels(false)
+}
+
+// RefreshModels is ListModels with the provider lists fetched anew.
+func (a *App) RefreshModels() ModelList {
+	return a.listModels(true)
+}
+
+func (a *App) listModels(refresh bool) ModelList {
+	cfg := a.GetConfig()
+	found, errs := a.discoverModels(cfg, refresh)
+
+	models := a.aiClient.Registry().Models()
+	for _, id := range []string{ai.AnthropicProvider, ai.OpenAIProvider, ai.GeminiProvider} {
+		models = ai.MergeModels(models, found[id])
+	}
+	models = append(models, a.gatewayModels(found)...)
+	models = overrideModels(models, cfg.Models)
+	models = append(models, a.refreshLocalModels(cfg)...)
+	return ModelList{Models: models, Errors: errs}
+}
+
+// gatewayModels lists each gateway's models, declared and found, in
+// priority order. A model served by several gateways is listed once.
+func (a *App) gatewayModels(found map[string][]ai.Model) []ai.Model {
+	var models []ai.Model
+	for _, conf := range a.GetGateways() {
+		gw := conf.gateway()
+		for _, m := range ai.MergeModels(staticGatewayModels(conf), found[conf.modelSourceKey()]) {
+			m.Capabilities = gw.Capabilities(m.Capabilities)
+			if !slices.ContainsFunc(models, func(o ai.Model) bool { return o.ID == m.ID }) {
+				models = append(models, m)
+			}
+		}
+	}
+	return models
+}
+
+// declaredGatewayModels returns the models gateway.json lists with
+// capabilities, while the gateway is on, so they override what was
+// discovered and what the built-in declaration says.
+func (a *App) declaredGatewayModels(cfg Config) []ai.Model {
+	if cfg.GatewayURL == "" {
+		return nil
+	}
+	var models []ai.Model
+	for _, conf := range a.GetGateways() {
+		for _, m := range conf.Models {
+			if !m.declares() {
+				continue
+			}
+			base, ok := a.aiClient.Registry().Model(m.Value)
+			if !ok {
+				base = defaultGatewayModel
+			}
+			models = append(models, m.model(base))
+		}
+	}
+	return models
+}
+
+// defaultGatewayModel is what a gateway model is assumed to take when
+// nothing says otherwise.
+var defaultGatewayModel = ai.Model{Capabilities: ai.Capabilities{Vision: true, Documents: true, Streaming: true}}
+
+// staticGatewayModels turns the models listed for conf in gateway.json into
+// model declarations.
+func staticGatewayModels(conf GatewayConfig) []ai.Model {
+	models := make([]ai.Model, 0, len(conf.Models))
+	for _, m := range conf.Models {
+		models = append(models, m.model(defaultGatewayModel))
+	}
+	return models
+}
+
+// overrideModels replaces the models in models that declared also names
+// and adds the rest of declared at the end.
+func overrideModels(models, declared []ai.Model) []ai.Model {
+	out := slices.Clone(models)
+	for _, d := range declared {
+		i := slices.IndexFunc(out, func(m ai.Model) bool { return m.ID == d.ID && m.Provider == d.Provider })
+		if i >= 0 {
+			out[i] = d
+		} else {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// discoveredModels returns the cached models SendMessage should know about
+// besides the built-in ones, without going to the network. Gateway models
+// only count while the gateway is on, as they would otherwise shadow the
+// providers' own models of the same name.
+func (a *App) discoveredModels(cfg Config) []ai.Model {
+	cache := a.readModelCache()
+	var models []ai.Model
+	for _, src := range a.modelSources(cfg) {
+		if src.provider == ai.GatewayProvider && cfg.GatewayURL == "" {
+			continue
+		}
+		if entry, ok := cache.Sources[src.key]; ok && entry.Account == src.account {
+			models = append(models, entry.Models...)
+		}
+	}
+	return models
+}
+
+// modelSources lists the providers that can be asked for their models:
+// those with an API key, and the gateways in gateway.json with a modelsURL.
+func (a *App) modelSources(cfg Config) []modelSource {
+	base := ai.Config{AnthropicKey: cfg.AnthropicKey, OpenAIKey: cfg.OpenAIKey, GeminiKey: cfg.GeminiKey}
+	var sources []modelSource
+	for _, s := range []struct{ provider, key string }{
+		{ai.AnthropicProvider, cfg.AnthropicKey},
+		{ai.OpenAIProvider, cfg.OpenAIKey},
+		{ai.GeminiProvider, cfg.GeminiKey},
+	} {
+		if s.key != "" {
+			sources = append(sources, modelSource{key: s.provider, provider: s.provider, account: fingerprint(s.key), cfg: base})
+		}
+	}
+	for _, conf := range a.GetGateways() {
+		if conf.ModelsURL == "" {
+			continue
+		}
+		gwCfg := base
+		gwCfg.Gateway = conf.gateway()
+		sources = append(sources, modelSource{key: conf.modelSourceKey(), provider: ai.GatewayProvider, account: fingerprint(conf.URL + "\x00" + conf.ModelsURL), cfg: gwCfg})
+	}
+	return sources
+}
+
+// discoverModels returns each source's models, from the cache while it is
+// fresh. A source that cannot be reached falls back to its last list and is
+// reported in errs.
+func (a *App) discoverModels(cfg Config, refresh bool) (found map[string][]ai.Model, errs map[string]string) {
+	sources := a.modelSources(cfg)
+	client, err := a.httpClient(cfg)
+	if err != nil {
+		errs = make(map[string]string)
+		for _, src := range sources {
+			errs[src.key] = err.Error()
+		}
+		return nil, errs
+	}
+
+	a.modelsMu.Lock()
+	defer a.modelsMu.Unlock()
+	cache := a.readModelCache()
+	if cache.Sources == nil {
+		cache.Sources = make(map[string]modelCacheEntry)
+	}
+
+	found = make(map[string][]ai.Model)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	fetched := false
+	for _, src := range sources {
+		entry, cached := cache.Sources[src.key]
+		cached = cached && entry.Account == src.account
+		if cached && !refresh && time.Since(entry.FetchedAt) < modelCacheTTL {
+			found[src.key] = entry.Models
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+			defer cancel()
+			src.cfg.HTTPClient = client
+			models, err := a.aiClient.ListModels(ctx, src.cfg, src.provider)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if errs == nil {
+					errs = make(map[string]string)
+				}
+				errs[src.key] = err.Error()
+				if cached {
+					found[src.key] = entry.Models
+				}
+				return
+			}
+			found[src.key] = models
+			cache.Sources[src.key] = modelCacheEntry{Account: src.account, FetchedAt: time.Now(), Models: models}
+			fetched = true
+		}()
+	}
+	wg.Wait()
+
+	if fetched {
+		// A cache that cannot be written only means fetching again next time.
+		if data, err := json.MarshalIndent(cache, "", "  "); err == nil {
+			_ = os.WriteFile(modelCacheFile(), data, 0o644)
+		}
+	}
+	return found, errs
+}
+
+func (a *App) readModelCache() modelCache {
+	var cache modelCache
+	if data, err := os.ReadFile(modelCacheFile()); err == nil {
+		_ = json.Unmarshal(data, &cache)
+	}
+	return cache
+}
+
+// fingerprint identifies a secret in the cache without storing it.
+func fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lay/internal/ai"
+)
+
+func TestListModelsCachesGatewayList(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `{"data":[{"id":"acme/big","context_length":300000},{"id":"claude-sonnet-4-6"}]}`)
+	}))
+	defer srv.Close()
+
+	a := New()
+	if err := os.MkdirAll(layDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gw := fmt.Sprintf(`{"name":"Corp","url":%q,"modelsURL":%q,"models":[{"value":"claude-sonnet-4-6","label":"Sonnet via Corp"}]}`, srv.URL, srv.URL+"/models")
+	if err := os.WriteFile(filepath.Join(layDir(), "gateway.json"), []byte(gw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	declared := ai.Model{ID: "acme/big", Provider: "gateway", Name: "Big (declared)", Capabilities: ai.Capabilities{Streaming: true}}
+	if err := a.writeConfig(Config{Model: "claude-sonnet-4-6", Models: []ai.Model{declared}}); err != nil {
+		t.Fatal(err)
+	}
+
+	list := a.ListModels()
+	if len(list.Errors) != 0 {
+		t.Fatalf("errors = %v", list.Errors)
+	}
+	var gateway []ai.Model
+	for _, m := range list.Models {
+		if m.Provider == "gateway" {
+			gateway = append(gateway, m)
+		}
+	}
+	if len(gateway) != 2 || gateway[0].Name != "Sonnet via Corp" || gateway[1] != declared {
+		t.Fatalf("gateway models = %+v", gateway)
+	}
+
+	a.ListModels()
+	if calls != 1 {
+		t.Fatalf("calls = %d after a second ListModels, want the cached list", calls)
+	}
+	a.RefreshModels()
+	if calls != 2 {
+		t.Fatalf("calls = %d after RefreshModels, want 2", calls)
+	}
+
+	// Cached gateway models only resolve while the gateway is on.
+	if got := a.discoveredModels(Config{}); len(got) != 0 {
+		t.Fatalf("discoveredModels() with the gateway off = %+v", got)
+	}
+	if got := a.discoveredModels(Config{GatewayURL: srv.URL}); len(got) != 2 || got[0].ContextWindow != 300000 {
+		t.Fatalf("discoveredModels() = %+v", got)
+	}
+}
+
+func TestGatewayModelCapabilities(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := os.MkdirAll(layDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gw := `{"name":"Corp","url":"https://gw.example.com","models":[
+		{"value":"acme/text","label":"Acme text","vision":false,"maxOutput":4096},
+		{"value":"gpt-4.1","label":"GPT-4.1","documents":false},
+		{"value":"claude-sonnet-4-6","label":"Sonnet"}
+	]}`
+	if err := os.WriteFile(filepath.Join(layDir(), "gateway.json"), []byte(gw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a := New()
+	cfg := Config{Model: "acme/text", GatewayURL: "https://gw.example.com"}
+
+	models := a.declaredGatewayModels(cfg)
+	if len(models) != 2 {
+		t.Fatalf("declaredGatewayModels() = %+v, want only the models that declare something", models)
+	}
+	if m := models[0]; m.Capabilities.Vision || !m.Capabilities.Documents || m.Capabilities.Tools || m.MaxOutput != 4096 {
+		t.Fatalf("acme/text = %+v", m)
+	}
+	// A known model keeps its built-in declaration except what is overridden.
+	if m := models[1]; !m.Capabilities.Tools || m.Capabilities.Documents || m.ContextWindow != 1_047_576 || m.Provider != "gateway" {
+		t.Fatalf("gpt-4.1 = %+v", m)
+	}
+
+	msgs := []ai.Message{{Role: "user", Content: "what is this?", Images: []string{"aW1n"}}}
+	aiCfg, err := a.aiConfig(cfg, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, notices, err := a.aiClient.Fit(aiCfg, msgs)
+	if err != nil || len(got[0].Images) != 0 || len(notices) != 1 {
+		t.Fatalf("Fit() = %+v, %q, %v", got, notices, err)
+	}
+	if a.declaredGatewayModels(Config{Model: "acme/text"}) != nil {
+		t.Fatal("gateway models declared with the gateway off")
+	}
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"lay/internal/ai"
+)
+
+// NetworkConfig is the "network" block of config.json, for machines behind
+// a corporate proxy or TLS inspection. It is edited by hand.
+type NetworkConfig struct {
+	ProxyURL       string `json:"proxyURL,omitempty"`       // e.g. http://proxy.corp:3128; empty uses HTTPS_PROXY
+	CAFile         string `json:"caFile,omitempty"`         // PEM bundle of private CAs
+	ClientCert     string `json:"clientCert,omitempty"`     // PEM client certificate for mTLS
+	ClientKey      string `json:"clientKey,omitempty"`      // PEM key for clientCert
+	ConnectTimeout int    `json:"connectTimeout,omitempty"` // seconds, default 15
+	ReadTimeout    int    `json:"readTimeout,omitempty"`    // seconds without data before giving up, default 300
+}
+
+// httpClient returns the client shared by every provider, rebuilding it
+// only when the network settings change so connections are reused.
+func (a *App) httpClient(cfg Config) (*http.Client, error) {
+	a.httpMu.Lock()
+	defer a.httpMu.Unlock()
+	if a.http != nil && a.httpNetwork == cfg.Network {
+		return a.http, nil
+	}
+
+	n := cfg.Network
+	client, err := ai.NewHTTPClient(ai.Network{
+		ProxyURL:       n.ProxyURL,
+		CAFile:         expandHome(n.CAFile),
+		ClientCert:     expandHome(n.ClientCert),
+		ClientKey:      expandHome(n.ClientKey),
+		ConnectTimeout: time.Duration(n.ConnectTimeout) * time.Second,
+		ReadTimeout:    time.Duration(n.ReadTimeout) * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("network settings in config.json: %w", err)
+	}
+	a.http, a.httpNetwork = client, n
+	return client, nil
+}
+
+// expandHome resolves a leading ~/ so paths in config.json can be written
+// the way users type them.
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,318 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"lay/internal/ai"
+)
+
+// maxToolRounds caps how many times one chat message may go back to the
+// model with tool results before the answer is taken as final.
+const maxToolRounds = 8
+
+// maxToolResultChars keeps a single tool result from flooding the context.
+const maxToolResultChars = 20000
+
+// ToolEvent is the payload of chat:tool, sent when a tool starts and again
+// when it finishes.
+type ToolEvent struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Status    string `json:"status"`           // "running", "done" or "error"
+	Result    string `json:"result,omitempty"` // first few hundred characters
+}
+
+// chatTools are offered to every model that supports tool calling.
+var chatTools = []ai.Tool{
+	{
+		Name:        "read_notes",
+		Description: "Read the user's notes file (notes.md).",
+		Parameters:  map[string]any{"type": "object", "properties": map[string]any{}},
+	},
+	{
+		Name:        "append_notes",
+		Description: "Append markdown to the end of the user's notes file. Use only when the user asks to save something.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"text": map[string]any{"type": "string", "description": "Markdown to append."},
+			},
+			"required": []string{"text"},
+		},
+	},
+	{
+		Name:        "list_transcripts",
+		Description: "List saved meeting transcripts, newest first. Session names are recording start times (YYYY-MM-DD-HH-MM-SS).",
+		Parameters:  map[string]any{"type": "object", "properties": map[string]any{}},
+	},
+	{
+		Name:        "search_transcripts",
+		Description: "Search saved meeting transcripts for lines containing a phrase (case-insensitive).",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{"type": "string", "description": "Phrase to look for."},
+				"limit": map[string]any{"type": "integer", "description": "Maximum number of matching lines, default 20."},
+			},
+			"required": []string{"query"},
+		},
+	},
+	{
+		Name:        "get_transcript_range",
+		Description: "Get the transcript lines between two timestamps (HH:MM:SS or MM:SS from the start of the recording). Defaults to the current or live transcript.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"from":    map[string]any{"type": "string", "description": "Start time, e.g. 00:05:00."},
+				"to":      map[string]any{"type": "string", "description": "End time, e.g. 00:10:00."},
+				"session": map[string]any{"type": "string", "description": "Saved session name from list_transcripts. Omit for the current transcript."},
+			},
+			"required": []string{"from", "to"},
+		},
+	},
+}
+
+// runTool executes one tool call. Errors are meant for the model, which is
+// told about them and can try again.
+func (a *App) runTool(call ai.ToolCall) (string, error) {
+	var args struct {
+		Text    string `json:"text"`
+		Query   string `json:"query"`
+		Limit   int    `json:"limit"`
+		From    string `json:"from"`
+		To      string `json:"to"`
+		Session string `json:"session"`
+	}
+	if strings.TrimSpace(call.Arguments) != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+
+	switch call.Name {
+	case "read_notes":
+		if notes := a.GetNotes(); notes != "" {
+			return notes, nil
+		}
+		return "(the notes file is empty)", nil
+	case "append_notes":
+		return a.appendNotes(args.Text)
+	case "list_transcripts":
+		return listTranscripts()
+	case "search_transcripts":
+		return searchTranscripts(args.Query, args.Limit)
+	case "get_transcript_range":
+		return a.transcriptRange(args.Session, args.From, args.To)
+	}
+	return "", fmt.Errorf("unknown tool %q", call.Name)
+}
+
+func (a *App) appendNotes(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("text is empty")
+	}
+	f, err := os.OpenFile(filepath.Join(layDir(), "notes.md"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "\n\n%s\n", text); err != nil {
+		return "", err
+	}
+	a.emit("notes:appended", text)
+	return "Appended to notes.", nil
+}
+
+func transcriptsDir() string {
+	return filepath.Join(layDir(), "transcripts")
+}
+
+// transcriptSessions returns the saved session names, newest first.
+func transcriptSessions() ([]string, error) {
+	entries, err := os.ReadDir(transcriptsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sessions []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".md") {
+			sessions = append(sessions, strings.TrimSuffix(e.Name(), ".md"))
+		}
+	}
+	// Session names are timestamps, so they sort chronologically.
+	sort.Sort(sort.Reverse(sort.StringSlice(sessions)))
+	return sessions, nil
+}
+
+func readTranscript(session string) (string, error) {
+	if session == "" || session != filepath.Base(session) || strings.HasPrefix(session, ".") {
+		return "", fmt.Errorf("invalid session name %q", session)
+	}
+	data, err := os.ReadFile(filepath.Join(transcriptsDir(), session+".md"))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("no transcript named %q — call list_transcripts", session)
+	}
+	return string(data), err
+}
+
+func listTranscripts() (string, error) {
+	sessions, err := transcriptSessions()
+	if err != nil {
+		return "", err
+	}
+	if len(sessions) == 0 {
+		return "No saved transcripts.", nil
+	}
+	var sb strings.Builder
+	for _, s := range sessions {
+		text, err := readTranscript(s)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s (%d lines)\n", s, len(transcriptLines(text)))
+	}
+	return sb.String(), nil
+}
+
+func searchTranscripts(query string, limit int) (string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return "", fmt.Errorf("query is empty")
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	sessions, err := transcriptSessions()
+	if err != nil {
+		return "", err
+	}
+	needle := strings.ToLower(query)
+	var sb strings.Builder
+	found := 0
+	for _, s := range sessions {
+		text, err := readTranscript(s)
+		if err != nil {
+			continue
+		}
+		for _, line := range transcriptLines(text) {
+			if !strings.Contains(strings.ToLower(line), needle) {
+				continue
+			}
+			if found == limit {
+				fmt.Fprintf(&sb, "(stopped after %d matches)\n", limit)
+				return sb.String(), nil
+			}
+			fmt.Fprintf(&sb, "%s: %s\n", s, line)
+			found++
+		}
+	}
+	if found == 0 {
+		return fmt.Sprintf("No transcript lines contain %q.", query), nil
+	}
+	return sb.String(), nil
+}
+
+// transcriptRange returns the lines of a transcript stamped between from and
+// to. An empty session means the transcript chat is currently about.
+func (a *App) transcriptRange(session, from, to string) (string, error) {
+	start, ok := parseClock(from)
+	if !ok {
+		return "", fmt.Errorf("invalid start time %q — use HH:MM:SS", from)
+	}
+	end, ok := parseClock(to)
+	if !ok {
+		return "", fmt.Errorf("invalid end time %q — use HH:MM:SS", to)
+	}
+
+	var text string
+	if session != "" {
+		var err error
+		if text, err = readTranscript(session); err != nil {
+			return "", err
+		}
+	} else if text = a.currentTranscript; text == "" {
+		a.liveMu.Lock()
+		text = strings.Join(a.liveSegments, "\n")
+		a.liveMu.Unlock()
+	}
+	if text == "" {
+		return "", fmt.Errorf("there is no current transcript — pass a session from list_transcripts")
+	}
+
+	var sb strings.Builder
+	for _, line := range transcriptLines(text) {
+		if ts, ok := lineTimestamp(line); ok && ts >= start && ts <= end {
+			sb.WriteString(line + "\n")
+		}
+	}
+	if sb.Len() == 0 {
+		return fmt.Sprintf("No transcript lines between %s and %s.", from, to), nil
+	}
+	return sb.String(), nil
+}
+
+// transcriptLines returns the timestamped lines of a transcript, skipping the
+// header and blank lines.
+func transcriptLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if _, ok := lineTimestamp(line); ok {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// lineTimestamp parses the leading [HH:MM:SS.mmm] of a transcript line.
+func lineTimestamp(line string) (float64, bool) {
+	if !strings.HasPrefix(line, "[") {
+		return 0, false
+	}
+	end := strings.IndexByte(line, ']')
+	if end < 0 {
+		return 0, false
+	}
+	ts := parseWhisperTS(line[1:end])
+	return ts, ts >= 0
+}
+
+// parseClock parses HH:MM:SS or MM:SS, with optional fractional seconds,
+// into seconds.
+func parseClock(s string) (float64, bool) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	var secs float64
+	for i, p := range parts {
+		n, err := strconv.ParseFloat(p, 64)
+		if err != nil || n < 0 || (i < len(parts)-1 && n != float64(int(n))) {
+			return 0, false
+		}
+		secs = secs*60 + n
+	}
+	return secs, true
+}
+
+// clip shortens s to at most n bytes without splitting a UTF-8 sequence.
+func clip(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
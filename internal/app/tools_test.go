@@ -0,0 +1,158 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"lay/internal/ai"
+)
+
+func writeTestTranscript(t *testing.T, session, body string) {
+	t.Helper()
+	dir := transcriptsDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := fmt.Sprintf("# Transcript — %s\n\n%s\n", session, body)
+	if err := os.WriteFile(filepath.Join(dir, session+".md"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTranscriptTools(t *testing.T) {
+	a := newUsageTestApp(t)
+	writeTestTranscript(t, "2026-03-13-10-00-00", "[00:00:01.000] [You] Budget is tight.\n[00:01:00.000] [Them] Agreed.")
+	writeTestTranscript(t, "2026-03-14-09-00-00", "[00:00:05.000] [Them] The budget review is Friday.")
+
+	list, err := a.runTool(ai.ToolCall{Name: "list_transcripts"})
+	if err != nil || !strings.HasPrefix(list, "2026-03-14-09-00-00 (1 lines)\n2026-03-13-10-00-00 (2 lines)") {
+		t.Fatalf("list_transcripts = %q, %v", list, err)
+	}
+
+	found, err := a.runTool(ai.ToolCall{Name: "search_transcripts", Arguments: `{"query":"BUDGET","limit":1}`})
+	if err != nil || !strings.HasPrefix(found, "2026-03-14-09-00-00: [00:00:05.000] [Them] The budget review") || !strings.Contains(found, "stopped after 1") {
+		t.Fatalf("search_transcripts = %q, %v", found, err)
+	}
+
+	a.currentTranscript = "[00:04:59.000] [You] early\n[00:05:00.000] [You] start\n[00:09:30.500] [Them] middle\n[00:10:00.001] [You] late"
+	got, err := a.runTool(ai.ToolCall{Name: "get_transcript_range", Arguments: `{"from":"5:00","to":"00:10:00"}`})
+	if err != nil || got != "[00:05:00.000] [You] start\n[00:09:30.500] [Them] middle\n" {
+		t.Fatalf("get_transcript_range = %q, %v", got, err)
+	}
+
+	if _, err := a.runTool(ai.ToolCall{Name: "get_transcript_range", Arguments: `{"from":"0:00","to":"1:00","session":"../notes"}`}); err == nil {
+		t.Fatal("expected an error for a session outside the transcripts folder")
+	}
+}
+
+func TestSendMessageRunsToolLoop(t *testing.T) {
+	a := newUsageTestApp(t)
+	if err := a.SaveNotes("# Notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Messages []map[string]any `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if calls.Add(1) == 1 {
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Saving it.","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"append_notes","arguments":"{\"text\":\"- follow up with Sam\"}"}}]}}]}`)
+			return
+		}
+		last := req.Messages[len(req.Messages)-1]
+		if last["role"] != "tool" || last["content"] != "Appended to notes." {
+			t.Errorf("tool result not sent back, got %v", last)
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Done."}}]}`)
+	}))
+	defer srv.Close()
+
+	cfg := Config{
+		Model:    "local/llama3.2",
+		LocalURL: srv.URL,
+		Models:   []ai.Model{{ID: "llama3.2", Provider: "local", Capabilities: ai.Capabilities{Tools: true}}},
+	}
+	data, _ := json.Marshal(cfg)
+	if err := os.WriteFile(filepath.Join(layDir(), "config.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := a.SendMessage("req-1", "chat-1", `{"content":"note to follow up with Sam"}`)
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if reply != "Saving it.\n\nDone." {
+		t.Fatalf("reply = %q, want text from both rounds", reply)
+	}
+	if notes := a.GetNotes(); notes != "# Notes\n\n- follow up with Sam\n" {
+		t.Fatalf("notes = %q", notes)
+	}
+	if sum, _ := a.GetUsageSummary("day"); sum.Requests != 2 {
+		t.Fatalf("usage recorded for %d requests, want 2", sum.Requests)
+	}
+}
+
+func TestSendMessageStopsAtToolLimit(t *testing.T) {
+	a := newUsageTestApp(t)
+	if err := a.SaveNotes("# Notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The model asks for another note on every round and never answers.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"","tool_calls":[
+			{"id":"call_1","type":"function","function":{"name":"append_notes","arguments":"{\"text\":\"- again\"}"}}]}}]}`)
+	}))
+	defer srv.Close()
+
+	cfg := Config{
+		Model:    "local/llama3.2",
+		LocalURL: srv.URL,
+		Models:   []ai.Model{{ID: "llama3.2", Provider: "local", Capabilities: ai.Capabilities{Tools: true}}},
+	}
+	if err := a.writeConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := a.SendMessage("req-1", "chat-1", `{"content":"keep noting"}`)
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if !strings.Contains(reply, "Stopped after 8 rounds") {
+		t.Fatalf("reply = %q, want the tool limit mentioned", reply)
+	}
+	if got := strings.Count(a.GetNotes(), "- again"); got != maxToolRounds-1 {
+		t.Fatalf("notes appended %d times, want %d: refused calls must not run", got, maxToolRounds-1)
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	for in, want := range map[string]float64{"00:05:00": 300, "5:00": 300, "1:02:03.5": 3723.5} {
+		if got, ok := parseClock(in); !ok || got != want {
+			t.Errorf("parseClock(%q) = %v, %v; want %v", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "90", "1:x", "1.5:00"} {
+		if _, ok := parseClock(in); ok {
+			t.Errorf("parseClock(%q) should fail", in)
+		}
+	}
+}
@@ -40,6 +40,7 @@ func (a *App) StartRecording() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	a.currentSession = filepath.Base(dir)
 	if err := platform.StartCapture(dir); err != nil {
 		return "", err
 	}
@@ -219,6 +220,7 @@ func (a *App) StartMicOnlyRecording() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	a.currentSession = filepath.Base(dir)
 	if err := platform.StartMicOnlyCapture(dir); err != nil {
 		return "", err
 	}
@@ -0,0 +1,311 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"lay/internal/ai"
+)
+
+// ModelPrice is what a model costs in USD per million tokens. Cache rates
+// left at zero are derived from Input using Anthropic's multipliers, which
+// also match most OpenAI and Gemini models for reads.
+type ModelPrice struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cacheRead,omitempty"`
+	CacheWrite float64 `json:"cacheWrite,omitempty"`
+}
+
+// cost prices u.
+func (p ModelPrice) cost(u ai.Usage) float64 {
+	read, write := p.CacheRead, p.CacheWrite
+	if read == 0 {
+		read = p.Input * 0.1
+	}
+	if write == 0 {
+		write = p.Input * 1.25
+	}
+	return (float64(u.InputTokens)*p.Input + float64(u.OutputTokens)*p.Output +
+		float64(u.CacheReadTokens)*read + float64(u.CacheWriteTokens)*write) / 1e6
+}
+
+// defaultPricing holds list prices for the built-in models. Users can add or
+// override rates in ~/.lay/pricing.json, keyed by model ID.
+var defaultPricing = map[string]ModelPrice{
+	"claude-haiku-4-5-20251001": {Input: 1, Output: 5},
+	"claude-sonnet-4-6":         {Input: 3, Output: 15},
+	"claude-opus-4-6":           {Input: 5, Output: 25},
+	"gpt-5-nano":                {Input: 0.05, Output: 0.40},
+	"gpt-5-mini":                {Input: 0.25, Output: 2},
+	"gpt-5.1":                   {Input: 1.25, Output: 10},
+	"gpt-5.2":                   {Input: 1.75, Output: 14},
+	"gpt-5.2-chat-latest":       {Input: 1.75, Output: 14},
+	"gpt-4.1":                   {Input: 2, Output: 8, CacheRead: 0.5},
+	"gpt-4o":                    {Input: 2.5, Output: 10, CacheRead: 1.25},
+	"gemini-2.5-flash-lite":     {Input: 0.10, Output: 0.40},
+	"gemini-2.5-flash":          {Input: 0.30, Output: 2.50},
+	"gemini-2.5-pro":            {Input: 1.25, Output: 10},
+}
+
+// BudgetConfig is the "budget" block of config.json. A zero cap means none.
+type BudgetConfig struct {
+	Daily   float64 `json:"daily,omitempty"`   // USD per calendar day
+	Monthly float64 `json:"monthly,omitempty"` // USD per calendar month
+}
+
+// ErrBudgetExceeded is returned by SendMessage once a spending cap is reached.
+var ErrBudgetExceeded = errors.New("spending cap reached")
+
+// ErrorBudget is the chat:error kind for ErrBudgetExceeded.
+const ErrorBudget ai.ErrorKind = "budget"
+
+// UsageEntry is one line of ~/.lay/usage.jsonl.
+type UsageEntry struct {
+	Time             time.Time `json:"time"`
+	Model            string    `json:"model"`
+	Provider         string    `json:"provider"`    // provider that was called, e.g. "gateway"
+	InputTokens      int       `json:"inputTokens"` // not served from or written to the cache
+	OutputTokens     int       `json:"outputTokens"`
+	CacheReadTokens  int       `json:"cacheReadTokens,omitempty"`  // prompt tokens served from the cache
+	CacheWriteTokens int       `json:"cacheWriteTokens,omitempty"` // prompt tokens written to the cache
+	Cost             float64   `json:"cost"`                       // USD
+	Priced           bool      `json:"priced"`                     // false when the model has no rate
+}
+
+type ModelUsage struct {
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	InputTokens      int     `json:"inputTokens"`
+	OutputTokens     int     `json:"outputTokens"`
+	CacheReadTokens  int     `json:"cacheReadTokens"`
+	CacheWriteTokens int     `json:"cacheWriteTokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// UsageSummary totals the ledger for one period.
+type UsageSummary struct {
+	Period           string       `json:"period"` // "day", "month" or "all"
+	Since            time.Time    `json:"since"`
+	Requests         int          `json:"requests"`
+	InputTokens      int          `json:"inputTokens"`
+	OutputTokens     int          `json:"outputTokens"`
+	CacheReadTokens  int          `json:"cacheReadTokens"`  // cache hits
+	CacheWriteTokens int          `json:"cacheWriteTokens"` // cache misses written for later requests
+	Cost             float64      `json:"cost"`
+	Unpriced         int          `json:"unpriced"`         // requests to models without a rate
+	Budget           float64      `json:"budget,omitempty"` // cap for the period, 0 if none
+	Models           []ModelUsage `json:"models"`           // most expensive first
+}
+
+func usagePath() string {
+	return filepath.Join(layDir(), "usage.jsonl")
+}
+
+// pricing returns the built-in rates merged with ~/.lay/pricing.json.
+func pricing() map[string]ModelPrice {
+	rates := make(map[string]ModelPrice, len(defaultPricing))
+	for id, p := range defaultPricing {
+		rates[id] = p
+	}
+	data, err := os.ReadFile(filepath.Join(layDir(), "pricing.json"))
+	if err != nil {
+		return rates
+	}
+	var custom map[string]ModelPrice
+	if json.Unmarshal(data, &custom) == nil {
+		for id, p := range custom {
+			rates[id] = p
+		}
+	}
+	return rates
+}
+
+// priceFor looks a model up by its ID, its qualified ID and, for gateway IDs
+// such as "anthropic/claude-sonnet-4-6", the part after the vendor.
+func priceFor(rates map[string]ModelPrice, m ai.Model) (ModelPrice, bool) {
+	keys := []string{m.ID}
+	if m.Provider != "" {
+		keys = append(keys, m.Provider+"/"+m.ID)
+	}
+	if i := strings.LastIndex(m.ID, "/"); i >= 0 {
+		keys = append(keys, m.ID[i+1:])
+	}
+	for _, k := range keys {
+		if p, ok := rates[k]; ok {
+			return p, true
+		}
+	}
+	return ModelPrice{}, false
+}
+
+// recordUsage prices resp and appends it to the ledger.
+func (a *App) recordUsage(resp *ai.Response) error {
+	entry := UsageEntry{
+		Time:             time.Now(),
+		Model:            resp.Model.ID,
+		Provider:         resp.Provider,
+		InputTokens:      resp.Usage.InputTokens,
+		OutputTokens:     resp.Usage.OutputTokens,
+		CacheReadTokens:  resp.Usage.CacheReadTokens,
+		CacheWriteTokens: resp.Usage.CacheWriteTokens,
+	}
+	if resp.Provider == ai.LocalProvider {
+		entry.Priced = true // runs on the user's own hardware
+	} else if p, ok := priceFor(pricing(), resp.Model); ok {
+		entry.Cost = p.cost(resp.Usage)
+		entry.Priced = true
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	a.usageMu.Lock()
+	defer a.usageMu.Unlock()
+	f, err := os.OpenFile(usagePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// readUsage returns the ledger entries at or after since. Lines that fail to
+// parse are skipped so a hand-edited ledger can't break the app.
+func (a *App) readUsage(since time.Time) ([]UsageEntry, error) {
+	a.usageMu.Lock()
+	defer a.usageMu.Unlock()
+	f, err := os.Open(usagePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []UsageEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e UsageEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || e.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// periodStart returns the start of the current day or month in local time.
+func periodStart(period string, now time.Time) (time.Time, error) {
+	y, m, d := now.Date()
+	switch period {
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, now.Location()), nil
+	case "all":
+		return time.Time{}, nil
+	}
+	return time.Time{}, fmt.Errorf("unknown usage period %q — use day, month or all", period)
+}
+
+// GetUsageSummary totals token usage and cost for period: "day", "month" or
+// "all".
+func (a *App) GetUsageSummary(period string) (UsageSummary, error) {
+	since, err := periodStart(period, time.Now())
+	if err != nil {
+		return UsageSummary{}, err
+	}
+	entries, err := a.readUsage(since)
+	if err != nil {
+		return UsageSummary{}, err
+	}
+
+	sum := UsageSummary{Period: period, Since: since, Models: []ModelUsage{}}
+	switch budget := a.GetConfig().Budget; period {
+	case "day":
+		sum.Budget = budget.Daily
+	case "month":
+		sum.Budget = budget.Monthly
+	}
+
+	byModel := map[string]*ModelUsage{}
+	for _, e := range entries {
+		sum.Requests++
+		sum.InputTokens += e.InputTokens
+		sum.OutputTokens += e.OutputTokens
+		sum.CacheReadTokens += e.CacheReadTokens
+		sum.CacheWriteTokens += e.CacheWriteTokens
+		sum.Cost += e.Cost
+		if !e.Priced {
+			sum.Unpriced++
+		}
+		mu := byModel[e.Model]
+		if mu == nil {
+			mu = &ModelUsage{Model: e.Model}
+			byModel[e.Model] = mu
+		}
+		mu.Requests++
+		mu.InputTokens += e.InputTokens
+		mu.OutputTokens += e.OutputTokens
+		mu.CacheReadTokens += e.CacheReadTokens
+		mu.CacheWriteTokens += e.CacheWriteTokens
+		mu.Cost += e.Cost
+	}
+	for _, mu := range byModel {
+		sum.Models = append(sum.Models, *mu)
+	}
+	sort.Slice(sum.Models, func(i, j int) bool {
+		if sum.Models[i].Cost != sum.Models[j].Cost {
+			return sum.Models[i].Cost > sum.Models[j].Cost
+		}
+		return sum.Models[i].Model < sum.Models[j].Model
+	})
+	return sum, nil
+}
+
+// checkBudget returns ErrBudgetExceeded once today's or this month's
+// spending has reached its cap.
+func (a *App) checkBudget(budget BudgetConfig) error {
+	caps := []struct {
+		period, label string
+		limit         float64
+	}{
+		{"day", "daily", budget.Daily},
+		{"month", "monthly", budget.Monthly},
+	}
+	for _, c := range caps {
+		if c.limit <= 0 {
+			continue
+		}
+		sum, err := a.GetUsageSummary(c.period)
+		if err != nil {
+			return err
+		}
+		if sum.Cost >= c.limit {
+			return fmt.Errorf("%w: %s cap of $%.2f reached ($%.2f spent) — raise it in Settings or use a local model",
+				ErrBudgetExceeded, c.label, c.limit, sum.Cost)
+		}
+	}
+	return nil
+}
+
+// SaveBudget sets the daily and monthly spending caps in USD; 0 removes a cap.
+func (a *App) SaveBudget(daily float64, monthly float64) error {
+	if daily < 0 || monthly < 0 {
+		return fmt.Errorf("spending caps cannot be negative")
+	}
+	cfg := a.GetConfig()
+	cfg.Budget = BudgetConfig{Daily: daily, Monthly: monthly}
+	return a.writeConfig(cfg)
+}
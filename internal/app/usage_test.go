@@ -0,0 +1,112 @@
+package app
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lay/internal/ai"
+)
+
+func newUsageTestApp(t *testing.T) *App {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	if err := os.MkdirAll(layDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return New()
+}
+
+func TestRecordUsagePricesAndSummarises(t *testing.T) {
+	a := newUsageTestApp(t)
+	custom := `{"acme-large":{"input":10,"output":20}}`
+	if err := os.WriteFile(filepath.Join(layDir(), "pricing.json"), []byte(custom), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := []*ai.Response{
+		{Model: ai.Model{ID: "claude-sonnet-4-6", Provider: "anthropic"}, Provider: "anthropic", Usage: ai.Usage{InputTokens: 1_000_000, OutputTokens: 100_000}},
+		{Model: ai.Model{ID: "anthropic/claude-sonnet-4-6"}, Provider: "gateway", Usage: ai.Usage{InputTokens: 1000, OutputTokens: 0}},
+		{Model: ai.Model{ID: "acme-large", Provider: "openai"}, Provider: "openai", Usage: ai.Usage{InputTokens: 500_000, OutputTokens: 0}},
+		{Model: ai.Model{ID: "mystery-1"}, Provider: "gateway", Usage: ai.Usage{InputTokens: 10}},
+		{Model: ai.Model{ID: "llama3.2", Provider: "local"}, Provider: "local", Usage: ai.Usage{InputTokens: 10}},
+	}
+	for _, r := range responses {
+		if err := a.recordUsage(r); err != nil {
+			t.Fatalf("recordUsage() error = %v", err)
+		}
+	}
+
+	info, err := os.Stat(usagePath())
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("ledger should exist with mode 600, got %v, %v", info, err)
+	}
+
+	sum, err := a.GetUsageSummary("day")
+	if err != nil {
+		t.Fatalf("GetUsageSummary() error = %v", err)
+	}
+	// 3 + 1.5 for Sonnet direct, 0.003 via the gateway, 5 for the custom rate.
+	if want := 9.503; math.Abs(sum.Cost-want) > 1e-9 {
+		t.Fatalf("Cost = %v, want %v", sum.Cost, want)
+	}
+	if sum.Requests != 5 || sum.Unpriced != 1 {
+		t.Fatalf("Requests = %d, Unpriced = %d; want 5 and 1", sum.Requests, sum.Unpriced)
+	}
+	if sum.Models[0].Model != "acme-large" {
+		t.Fatalf("models should be sorted by cost, got %+v", sum.Models)
+	}
+
+	if _, err := a.GetUsageSummary("week"); err == nil {
+		t.Fatal("expected an error for an unknown period")
+	}
+}
+
+func TestModelPriceCountsCache(t *testing.T) {
+	u := ai.Usage{InputTokens: 100_000, OutputTokens: 10_000, CacheReadTokens: 1_000_000, CacheWriteTokens: 200_000}
+	// Sonnet: 0.3 in, 0.15 out, 0.3 read at a tenth, 0.75 written at 1.25×.
+	if got := defaultPricing["claude-sonnet-4-6"].cost(u); math.Abs(got-1.5) > 1e-9 {
+		t.Fatalf("Sonnet cost = %v, want 1.5", got)
+	}
+	// GPT-4o reads its cache at half price.
+	if got := defaultPricing["gpt-4o"].cost(ai.Usage{CacheReadTokens: 1_000_000}); got != 1.25 {
+		t.Fatalf("GPT-4o cache read cost = %v, want 1.25", got)
+	}
+}
+
+func TestSendMessageRefusesOverBudget(t *testing.T) {
+	a := newUsageTestApp(t)
+	if err := a.SaveBudget(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	err := a.recordUsage(&ai.Response{
+		Model:    ai.Model{ID: "claude-opus-4-6", Provider: "anthropic"},
+		Provider: "anthropic",
+		Usage:    ai.Usage{OutputTokens: 100_000}, // $2.50
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = a.SendMessage("req-1", "chat-1", `{"content":"hi"}`)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("SendMessage() error = %v, want ErrBudgetExceeded", err)
+	}
+
+	sum, _ := a.GetUsageSummary("day")
+	if sum.Budget != 1 {
+		t.Fatalf("Budget = %v, want the daily cap", sum.Budget)
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2026, 3, 14, 15, 9, 26, 0, time.Local)
+	day, _ := periodStart("day", now)
+	month, _ := periodStart("month", now)
+	if !day.Equal(time.Date(2026, 3, 14, 0, 0, 0, 0, time.Local)) || !month.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("periodStart() = %v, %v", day, month)
+	}
+}